@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/acramatte/Chirpy/internal/auth"
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// staff is the moderator or admin making an admin request.
+type staff struct {
+	// UserID is unset when the request was made with the admin API key.
+	UserID uuid.NullUUID
+	Role   string
+}
+
+type staffContextKey struct{}
+
+func staffFromContext(ctx context.Context) (staff, bool) {
+	s, ok := ctx.Value(staffContextKey{}).(staff)
+	return s, ok
+}
+
+// middlewareRole only lets requests through from users with at least the given role. The role is
+// looked up on every request rather than carried in the JWT, so demotions take effect immediately.
+// The admin API key, if configured, is accepted as an admin without a user for automation.
+func (cfg *apiConfig) middlewareRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, ok := staffFromContext(r.Context())
+		if !ok {
+			var err error
+			s, err = cfg.resolveStaff(r)
+			if err != nil {
+				respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), staffContextKey{}, s))
+		}
+		if !auth.HasRole(s.Role, role) {
+			respondWithError(w, http.StatusForbidden, "Insufficient role", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (cfg *apiConfig) resolveStaff(r *http.Request) (staff, error) {
+	if strings.HasPrefix(r.Header.Get("Authorization"), "ApiKey ") {
+		if cfg.config.AdminAPIKey == "" {
+			return staff{}, errors.New("ADMIN_API_KEY is not set")
+		}
+		apiKey, err := auth.GetAPIKey(r.Header)
+		if err != nil {
+			return staff{}, err
+		}
+		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.config.AdminAPIKey)) != 1 {
+			return staff{}, errors.New("API key not authorized")
+		}
+		return staff{Role: auth.RoleAdmin}, nil
+	}
+
+	userID, err := cfg.sessionUserID(r)
+	if err != nil {
+		return staff{}, err
+	}
+	user, err := cfg.users.GetUser(r.Context(), userID)
+	if err != nil {
+		return staff{}, err
+	}
+	if isSuspended(user, time.Now().UTC()) {
+		return staff{}, errors.New("account is suspended")
+	}
+	return staff{UserID: uuid.NullUUID{UUID: user.ID, Valid: true}, Role: user.Role}, nil
+}
+
+// bootstrapAdmin promotes the user with the given email to admin as long as there is no admin yet,
+// so a fresh deployment can get its first admin without database access.
+func (cfg *apiConfig) bootstrapAdmin(ctx context.Context, email string) error {
+	admins, err := cfg.users.CountUsersByRole(ctx, auth.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if admins > 0 {
+		return nil
+	}
+	user, err := cfg.users.GetUserByEmail(ctx, email)
+	if err != nil {
+		slog.Warn("No admin yet and couldn't find the bootstrap admin", "email", email, "err", err)
+		return nil
+	}
+	_, err = cfg.users.UpdateUserRole(ctx, database.UpdateUserRoleParams{ID: user.ID, Role: auth.RoleAdmin})
+	if err != nil {
+		return err
+	}
+	slog.Info("Promoted bootstrap admin", "email", email)
+	return nil
+}
+
+func (cfg *apiConfig) handlerAdminUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+	type parameters struct {
+		Role string `json:"role"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	role, err := auth.ParseRole(params.Role)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	user, err := cfg.users.GetUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	previousRole := user.Role
+	user, err = cfg.users.UpdateUserRole(r.Context(), database.UpdateUserRoleParams{ID: user.ID, Role: role})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "Can't demote the last admin", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update role", err)
+		return
+	}
+	actorType, actorID := staffActor(r.Context())
+	cfg.audit(r.Context(), auditEntry{
+		Action:     auditRoleChanged,
+		ActorType:  actorType,
+		ActorID:    actorID,
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+		Details:    map[string]interface{}{"from": previousRole, "to": user.Role},
+	})
+	type response struct {
+		ID   uuid.UUID `json:"id"`
+		Role string    `json:"role"`
+	}
+	respondWithJSON(w, http.StatusOK, response{ID: user.ID, Role: user.Role})
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	auditLogin           = "auth.login"
+	auditLoginFailed     = "auth.login_failed"
+	auditPasswordChanged = "user.password_changed"
+	auditEmailChanged    = "user.email_changed"
+	auditTokenRevoked    = "token.revoked"
+	auditSubscription    = "subscription.changed"
+	auditChirpDeleted    = "chirp.deleted"
+	auditReset           = "admin.reset"
+	auditRoleChanged     = "admin.role_changed"
+	auditLoginUnlocked   = "admin.login_unlocked"
+	auditUserCreated     = "admin.user_created"
+	auditModeration      = "moderation.action"
+
+	actorUser        = "user"
+	actorAnonymous   = "anonymous"
+	actorAdminAPIKey = "admin_api_key"
+	actorOAuthClient = "oauth_client"
+	actorPolka       = "polka"
+	actorCLI         = "cli"
+)
+
+// auditExportPageSize is how many entries an export reads from the database at a time.
+const auditExportPageSize = 1000
+
+// auditEntry is a security relevant or admin event. The request ID and IP are taken from the context.
+type auditEntry struct {
+	Action     string
+	ActorType  string
+	ActorID    uuid.NullUUID
+	TargetType string
+	TargetID   string
+	Details    map[string]interface{}
+}
+
+func userActor(userID uuid.UUID) (string, uuid.NullUUID) {
+	return actorUser, uuid.NullUUID{UUID: userID, Valid: true}
+}
+
+// staffActor is the moderator or admin making the current admin request.
+func staffActor(ctx context.Context) (string, uuid.NullUUID) {
+	s, _ := staffFromContext(ctx)
+	if !s.UserID.Valid {
+		return actorAdminAPIKey, uuid.NullUUID{}
+	}
+	return actorUser, s.UserID
+}
+
+// audit appends an entry to the audit log. Like emitEvent it only logs failures, since the
+// action it records has already happened.
+func (cfg *apiConfig) audit(ctx context.Context, entry auditEntry) {
+	details := []byte("{}")
+	if entry.Details != nil {
+		var err error
+		details, err = json.Marshal(entry.Details)
+		if err != nil {
+			loggerFromContext(ctx).Error("Couldn't encode audit details", "action", entry.Action, "err", err)
+			return
+		}
+	}
+	info := requestInfoFromContext(ctx)
+	err := cfg.auditLog.CreateAuditEntry(ctx, database.CreateAuditEntryParams{
+		Action:     entry.Action,
+		ActorType:  entry.ActorType,
+		ActorID:    entry.ActorID,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Ip:         info.IP,
+		RequestID:  info.ID,
+		Details:    string(details),
+	})
+	if err != nil {
+		loggerFromContext(ctx).Error("Couldn't record audit entry", "action", entry.Action, "err", err)
+	}
+}
+
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Action     string          `json:"action"`
+	ActorType  string          `json:"actor_type"`
+	ActorID    *uuid.UUID      `json:"actor_id"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	IP         string          `json:"ip"`
+	RequestID  string          `json:"request_id"`
+	Details    json.RawMessage `json:"details"`
+}
+
+func auditEntryFromDB(entry database.AuditLog) AuditEntry {
+	return AuditEntry{
+		ID:         entry.ID,
+		CreatedAt:  entry.CreatedAt,
+		Action:     entry.Action,
+		ActorType:  entry.ActorType,
+		ActorID:    nullUUIDPtr(entry.ActorID),
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		IP:         entry.Ip,
+		RequestID:  entry.RequestID,
+		Details:    json.RawMessage(entry.Details),
+	}
+}
+
+// auditFilter reads the filters shared by the audit list and export endpoints.
+func auditFilter(w http.ResponseWriter, r *http.Request) (database.GetAuditEntriesParams, bool) {
+	query := r.URL.Query()
+	params := database.GetAuditEntriesParams{}
+	if action := query.Get("action"); action != "" {
+		params.Action = sql.NullString{String: action, Valid: true}
+	}
+	if actorID := query.Get("actor_id"); actorID != "" {
+		id, err := uuid.Parse(actorID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid actor_id", err)
+			return params, false
+		}
+		params.ActorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	if targetID := query.Get("target_id"); targetID != "" {
+		params.TargetID = sql.NullString{String: targetID, Valid: true}
+	}
+	for name, dst := range map[string]*sql.NullTime{"since": &params.Since, "until": &params.Until} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid "+name+", use RFC 3339", err)
+			return params, false
+		}
+		*dst = sql.NullTime{Time: t.UTC(), Valid: true}
+	}
+	return params, true
+}
+
+// handlerAdminAuditList lists matching entries newest first. The next page starts before the
+// last ID of this one.
+func (cfg *apiConfig) handlerAdminAuditList(w http.ResponseWriter, r *http.Request) {
+	filter, ok := auditFilter(w, r)
+	if !ok {
+		return
+	}
+	params := database.GetRecentAuditEntriesParams{
+		Action:   filter.Action,
+		ActorID:  filter.ActorID,
+		TargetID: filter.TargetID,
+		Since:    filter.Since,
+		Until:    filter.Until,
+	}
+	if before := r.URL.Query().Get("before"); before != "" {
+		id, err := strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid before", err)
+			return
+		}
+		params.BeforeID = sql.NullInt64{Int64: id, Valid: true}
+	}
+	limit := 50
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		limit = min(n, 500)
+	}
+	params.MaxEntries = int32(limit)
+	dbEntries, err := cfg.auditLog.GetRecentAuditEntries(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve audit log", err)
+		return
+	}
+	entries := []AuditEntry{}
+	for _, entry := range dbEntries {
+		entries = append(entries, auditEntryFromDB(entry))
+	}
+	respondWithJSON(w, http.StatusOK, entries)
+}
+
+// handlerAdminAuditExport streams every matching entry as newline delimited JSON, one entry per
+// line, for ingestion by a SIEM. Exports can resume from the last ID they received with after.
+func (cfg *apiConfig) handlerAdminAuditExport(w http.ResponseWriter, r *http.Request) {
+	params, ok := auditFilter(w, r)
+	if !ok {
+		return
+	}
+	if after := r.URL.Query().Get("after"); after != "" {
+		id, err := strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid after", err)
+			return
+		}
+		params.AfterID = id
+	}
+	params.MaxEntries = auditExportPageSize
+	// Exports of a large log can take longer than the server's write timeout.
+	err := http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	if err != nil {
+		loggerFromContext(r.Context()).Warn("Couldn't lift the write deadline for the audit export", "err", err)
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	for {
+		dbEntries, err := cfg.auditLog.GetAuditEntries(r.Context(), params)
+		if err != nil {
+			// The status has already been sent; a truncated export is detected by resuming it.
+			loggerFromContext(r.Context()).Error("Couldn't export audit log", "err", err)
+			return
+		}
+		for _, entry := range dbEntries {
+			err = encoder.Encode(auditEntryFromDB(entry))
+			if err != nil {
+				loggerFromContext(r.Context()).Error("Couldn't write audit export", "err", err)
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if len(dbEntries) < auditExportPageSize {
+			return
+		}
+		params.AfterID = dbEntries[len(dbEntries)-1].ID
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAdminAuditOrder(t *testing.T) {
+	cfg, _ := newTestAPIConfig(t)
+	for i := range 5 {
+		cfg.audit(context.Background(), auditEntry{Action: auditLogin, TargetID: fmt.Sprint(i)})
+	}
+
+	list := func(path string) []int64 {
+		t.Helper()
+		rec := serve(t, cfg.handlerAdminAuditList, "GET", path, nil, "")
+		var entries []AuditEntry
+		err := json.NewDecoder(rec.Body).Decode(&entries)
+		if err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("%s returned %d, %v", path, rec.Code, err)
+		}
+		ids := []int64{}
+		for _, entry := range entries {
+			ids = append(ids, entry.ID)
+		}
+		return ids
+	}
+	ids := list("/admin/audit?limit=2")
+	if fmt.Sprint(ids) != "[5 4]" {
+		t.Errorf("Expected the newest entries first, got %v", ids)
+	}
+	ids = list(fmt.Sprintf("/admin/audit?limit=2&before=%d", ids[1]))
+	if fmt.Sprint(ids) != "[3 2]" {
+		t.Errorf("Expected the next page to continue before the cursor, got %v", ids)
+	}
+	rec := serve(t, cfg.handlerAdminAuditList, "GET", "/admin/audit?before=x", nil, "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("An invalid before returned %d", rec.Code)
+	}
+
+	rec = serve(t, cfg.handlerAdminAuditExport, "GET", "/admin/audit/export?after=2", nil, "")
+	ids = []int64{}
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var entry AuditEntry
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, entry.ID)
+	}
+	if fmt.Sprint(ids) != "[3 4 5]" {
+		t.Errorf("Expected the export to stay oldest first from the cursor, got %v", ids)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/acramatte/Chirpy/internal/auth"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+var errInsufficientScope = errors.New("token does not grant the required scope")
+
+// authenticate resolves the calling user from the bearer token, which is either a session JWT
+// (implicitly granted every scope), a JWT issued to an OAuth client, or a personal access token.
+// The latter two must carry the required scope. Tokens of suspended users are rejected, since
+// access tokens and personal access tokens outlive a suspension.
+func (cfg *apiConfig) authenticate(r *http.Request, scope string) (uuid.UUID, error) {
+	userID, err := cfg.authenticateToken(r, scope)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	err = cfg.checkAccountActive(r.Context(), userID)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	setRequestUser(r.Context(), userID)
+	return userID, nil
+}
+
+func (cfg *apiConfig) authenticateToken(r *http.Request, scope string) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	if !auth.IsPersonalAccessToken(token) {
+		claims, err := auth.ParseJWT(token, cfg.config.JWTSecret)
+		if err != nil {
+			return uuid.UUID{}, err
+		}
+		if !claims.IsSession() && !auth.HasScope(claims.Scope, scope) {
+			return uuid.UUID{}, errInsufficientScope
+		}
+		return claims.UserID()
+	}
+
+	pat, err := cfg.personalAccessTokens.GetPersonalAccessTokenByHash(r.Context(), auth.HashToken(token))
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("invalid personal access token: %w", err)
+	}
+	if !auth.HasScope(pat.Scopes, scope) {
+		return uuid.UUID{}, errInsufficientScope
+	}
+	err = cfg.personalAccessTokens.TouchPersonalAccessToken(r.Context(), pat.ID)
+	if err != nil {
+		loggerFromContext(r.Context()).Warn("Couldn't record personal access token usage", "err", err)
+	}
+	return pat.UserID, nil
+}
+
+// sessionUserID only accepts first-party session JWTs. Credential management endpoints use it so that
+// neither personal access tokens nor OAuth clients can mint further credentials.
+func (cfg *apiConfig) sessionUserID(r *http.Request) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	claims, err := auth.ParseJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	if !claims.IsSession() {
+		return uuid.UUID{}, errors.New("token was not issued for a user session")
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	err = cfg.checkAccountActive(r.Context(), userID)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	setRequestUser(r.Context(), userID)
+	return userID, nil
+}
+
+// viewerID identifies the caller of endpoints that also serve anonymous requests. Missing or
+// invalid credentials make the request anonymous rather than failing it.
+func (cfg *apiConfig) viewerID(r *http.Request) uuid.NullUUID {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.NullUUID{}
+	}
+	userID, err := cfg.authenticate(r, auth.ScopeChirpsRead)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}
+}
+
+func respondWithAuthError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errInsufficientScope) {
+		respondWithError(w, http.StatusForbidden, "Insufficient scope", err)
+		return
+	}
+	if errors.Is(err, errAccountSuspended) {
+		respondWithError(w, http.StatusForbidden, "Account suspended", err)
+		return
+	}
+	respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/acramatte/Chirpy/internal/auth"
+	"github.com/acramatte/Chirpy/internal/database"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthenticateRejectsSuspendedUsers(t *testing.T) {
+	cfg, store := newTestAPIConfig(t)
+	user, err := store.CreateUser(context.Background(), database.CreateUserParams{Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := auth.MakeJWT(user.ID, cfg.config.JWTSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/api/chirps", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	_, err = cfg.authenticate(req, auth.ScopeChirpsWrite)
+	if err != nil {
+		t.Fatalf("Expected the token to be accepted, got %v", err)
+	}
+
+	err = cfg.suspendUser(context.Background(), user.ID, sql.NullTime{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cfg.authenticate(req, auth.ScopeChirpsWrite)
+	if !errors.Is(err, errAccountSuspended) {
+		t.Errorf("Expected the token of a suspended user to be rejected, got %v", err)
+	}
+	_, err = cfg.sessionUserID(req)
+	if !errors.Is(err, errAccountSuspended) {
+		t.Errorf("Expected the session of a suspended user to be rejected, got %v", err)
+	}
+	rec := httptest.NewRecorder()
+	respondWithAuthError(rec, err)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a suspended user, got %d", rec.Code)
+	}
+}
+
+func TestMayModerateAccount(t *testing.T) {
+	cases := []struct {
+		caller, target string
+		expected       bool
+	}{
+		{auth.RoleModerator, auth.RoleUser, true},
+		{auth.RoleModerator, auth.RoleModerator, false},
+		{auth.RoleModerator, auth.RoleAdmin, false},
+		{auth.RoleAdmin, auth.RoleModerator, true},
+		{auth.RoleAdmin, auth.RoleAdmin, true},
+	}
+	for _, c := range cases {
+		ctx := context.WithValue(context.Background(), staffContextKey{}, staff{Role: c.caller})
+		if got := mayModerateAccount(ctx, database.User{Role: c.target}); got != c.expected {
+			t.Errorf("mayModerateAccount(%s on %s) = %v, expected %v", c.caller, c.target, got, c.expected)
+		}
+	}
+}
+
+func TestUsersUpdateRequiresSession(t *testing.T) {
+	ctx := context.Background()
+	cfg, store := newTestAPIConfig(t)
+	user, err := store.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oauthToken, err := auth.MakeScopedJWT(user.ID, cfg.config.JWTSecret, time.Hour, "client", strings.Join(auth.AllScopes, " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pat, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cfg.personalAccessTokens.CreatePersonalAccessToken(ctx, database.CreatePersonalAccessTokenParams{
+		UserID:    user.ID,
+		Name:      "everything",
+		TokenHash: auth.HashToken(pat),
+		Scopes:    strings.Join(auth.AllScopes, " "),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{oauthToken, pat} {
+		req := httptest.NewRequest("PUT", "/api/users", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		_, err = cfg.authenticate(req, auth.ScopeChirpsWrite)
+		if err != nil {
+			t.Fatalf("Expected the token to be valid, got %v", err)
+		}
+	}
+	params := map[string]string{"email": "mallory@example.com", "password": "correct horse battery"}
+
+	for _, token := range []string{oauthToken, pat} {
+		rec := serve(t, cfg.handlerUsersUpdate, "PUT", "/api/users", params, token)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected credential changes without a session to be rejected, got %d", rec.Code)
+		}
+	}
+	user, err = store.GetUser(ctx, user.ID)
+	if err != nil || user.Email != "alice@example.com" {
+		t.Errorf("Expected the email to be unchanged, got %q, %v", user.Email, err)
+	}
+
+	session, err := auth.MakeJWT(user.ID, cfg.config.JWTSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := serve(t, cfg.handlerUsersUpdate, "PUT", "/api/users", params, session)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected a session to change credentials, got %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/acramatte/Chirpy/internal/auth"
+	"github.com/acramatte/Chirpy/internal/config"
+	"github.com/acramatte/Chirpy/internal/database"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const usage = `usage: chirpy [flags] <command>
+
+Commands:
+  serve                                   run the HTTP server (the default)
+  migrate up|down|status                  apply, roll back or list database migrations
+  user create -email E [-role R]          create a user
+  user promote -email E [-role R]         change a user's role (default admin)
+  user suspend -email E [-duration D]     suspend a user, permanently without a duration
+  user reset-password -email E            set a new password and end the user's sessions
+  token revoke-all -email E               revoke a user's refresh and personal access tokens
+  chirps purge -email E                   delete every chirp by a user
+  seed [-users N] [-chirps N]             create sample users and chirps (dev only)
+
+Commands that set a password read it from stdin with -password-stdin, or generate and print one.`
+
+// runCommand runs the subcommand named by args. Every command but serve stops at the first
+// SIGINT or SIGTERM.
+func runCommand(conf config.Config, args []string, stdin io.Reader, stdout io.Writer) error {
+	if args[0] == "serve" {
+		if len(args) > 1 {
+			return errors.New(usage)
+		}
+		return runServer(conf)
+	}
+	switch args[0] {
+	case "migrate", "user", "token", "chirps", "seed":
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+
+	if conf.DBURL == memoryDBURL {
+		return fmt.Errorf("%s needs a database, DB_URL=memory: only works with serve", args[0])
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	db, dialect, err := openDatabase(conf.DBURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if args[0] == "migrate" {
+		return runMigrate(ctx, db, dialect, args[1:], stdout)
+	}
+
+	repos, err := newRepositories(db, dialect)
+	if err != nil {
+		return err
+	}
+	cfg, err := newAPIConfig(conf, repos, db)
+	if err != nil {
+		return err
+	}
+	cli := commandLine{cfg: cfg, stdin: stdin, stdout: stdout}
+	switch args[0] {
+	case "user":
+		return cli.user(ctx, args[1:])
+	case "token":
+		return cli.token(ctx, args[1:])
+	case "chirps":
+		return cli.chirps(ctx, args[1:])
+	default:
+		return cli.seed(ctx, args[1:])
+	}
+}
+
+// commandLine runs the admin commands, which go through the same queries and checks as the
+// admin API and are audited with the cli actor.
+type commandLine struct {
+	cfg    *apiConfig
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func (cli commandLine) user(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	fs := newCommandFlags("user " + args[0])
+	email := fs.String("email", "", "email of the user")
+	switch args[0] {
+	case "create":
+		role := fs.String("role", auth.RoleUser, "role of the new user")
+		passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin")
+		err := parseCommandFlags(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		parsedRole, err := auth.ParseRole(*role)
+		if err != nil {
+			return err
+		}
+		password, err := cli.password(*passwordStdin)
+		if err != nil {
+			return err
+		}
+		hashedPassword, err := auth.HashPassword(password)
+		if err != nil {
+			return err
+		}
+		user, err := cli.cfg.users.CreateUserWithRole(ctx, database.CreateUserWithRoleParams{
+			Email:          *email,
+			HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
+			Role:           parsedRole,
+		})
+		if err != nil {
+			return fmt.Errorf("couldn't create user: %w", err)
+		}
+		cli.cfg.audit(ctx, auditEntry{
+			Action:     auditUserCreated,
+			ActorType:  actorCLI,
+			TargetType: "user",
+			TargetID:   user.ID.String(),
+			Details:    map[string]interface{}{"role": user.Role},
+		})
+		fmt.Fprintf(cli.stdout, "Created %s user %s (%s)\n", user.Role, user.Email, user.ID)
+		return nil
+	case "promote":
+		role := fs.String("role", auth.RoleAdmin, "new role of the user")
+		err := parseCommandFlags(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		parsedRole, err := auth.ParseRole(*role)
+		if err != nil {
+			return err
+		}
+		user, err := cli.lookupUser(ctx, *email)
+		if err != nil {
+			return err
+		}
+		previousRole := user.Role
+		user, err = cli.cfg.users.UpdateUserRole(ctx, database.UpdateUserRoleParams{ID: user.ID, Role: parsedRole})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("can't demote the last admin")
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't update role: %w", err)
+		}
+		cli.cfg.audit(ctx, auditEntry{
+			Action:     auditRoleChanged,
+			ActorType:  actorCLI,
+			TargetType: "user",
+			TargetID:   user.ID.String(),
+			Details:    map[string]interface{}{"from": previousRole, "to": user.Role},
+		})
+		fmt.Fprintf(cli.stdout, "%s is now %s\n", user.Email, user.Role)
+		return nil
+	case "suspend":
+		duration := fs.Duration("duration", 0, "how long to suspend the user for, such as 72h")
+		err := parseCommandFlags(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if *duration < 0 {
+			return errors.New("-duration can't be negative")
+		}
+		if *duration > maxSuspension {
+			return fmt.Errorf("-duration can't be more than %s, omit it to suspend permanently", maxSuspension)
+		}
+		user, err := cli.lookupUser(ctx, *email)
+		if err != nil {
+			return err
+		}
+		until := sql.NullTime{}
+		if *duration > 0 {
+			until = sql.NullTime{Time: time.Now().UTC().Add(*duration), Valid: true}
+		}
+		err = cli.cfg.suspendUser(ctx, user.ID, until)
+		if err != nil {
+			return fmt.Errorf("couldn't suspend user: %w", err)
+		}
+		action, err := cli.cfg.reports.CreateModerationAction(ctx, database.CreateModerationActionParams{
+			Action:        moderationActionSuspend,
+			UserID:        user.ID,
+			ModeratorType: actorCLI,
+		})
+		if err != nil {
+			return fmt.Errorf("suspended the user but couldn't record the moderation action: %w", err)
+		}
+		cli.cfg.auditModerationAction(ctx, action)
+		if until.Valid {
+			fmt.Fprintf(cli.stdout, "Suspended %s until %s\n", user.Email, until.Time.Format(time.RFC3339))
+		} else {
+			fmt.Fprintf(cli.stdout, "Suspended %s permanently\n", user.Email)
+		}
+		return nil
+	case "reset-password":
+		passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin")
+		err := parseCommandFlags(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		user, err := cli.lookupUser(ctx, *email)
+		if err != nil {
+			return err
+		}
+		password, err := cli.password(*passwordStdin)
+		if err != nil {
+			return err
+		}
+		hashedPassword, err := auth.HashPassword(password)
+		if err != nil {
+			return err
+		}
+		err = cli.cfg.users.UpdatePasswordHash(ctx, database.UpdatePasswordHashParams{ID: user.ID, HashedPassword: sql.NullString{String: hashedPassword, Valid: true}})
+		if err != nil {
+			return fmt.Errorf("couldn't update password: %w", err)
+		}
+		err = cli.cfg.refreshTokens.RevokeUserRefreshTokens(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("couldn't end sessions: %w", err)
+		}
+		cli.cfg.audit(ctx, auditEntry{
+			Action:     auditPasswordChanged,
+			ActorType:  actorCLI,
+			TargetType: "user",
+			TargetID:   user.ID.String(),
+		})
+		fmt.Fprintf(cli.stdout, "Reset the password of %s\n", user.Email)
+		return nil
+	default:
+		return fmt.Errorf("unknown command \"user %s\"\n%s", args[0], usage)
+	}
+}
+
+func (cli commandLine) token(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "revoke-all" {
+		return errors.New(usage)
+	}
+	fs := newCommandFlags("token revoke-all")
+	email := fs.String("email", "", "email of the user")
+	err := parseCommandFlags(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	user, err := cli.lookupUser(ctx, *email)
+	if err != nil {
+		return err
+	}
+	err = cli.cfg.refreshTokens.RevokeUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't revoke refresh tokens: %w", err)
+	}
+	revoked, err := cli.cfg.personalAccessTokens.RevokeUserPersonalAccessTokens(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't revoke personal access tokens: %w", err)
+	}
+	cli.cfg.audit(ctx, auditEntry{
+		Action:     auditTokenRevoked,
+		ActorType:  actorCLI,
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+		Details:    map[string]interface{}{"all": true, "personal_access_tokens": revoked},
+	})
+	fmt.Fprintf(cli.stdout, "Revoked the refresh tokens and %d personal access tokens of %s\n", revoked, user.Email)
+	return nil
+}
+
+func (cli commandLine) chirps(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "purge" {
+		return errors.New(usage)
+	}
+	fs := newCommandFlags("chirps purge")
+	email := fs.String("email", "", "email of the author")
+	err := parseCommandFlags(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	user, err := cli.lookupUser(ctx, *email)
+	if err != nil {
+		return err
+	}
+	deleted, err := cli.cfg.chirps.DeleteChirpsByUser(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't delete chirps: %w", err)
+	}
+	for _, chirp := range deleted {
+		cli.cfg.emitEvent(ctx, eventChirpDeleted, user.ID, chirpEventData(chirp))
+	}
+	cli.cfg.audit(ctx, auditEntry{
+		Action:     auditChirpDeleted,
+		ActorType:  actorCLI,
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+		Details:    map[string]interface{}{"purged": len(deleted)},
+	})
+	fmt.Fprintf(cli.stdout, "Deleted %d chirps by %s\n", len(deleted), user.Email)
+	return nil
+}
+
+var seedChirps = []string{
+	"Hello, Chirpy!",
+	"I had something interesting for breakfast",
+	"Just setting up my chirpy",
+	"Gonna get a bowl of cereal",
+	"What a lovely day for a walk",
+}
+
+// seed fills a development database with users and chirps. Users that already exist are reused.
+func (cli commandLine) seed(ctx context.Context, args []string) error {
+	fs := newCommandFlags("seed")
+	users := fs.Int("users", 5, "number of users")
+	chirps := fs.Int("chirps", 10, "number of chirps per user")
+	err := parseCommandFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if cli.cfg.config.Platform != "dev" {
+		return errors.New("seed is only allowed when PLATFORM is dev")
+	}
+	password, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	for i := 1; i <= *users; i++ {
+		email := fmt.Sprintf("user%d@example.com", i)
+		user, err := cli.cfg.users.GetUserByEmail(ctx, email)
+		if errors.Is(err, sql.ErrNoRows) {
+			user, err = cli.cfg.users.CreateUser(ctx, database.CreateUserParams{Email: email, HashedPassword: sql.NullString{String: hashedPassword, Valid: true}})
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't create %s: %w", email, err)
+		}
+		for j := 0; j < *chirps; j++ {
+			_, err = cli.cfg.chirps.CreateChirp(ctx, database.CreateChirpParams{Body: seedChirps[(i+j)%len(seedChirps)], UserID: user.ID})
+			if err != nil {
+				return fmt.Errorf("couldn't create chirp: %w", err)
+			}
+		}
+	}
+	fmt.Fprintf(cli.stdout, "Seeded %d users with %d chirps each. New users have the password %s\n", *users, *chirps, password)
+	return nil
+}
+
+func (cli commandLine) lookupUser(ctx context.Context, email string) (database.User, error) {
+	user, err := cli.cfg.users.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("no user with email %s", email)
+	}
+	return user, err
+}
+
+// password reads a password from the first line of stdin, or generates one and prints it so
+// passwords never end up in the shell history.
+func (cli commandLine) password(fromStdin bool) (string, error) {
+	if !fromStdin {
+		password, err := auth.MakeRefreshToken()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(cli.stdout, "Generated password: %s\n", password)
+		return password, nil
+	}
+	password, err := bufio.NewReader(cli.stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password = strings.TrimRight(password, "\r\n")
+	err = cli.cfg.passwordPolicy.Validate(password)
+	if err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+func newCommandFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parseCommandFlags parses a command's flags and requires -email for commands that have it.
+func parseCommandFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Name(), err)
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("%s: unexpected arguments %s", fs.Name(), strings.Join(fs.Args(), " "))
+	}
+	if email := fs.Lookup("email"); email != nil && email.Value.String() == "" {
+		return fmt.Errorf("%s: -email is required", fs.Name())
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/acramatte/Chirpy/internal/auth"
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"strings"
+	"testing"
+)
+
+// newTestCommandLine returns a commandLine on an in-memory store that reads stdin from input.
+func newTestCommandLine(t *testing.T, input string) (commandLine, *apiConfig, *bytes.Buffer) {
+	t.Helper()
+	cfg, _ := newTestAPIConfig(t)
+	var stdout bytes.Buffer
+	return commandLine{cfg: cfg, stdin: strings.NewReader(input), stdout: &stdout}, cfg, &stdout
+}
+
+func TestCommandUserCreate(t *testing.T) {
+	ctx := context.Background()
+	cli, cfg, stdout := newTestCommandLine(t, "correct horse battery\n")
+
+	err := cli.user(ctx, []string{"create", "-email", "mod@example.com", "-role", "moderator", "-password-stdin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := cfg.users.GetUserByEmail(ctx, "mod@example.com")
+	if err != nil || user.Role != auth.RoleModerator {
+		t.Fatalf("Expected a moderator, got %+v, %v", user, err)
+	}
+	err = auth.CheckPasswordHash("correct horse battery", user.HashedPassword.String)
+	if err != nil {
+		t.Errorf("Expected the password from stdin, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "Created moderator user mod@example.com") {
+		t.Errorf("Unexpected output %q", stdout)
+	}
+
+	err = cli.user(ctx, []string{"create", "-email", "bad@example.com", "-role", "superuser"})
+	if err == nil {
+		t.Error("Expected an unknown role to be rejected")
+	}
+	_, err = cfg.users.GetUserByEmail(ctx, "bad@example.com")
+	if err == nil {
+		t.Error("Expected no user to be created with an unknown role")
+	}
+}
+
+func TestCommandUserSuspend(t *testing.T) {
+	ctx := context.Background()
+	cli, cfg, _ := newTestCommandLine(t, "")
+	user, err := cfg.users.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cli.user(ctx, []string{"suspend", "-email", "alice@example.com", "-duration", "10000h"})
+	if err == nil {
+		t.Error("Expected a suspension longer than a year to be rejected")
+	}
+	err = cli.user(ctx, []string{"suspend", "-email", "alice@example.com", "-duration", "72h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _ = cfg.users.GetUser(ctx, user.ID)
+	if !user.SuspendedAt.Valid || !user.SuspendedUntil.Valid {
+		t.Errorf("Expected a timed suspension, got %+v", user)
+	}
+	actions, err := cfg.reports.GetModerationActionsByUser(ctx, user.ID)
+	if err != nil || len(actions) != 1 {
+		t.Fatalf("Expected 1 moderation action, got %d, %v", len(actions), err)
+	}
+	if actions[0].Action != moderationActionSuspend || actions[0].ModeratorType != actorCLI || actions[0].ModeratorID.Valid {
+		t.Errorf("Expected a suspension by the CLI, got %+v", actions[0])
+	}
+	entries, _ := cfg.auditLog.GetAuditEntries(ctx, database.GetAuditEntriesParams{MaxEntries: 10})
+	if len(entries) != 1 || entries[0].Action != auditModeration || entries[0].ActorType != actorCLI {
+		t.Errorf("Expected the suspension to be audited with the cli actor, got %+v", entries)
+	}
+}
+
+func TestCommandChirpsPurge(t *testing.T) {
+	ctx := context.Background()
+	cli, cfg, stdout := newTestCommandLine(t, "")
+	alice, err := cfg.users.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := cfg.users.CreateUser(ctx, database.CreateUserParams{Email: "bob@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, userID := range []uuid.UUID{alice.ID, alice.ID, bob.ID} {
+		_, err = cfg.chirps.CreateChirp(ctx, database.CreateChirpParams{Body: "hello", UserID: userID})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	endpoint, err := cfg.webhookEndpoints.CreateWebhookEndpoint(ctx, database.CreateWebhookEndpointParams{
+		UserID: uuid.NullUUID{UUID: alice.ID, Valid: true},
+		Url:    "https://alice.example.com",
+		Events: eventChirpDeleted,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cli.chirps(ctx, []string{"purge", "-email", "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "Deleted 2 chirps by alice@example.com") {
+		t.Errorf("Unexpected output %q", stdout)
+	}
+	chirps, _ := cfg.chirps.GetChirps(ctx, database.GetChirpsParams{})
+	if len(chirps) != 1 || chirps[0].UserID != bob.ID {
+		t.Errorf("Expected only bob's chirp to be left, got %+v", chirps)
+	}
+	deliveries, _ := cfg.webhookEndpoints.GetWebhookDeliveriesByEndpoint(ctx, database.GetWebhookDeliveriesByEndpointParams{EndpointID: endpoint.ID, Limit: 10})
+	if len(deliveries) != 2 {
+		t.Fatalf("Expected a chirp.deleted delivery per purged chirp, got %d", len(deliveries))
+	}
+	for _, delivery := range deliveries {
+		if delivery.EventType != eventChirpDeleted {
+			t.Errorf("Expected a %s delivery, got %s", eventChirpDeleted, delivery.EventType)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/acramatte/Chirpy/internal/migrations"
+	"go.opentelemetry.io/otel"
+	"strings"
+)
+
+// memoryDBURL is the DB_URL that keeps everything in memory, for demos. Nothing survives a
+// restart, and only serve can use it.
+const memoryDBURL = "memory:"
+
+// repositories is everything handlers store, backed by *database.Queries or by *memory.Store.
+type repositories interface {
+	database.UserRepository
+	database.ChirpRepository
+	database.RefreshTokenRepository
+	database.SubscriptionRepository
+	database.LoginThrottleRepository
+	database.AuditLogRepository
+	database.PersonalAccessTokenRepository
+	database.OAuthRepository
+	database.IdentityRepository
+	database.WebhookEndpointRepository
+	database.WebhookEventRepository
+	database.ReportRepository
+	database.NotificationRepository
+	database.ModerationWordRepository
+}
+
+// openDatabase opens the database DB_URL points to. A sqlite: URL such as sqlite:chirpy.db or
+// sqlite:///var/lib/chirpy/chirpy.db opens a SQLite file, and anything else is a Postgres URL.
+func openDatabase(dbURL string) (*sql.DB, migrations.Dialect, error) {
+	path, ok := strings.CutPrefix(dbURL, "sqlite:")
+	if !ok {
+		db, err := sql.Open("postgres", dbURL)
+		return db, migrations.Postgres, err
+	}
+	path = strings.TrimPrefix(path, "//")
+	if path == "" {
+		return nil, "", errors.New("DB_URL has no SQLite database path")
+	}
+	db, err := sql.Open(database.SQLiteDriver, "file:"+path+"?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, "", err
+	}
+	// SQLite allows one writer at a time. A single connection queues writes instead of failing
+	// them with "database is locked", and keeps a sqlite::memory: database alive.
+	db.SetMaxOpenConns(1)
+	return db, migrations.SQLite, nil
+}
+
+// queriesDB is what sqlc's queries run against: db itself for Postgres, or db with every query
+// swapped for its SQLite version.
+func queriesDB(db *sql.DB, dialect migrations.Dialect) (database.DBTX, error) {
+	if dialect != migrations.SQLite {
+		return db, nil
+	}
+	return database.NewSQLiteDB(db, sqliteQueries())
+}
+
+// newRepositories returns the repositories backed by db, with every query traced.
+func newRepositories(db *sql.DB, dialect migrations.Dialect) (repositories, error) {
+	dbtx, err := queriesDB(db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	return database.New(database.NewTracingDB(dbtx, otel.Tracer(tracerName))), nil
+}
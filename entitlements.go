@@ -0,0 +1,16 @@
+package main
+
+import (
+	"context"
+	"github.com/acramatte/Chirpy/internal/entitlements"
+	"github.com/google/uuid"
+)
+
+// entitlementsFor returns the perks the user currently has.
+func (cfg *apiConfig) entitlementsFor(ctx context.Context, userID uuid.UUID) (entitlements.Entitlements, error) {
+	isChirpyRed, err := cfg.isChirpyRed(ctx, userID)
+	if err != nil {
+		return entitlements.Entitlements{}, err
+	}
+	return cfg.entitlements.For(isChirpyRed), nil
+}
@@ -0,0 +1,545 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/acramatte/Chirpy/internal/auth"
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/acramatte/Chirpy/internal/profanity"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ModerationWord struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Word      string    `json:"word"`
+	Severity  string    `json:"severity"`
+}
+
+func moderationWordFromDB(word database.ModerationWord) ModerationWord {
+	return ModerationWord{
+		ID:        word.ID,
+		CreatedAt: word.CreatedAt,
+		UpdatedAt: word.UpdatedAt,
+		Word:      word.Word,
+		Severity:  word.Severity,
+	}
+}
+
+// applyWordListChange reloads the filter so changes take effect immediately on this instance.
+// Other instances pick them up on their next periodic reload.
+func (cfg *apiConfig) applyWordListChange(r *http.Request) {
+	err := cfg.reloadProfanityFilter(r.Context())
+	if err != nil {
+		loggerFromContext(r.Context()).Error("Couldn't reload the profanity filter", "err", err)
+	}
+}
+
+func (cfg *apiConfig) handlerModerationWordsList(w http.ResponseWriter, r *http.Request) {
+	dbWords, err := cfg.moderationWords.GetModerationWords(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve moderation words", err)
+		return
+	}
+	words := []ModerationWord{}
+	for _, word := range dbWords {
+		words = append(words, moderationWordFromDB(word))
+	}
+	respondWithJSON(w, http.StatusOK, words)
+}
+
+func (cfg *apiConfig) handlerModerationWordsCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Word     string `json:"word"`
+		Severity string `json:"severity"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	word := strings.TrimSpace(params.Word)
+	if profanity.Normalize(word) == "" {
+		respondWithError(w, http.StatusBadRequest, "A word is required", nil)
+		return
+	}
+	severity, err := profanity.ParseSeverity(params.Severity)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	dbWord, err := cfg.moderationWords.CreateModerationWord(r.Context(), database.CreateModerationWordParams{
+		Word:     word,
+		Severity: string(severity),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusConflict, "Couldn't add moderation word, it may already exist", err)
+		return
+	}
+	cfg.applyWordListChange(r)
+	respondWithJSON(w, http.StatusCreated, moderationWordFromDB(dbWord))
+}
+
+func (cfg *apiConfig) handlerModerationWordUpdate(w http.ResponseWriter, r *http.Request) {
+	wordID, err := uuid.Parse(r.PathValue("wordID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid word ID", err)
+		return
+	}
+	type parameters struct {
+		Severity string `json:"severity"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	severity, err := profanity.ParseSeverity(params.Severity)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	dbWord, err := cfg.moderationWords.UpdateModerationWordSeverity(r.Context(), database.UpdateModerationWordSeverityParams{
+		ID:       wordID,
+		Severity: string(severity),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Moderation word not found", err)
+		return
+	}
+	cfg.applyWordListChange(r)
+	respondWithJSON(w, http.StatusOK, moderationWordFromDB(dbWord))
+}
+
+func (cfg *apiConfig) handlerModerationWordDelete(w http.ResponseWriter, r *http.Request) {
+	wordID, err := uuid.Parse(r.PathValue("wordID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid word ID", err)
+		return
+	}
+	deleted, err := cfg.moderationWords.DeleteModerationWord(r.Context(), wordID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete moderation word", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Moderation word not found", nil)
+		return
+	}
+	cfg.applyWordListChange(r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerFlaggedChirpsList(w http.ResponseWriter, r *http.Request) {
+	dbChirps, err := cfg.chirps.GetFlaggedChirps(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve flagged chirps", err)
+		return
+	}
+	chirps, err := cfg.chirpsFromDB(r.Context(), dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirp authors", err)
+		return
+	}
+	if chirps == nil {
+		chirps = []Chirp{}
+	}
+	respondWithJSON(w, http.StatusOK, chirps)
+}
+
+// Actions moderators can take on a report.
+const (
+	moderationActionDismiss = "dismiss"
+	moderationActionHide    = "hide"
+	moderationActionDelete  = "delete"
+	moderationActionSuspend = "suspend"
+
+	moderationActionUnsuspend   = "unsuspend"
+	moderationActionShadowban   = "shadowban"
+	moderationActionUnshadowban = "unshadowban"
+)
+
+type ModerationAction struct {
+	ID            uuid.UUID  `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ReportID      *uuid.UUID `json:"report_id"`
+	ModeratorType string     `json:"moderator_type"`
+	ModeratorID   *uuid.UUID `json:"moderator_id"`
+	Action        string     `json:"action"`
+	UserID        uuid.UUID  `json:"user_id"`
+	ChirpID       *uuid.UUID `json:"chirp_id"`
+	Note          string     `json:"note"`
+}
+
+func moderationActionFromDB(action database.ModerationAction) ModerationAction {
+	return ModerationAction{
+		ID:            action.ID,
+		CreatedAt:     action.CreatedAt,
+		ReportID:      nullUUIDPtr(action.ReportID),
+		ModeratorType: action.ModeratorType,
+		ModeratorID:   nullUUIDPtr(action.ModeratorID),
+		Action:        action.Action,
+		UserID:        action.UserID,
+		ChirpID:       nullUUIDPtr(action.ChirpID),
+		Note:          action.Note,
+	}
+}
+
+func (cfg *apiConfig) handlerModerationReportsList(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = reportStatusOpen
+	}
+	limit := 50
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		limit = min(n, 500)
+	}
+	dbReports, err := cfg.reports.GetReportsByStatus(r.Context(), database.GetReportsByStatusParams{
+		Status: status,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve reports", err)
+		return
+	}
+	reports := []Report{}
+	for _, report := range dbReports {
+		reports = append(reports, reportFromDB(report))
+	}
+	respondWithJSON(w, http.StatusOK, reports)
+}
+
+func (cfg *apiConfig) handlerModerationReportGet(w http.ResponseWriter, r *http.Request) {
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid report ID", err)
+		return
+	}
+	report, err := cfg.reports.GetReport(r.Context(), reportID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Report not found", err)
+		return
+	}
+	dbActions, err := cfg.reports.GetModerationActionsByReport(r.Context(), uuid.NullUUID{UUID: report.ID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve moderation actions", err)
+		return
+	}
+	type response struct {
+		Report
+		Actions []ModerationAction `json:"actions"`
+	}
+	resp := response{Report: reportFromDB(report), Actions: []ModerationAction{}}
+	for _, action := range dbActions {
+		resp.Actions = append(resp.Actions, moderationActionFromDB(action))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// handlerModerationReportAction applies a moderator's decision to the reported chirp or user. The
+// decision resolves every open report about the same target and each reporter is notified.
+func (cfg *apiConfig) handlerModerationReportAction(w http.ResponseWriter, r *http.Request) {
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid report ID", err)
+		return
+	}
+	type parameters struct {
+		Action        string `json:"action"`
+		Note          string `json:"note"`
+		DurationHours int    `json:"duration_hours"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	report, err := cfg.reports.GetReport(r.Context(), reportID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Report not found", err)
+		return
+	}
+	if report.Status != reportStatusOpen {
+		respondWithError(w, http.StatusConflict, "Report was already resolved", nil)
+		return
+	}
+
+	// The reports are collected before acting, since the action may resolve this one.
+	var related []database.Report
+	if report.ChirpID.Valid {
+		related, err = cfg.reports.GetOpenReportsForChirp(r.Context(), report.ChirpID)
+	} else {
+		related, err = cfg.reports.GetOpenReportsForUser(r.Context(), report.UserID)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve related reports", err)
+		return
+	}
+
+	if params.Action == moderationActionSuspend || params.Action == moderationActionShadowban {
+		target, err := cfg.users.GetUser(r.Context(), report.UserID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "Reported user not found", err)
+			return
+		}
+		if !mayModerateAccount(r.Context(), target) {
+			respondWithError(w, http.StatusForbidden, "Only admins can moderate staff accounts", nil)
+			return
+		}
+	}
+
+	status := reportStatusActioned
+	var outcome string
+	switch params.Action {
+	case moderationActionDismiss:
+		status = reportStatusDismissed
+		outcome = "found that it doesn't break our rules"
+	case moderationActionHide, moderationActionDelete:
+		if !report.ChirpID.Valid {
+			respondWithError(w, http.StatusBadRequest, "Only reported chirps can be hidden or deleted", nil)
+			return
+		}
+		if params.Action == moderationActionHide {
+			_, err = cfg.chirps.HideChirp(r.Context(), report.ChirpID.UUID)
+		} else {
+			var chirp database.Chirp
+			chirp, err = cfg.chirps.GetChirp(r.Context(), report.ChirpID.UUID)
+			if err == nil {
+				err = cfg.chirps.DeleteChirp(r.Context(), chirp.ID)
+			}
+			if err == nil {
+				cfg.emitEvent(r.Context(), eventChirpDeleted, chirp.UserID, chirpEventData(chirp))
+			}
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusConflict, "Reported chirp was already deleted", err)
+			return
+		}
+		outcome = "removed it"
+	case moderationActionSuspend:
+		until, ok := suspensionEnd(w, params.DurationHours)
+		if !ok {
+			return
+		}
+		err = cfg.suspendUser(r.Context(), report.UserID, until)
+		outcome = "suspended the account"
+	case moderationActionShadowban:
+		_, err = cfg.users.ShadowbanUser(r.Context(), report.UserID)
+		outcome = "took action against the account"
+	default:
+		respondWithError(w, http.StatusBadRequest, "Action must be one of dismiss, hide, delete, suspend, shadowban", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't apply moderation action", err)
+		return
+	}
+
+	// Actions taken with the admin API key aren't tied to a user, so no moderator is recorded.
+	moderatorType, moderatorID := staffActor(r.Context())
+	action, err := cfg.reports.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ReportID:      uuid.NullUUID{UUID: report.ID, Valid: true},
+		ModeratorID:   moderatorID,
+		Action:        params.Action,
+		UserID:        report.UserID,
+		ChirpID:       report.ChirpID,
+		Note:          params.Note,
+		ModeratorType: moderatorType,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record moderation action", err)
+		return
+	}
+	cfg.auditModerationAction(r.Context(), action)
+
+	target := "user"
+	if report.ChirpID.Valid {
+		target = "chirp"
+	}
+	for _, related := range related {
+		err = cfg.reports.ResolveReport(r.Context(), database.ResolveReportParams{ID: related.ID, Status: status})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't resolve report", err)
+			return
+		}
+		if !related.ReporterID.Valid {
+			continue
+		}
+		_, err = cfg.notifications.CreateNotification(r.Context(), database.CreateNotificationParams{
+			UserID:  related.ReporterID.UUID,
+			Message: fmt.Sprintf("Thanks for your report. We reviewed the %s you reported and %s.", target, outcome),
+		})
+		if err != nil {
+			loggerFromContext(r.Context()).Warn("Couldn't notify reporter", "report_id", related.ID, "err", err)
+		}
+	}
+	respondWithJSON(w, http.StatusOK, moderationActionFromDB(action))
+}
+
+func (cfg *apiConfig) handlerModerationUserActions(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+	dbActions, err := cfg.reports.GetModerationActionsByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve moderation actions", err)
+		return
+	}
+	actions := []ModerationAction{}
+	for _, action := range dbActions {
+		actions = append(actions, moderationActionFromDB(action))
+	}
+	respondWithJSON(w, http.StatusOK, actions)
+}
+
+// auditModerationAction copies a moderation action into the audit log, with its moderator as the
+// actor. Chirp deletions are additionally recorded as such, like deletions by their author.
+func (cfg *apiConfig) auditModerationAction(ctx context.Context, action database.ModerationAction) {
+	actorType, actorID := action.ModeratorType, action.ModeratorID
+	details := map[string]interface{}{"action": action.Action, "moderation_action_id": action.ID}
+	if action.ReportID.Valid {
+		details["report_id"] = action.ReportID.UUID
+	}
+	cfg.audit(ctx, auditEntry{
+		Action:     auditModeration,
+		ActorType:  actorType,
+		ActorID:    actorID,
+		TargetType: "user",
+		TargetID:   action.UserID.String(),
+		Details:    details,
+	})
+	if action.Action == moderationActionDelete && action.ChirpID.Valid {
+		cfg.audit(ctx, auditEntry{
+			Action:     auditChirpDeleted,
+			ActorType:  actorType,
+			ActorID:    actorID,
+			TargetType: "chirp",
+			TargetID:   action.ChirpID.UUID.String(),
+			Details:    details,
+		})
+	}
+}
+
+// mayModerateAccount reports whether the caller may change the account state of target. Only
+// admins can act on moderators and other admins, so moderators can't lock each other out.
+func mayModerateAccount(ctx context.Context, target database.User) bool {
+	caller, _ := staffFromContext(ctx)
+	return target.Role == auth.RoleUser || auth.HasRole(caller.Role, auth.RoleAdmin)
+}
+
+// maxSuspension is the longest timed suspension. Longer ones are permanent suspensions.
+const maxSuspension = 365 * 24 * time.Hour
+
+// suspensionEnd turns duration_hours into the end of a suspension, where 0 means permanent.
+func suspensionEnd(w http.ResponseWriter, durationHours int) (sql.NullTime, bool) {
+	if durationHours < 0 {
+		respondWithError(w, http.StatusBadRequest, "duration_hours can't be negative", nil)
+		return sql.NullTime{}, false
+	}
+	if durationHours > int(maxSuspension/time.Hour) {
+		msg := fmt.Sprintf("duration_hours can't be more than %d, omit it to suspend permanently", maxSuspension/time.Hour)
+		respondWithError(w, http.StatusBadRequest, msg, nil)
+		return sql.NullTime{}, false
+	}
+	if durationHours == 0 {
+		return sql.NullTime{}, true
+	}
+	until := time.Now().UTC().Add(time.Duration(durationHours) * time.Hour)
+	return sql.NullTime{Time: until, Valid: true}, true
+}
+
+// handlerModerationUserState suspends, unsuspends, shadowbans or unshadowbans a user outside
+// of a report, and records the action in the user's moderation history.
+func (cfg *apiConfig) handlerModerationUserState(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(r.PathValue("userID"))
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+			return
+		}
+		type parameters struct {
+			DurationHours int    `json:"duration_hours"`
+			Note          string `json:"note"`
+		}
+		params := parameters{}
+		if r.ContentLength != 0 {
+			decoder := json.NewDecoder(r.Body)
+			defer r.Body.Close()
+			err = decoder.Decode(&params)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+				return
+			}
+		}
+		target, err := cfg.users.GetUser(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "User not found", err)
+			return
+		}
+		if !mayModerateAccount(r.Context(), target) {
+			respondWithError(w, http.StatusForbidden, "Only admins can moderate staff accounts", nil)
+			return
+		}
+
+		switch action {
+		case moderationActionSuspend:
+			until, ok := suspensionEnd(w, params.DurationHours)
+			if !ok {
+				return
+			}
+			err = cfg.suspendUser(r.Context(), userID, until)
+		case moderationActionUnsuspend:
+			_, err = cfg.users.UnsuspendUser(r.Context(), userID)
+		case moderationActionShadowban:
+			_, err = cfg.users.ShadowbanUser(r.Context(), userID)
+		case moderationActionUnshadowban:
+			_, err = cfg.users.UnshadowbanUser(r.Context(), userID)
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't apply moderation action", err)
+			return
+		}
+
+		moderatorType, moderatorID := staffActor(r.Context())
+		recorded, err := cfg.reports.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+			ModeratorID:   moderatorID,
+			Action:        action,
+			UserID:        userID,
+			Note:          params.Note,
+			ModeratorType: moderatorType,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't record moderation action", err)
+			return
+		}
+		cfg.auditModerationAction(r.Context(), recorded)
+		respondWithJSON(w, http.StatusOK, moderationActionFromDB(recorded))
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSuspensionEnd(t *testing.T) {
+	tests := []struct {
+		name          string
+		durationHours int
+		ok            bool
+		timed         bool
+	}{
+		{"permanent", 0, true, false},
+		{"a day", 24, true, true},
+		{"a year", 8760, true, true},
+		{"negative", -1, false, false},
+		{"more than a year", 8761, false, false},
+		{"overflowing", 1 << 62, false, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			until, ok := suspensionEnd(rec, tc.durationHours)
+			if ok != tc.ok || until.Valid != tc.timed {
+				t.Fatalf("suspensionEnd(%d) = %v, %v", tc.durationHours, until, ok)
+			}
+			if !ok && rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400, got %d", rec.Code)
+			}
+			if until.Valid && !until.Time.After(time.Now()) {
+				t.Errorf("Expected the suspension to end in the future, got %v", until.Time)
+			}
+		})
+	}
+}
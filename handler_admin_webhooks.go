@@ -0,0 +1,113 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type WebhookEvent struct {
+	ID          uuid.UUID       `json:"id"`
+	Provider    string          `json:"provider"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Error       *string         `json:"error"`
+	Attempts    int32           `json:"attempts"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+func webhookEventFromDB(event database.WebhookEvent) WebhookEvent {
+	resp := WebhookEvent{
+		ID:          event.ID,
+		Provider:    event.Provider,
+		EventID:     event.EventID,
+		EventType:   event.EventType,
+		Payload:     json.RawMessage(event.Payload),
+		Status:      event.Status,
+		Attempts:    event.Attempts,
+		ReceivedAt:  event.ReceivedAt,
+		ProcessedAt: nullTimePtr(event.ProcessedAt),
+	}
+	if event.Error.Valid {
+		resp.Error = &event.Error.String
+	}
+	return resp
+}
+
+func (cfg *apiConfig) handlerAdminWebhookEventsList(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 500 {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 500", err)
+			return
+		}
+		limit = n
+	}
+	dbEvents, err := cfg.webhookEvents.ListWebhookEvents(r.Context(), database.ListWebhookEventsParams{
+		Status:     r.URL.Query().Get("status"),
+		MaxResults: int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve webhook events", err)
+		return
+	}
+	events := []WebhookEvent{}
+	for _, event := range dbEvents {
+		events = append(events, webhookEventFromDB(event))
+	}
+	respondWithJSON(w, http.StatusOK, events)
+}
+
+func (cfg *apiConfig) handlerAdminWebhookEventGet(w http.ResponseWriter, r *http.Request) {
+	eventID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid event ID", err)
+		return
+	}
+	event, err := cfg.webhookEvents.GetWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Webhook event not found", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, webhookEventFromDB(event))
+}
+
+func (cfg *apiConfig) handlerAdminWebhookEventReplay(w http.ResponseWriter, r *http.Request) {
+	eventID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid event ID", err)
+		return
+	}
+	claimed, err := cfg.webhookEvents.ClaimWebhookEventForReplay(r.Context(), database.ClaimWebhookEventForReplayParams{
+		ID:          eventID,
+		StaleBefore: time.Now().UTC().Add(-webhookEventLease),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		_, getErr := cfg.webhookEvents.GetWebhookEvent(r.Context(), eventID)
+		if getErr != nil {
+			respondWithError(w, http.StatusNotFound, "Webhook event not found", getErr)
+			return
+		}
+		respondWithError(w, http.StatusConflict, "Webhook event is being processed", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't claim webhook event", err)
+		return
+	}
+	event, err := cfg.runWebhookEvent(r.Context(), claimed)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record webhook outcome", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, webhookEventFromDB(event))
+}
@@ -6,18 +6,68 @@ import (
 	"fmt"
 	"github.com/acramatte/Chirpy/internal/auth"
 	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/acramatte/Chirpy/internal/entitlements"
 	"github.com/google/uuid"
 	"net/http"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID   `json:"id"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	Body      string      `json:"body"`
+	UserID    uuid.UUID   `json:"user_id"`
+	PinnedAt  *time.Time  `json:"pinned_at"`
+	Author    ChirpAuthor `json:"author"`
+}
+
+type ChirpAuthor struct {
+	ID          uuid.UUID `json:"id"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	Badge       string    `json:"badge,omitempty"`
+}
+
+// chirpsFromDB attaches the author's membership badge to each chirp, looking up every author once.
+func (cfg *apiConfig) chirpsFromDB(ctx context.Context, dbChirps []database.Chirp) ([]Chirp, error) {
+	authors := map[uuid.UUID]ChirpAuthor{}
+	var chirps []Chirp
+	for _, dbChirp := range dbChirps {
+		author, ok := authors[dbChirp.UserID]
+		if !ok {
+			isChirpyRed, err := cfg.isChirpyRed(ctx, dbChirp.UserID)
+			if err != nil {
+				return nil, err
+			}
+			author = ChirpAuthor{
+				ID:          dbChirp.UserID,
+				IsChirpyRed: isChirpyRed,
+				Badge:       cfg.entitlements.For(isChirpyRed).Badge,
+			}
+			authors[dbChirp.UserID] = author
+		}
+		chirps = append(chirps, Chirp{
+			ID:        dbChirp.ID,
+			CreatedAt: dbChirp.CreatedAt,
+			UpdatedAt: dbChirp.UpdatedAt,
+			Body:      dbChirp.Body,
+			UserID:    dbChirp.UserID,
+			PinnedAt:  nullTimePtr(dbChirp.PinnedAt),
+			Author:    author,
+		})
+	}
+	return chirps, nil
+}
+
+func (cfg *apiConfig) respondWithChirp(w http.ResponseWriter, r *http.Request, code int, dbChirp database.Chirp) {
+	chirps, err := cfg.chirpsFromDB(r.Context(), []database.Chirp{dbChirp})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirp author", err)
+		return
+	}
+	respondWithJSON(w, code, chirps[0])
 }
 
 func (cfg *apiConfig) handlerChirpGet(w http.ResponseWriter, r *http.Request) {
@@ -27,30 +77,21 @@ func (cfg *apiConfig) handlerChirpGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dbChirp, err := cfg.db.GetChirp(r.Context(), userID)
+	dbChirp, err := cfg.chirps.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+		ID:       userID,
+		ViewerID: cfg.viewerID(r),
+	})
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
 		return
 	}
-	respondWithJSON(w, http.StatusOK, Chirp{
-		ID:        dbChirp.ID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-		Body:      dbChirp.Body,
-		UserID:    dbChirp.UserID,
-	})
+	cfg.respondWithChirp(w, r, http.StatusOK, dbChirp)
 }
 
 func (cfg *apiConfig) handlerChirpDelete(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
-		return
-	}
-
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	userID, err := cfg.authenticate(r, auth.ScopeChirpsWrite)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		respondWithAuthError(w, err)
 		return
 	}
 
@@ -60,7 +101,7 @@ func (cfg *apiConfig) handlerChirpDelete(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	chirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	chirp, err := cfg.chirps.GetChirp(r.Context(), chirpID)
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
 		return
@@ -71,11 +112,20 @@ func (cfg *apiConfig) handlerChirpDelete(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = cfg.db.DeleteChirp(r.Context(), chirpID)
+	err = cfg.chirps.DeleteChirp(r.Context(), chirpID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Couldn't delete chirp %v", chirpID), err)
 		return
 	}
+	cfg.emitEvent(r.Context(), eventChirpDeleted, userID, chirpEventData(chirp))
+	actorType, actorID := userActor(userID)
+	cfg.audit(r.Context(), auditEntry{
+		Action:     auditChirpDeleted,
+		ActorType:  actorType,
+		ActorID:    actorID,
+		TargetType: "chirp",
+		TargetID:   chirpID.String(),
+	})
 	respondWithJSON(w, http.StatusNoContent, struct{}{})
 }
 
@@ -87,28 +137,26 @@ func (cfg *apiConfig) handlerChirpsRetrieve(w http.ResponseWriter, r *http.Reque
 	}
 
 	s := r.URL.Query().Get("author_id")
-	dbChirps, err := cfg.getChirps(r.Context(), s, order)
+	dbChirps, err := cfg.getChirps(r.Context(), s, order, cfg.viewerID(r))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
 		return
 	}
 
-	var chirps []Chirp
-	for _, dbChirp := range dbChirps {
-		chirps = append(chirps, Chirp{
-			ID:        dbChirp.ID,
-			CreatedAt: dbChirp.CreatedAt,
-			UpdatedAt: dbChirp.UpdatedAt,
-			Body:      dbChirp.Body,
-			UserID:    dbChirp.UserID,
-		})
+	chirps, err := cfg.chirpsFromDB(r.Context(), dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirp authors", err)
+		return
 	}
 	respondWithJSON(w, http.StatusOK, chirps)
 }
 
-func (cfg *apiConfig) getChirps(c context.Context, authorId, sortOrder string) ([]database.Chirp, error) {
+func (cfg *apiConfig) getChirps(c context.Context, authorId, sortOrder string, viewerID uuid.NullUUID) ([]database.Chirp, error) {
 	if authorId == "" {
-		return cfg.db.GetChirps(c, sortOrder)
+		return cfg.chirps.GetChirps(c, database.GetChirpsParams{
+			ViewerID:  viewerID,
+			SortOrder: sortOrder,
+		})
 	}
 
 	parsedID, err := uuid.Parse(authorId)
@@ -116,21 +164,17 @@ func (cfg *apiConfig) getChirps(c context.Context, authorId, sortOrder string) (
 		return nil, fmt.Errorf("Couldn't parse user id: %w", err)
 	}
 
-	return cfg.db.GetChirpsByAuthorId(c, database.GetChirpsByAuthorIdParams{
-		UserID:  parsedID,
-		Column2: sortOrder,
+	return cfg.chirps.GetChirpsByAuthorId(c, database.GetChirpsByAuthorIdParams{
+		UserID:    parsedID,
+		ViewerID:  viewerID,
+		SortOrder: sortOrder,
 	})
 }
 
 func (cfg *apiConfig) handlerChirpsCreate(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
+	userID, err := cfg.authenticate(r, auth.ScopeChirpsWrite)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
-		return
-	}
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		respondWithAuthError(w, err)
 		return
 	}
 
@@ -146,33 +190,191 @@ func (cfg *apiConfig) handlerChirpsCreate(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if len(params.Body) > 140 {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
+	perks, err := cfg.entitlementsFor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve entitlements", err)
+		return
+	}
+	recent, err := cfg.chirps.CountChirpsByUserSince(r.Context(), database.CountChirpsByUserSinceParams{
+		UserID:    userID,
+		CreatedAt: time.Now().UTC().Add(-time.Hour),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check chirp rate", err)
+		return
+	}
+	if recent >= int64(perks.ChirpsPerHour) {
+		respondWithError(w, http.StatusTooManyRequests, fmt.Sprintf("You can post at most %d chirps per hour", perks.ChirpsPerHour), nil)
+		return
+	}
+	if !validChirpLength(w, params.Body, perks) {
+		return
+	}
+	moderation, ok := cfg.moderateChirp(w, params.Body)
+	if !ok {
 		return
 	}
-	filteredBody := getCleanedBody(params.Body)
 
-	chirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   filteredBody,
+	chirp, err := cfg.chirps.CreateChirp(r.Context(), database.CreateChirpParams{
+		Body:   moderation.Body,
 		UserID: userID,
 	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+		return
+	}
+	cfg.metrics.chirpsCreated.Inc()
+	if moderation.Flagged {
+		chirp, err = cfg.flagChirp(r.Context(), chirp, moderation.Matches)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't flag chirp for review", err)
+			return
+		}
 	}
+	cfg.emitEvent(r.Context(), eventChirpCreated, userID, chirpEventData(chirp))
+
+	cfg.respondWithChirp(w, r, http.StatusCreated, chirp)
+}
 
-	respondWithJSON(w, http.StatusCreated, Chirp{ID: chirp.ID, CreatedAt: chirp.CreatedAt, UpdatedAt: chirp.UpdatedAt, Body: chirp.Body, UserID: chirp.UserID})
+func validChirpLength(w http.ResponseWriter, body string, perks entitlements.Entitlements) bool {
+	if utf8.RuneCountInString(body) > perks.MaxChirpLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Chirp is too long, the limit is %d characters", perks.MaxChirpLength), nil)
+		return false
+	}
+	return true
 }
 
-func getCleanedBody(body string) string {
-	words := strings.Split(body, " ")
-	var filtered []string
-	for _, word := range words {
-		lowerCase := strings.ToLower(word)
-		if strings.Contains(lowerCase, "kerfuffle") || strings.Contains(lowerCase, "sharbert") || strings.Contains(lowerCase, "fornax") {
-			filtered = append(filtered, "****")
-		} else {
-			filtered = append(filtered, word)
+// ownChirp loads the chirp from the path and checks that it belongs to the user. It responds with
+// an error and returns false otherwise.
+func (cfg *apiConfig) ownChirp(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.Chirp, bool) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return database.Chirp{}, false
+	}
+	chirp, err := cfg.chirps.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return database.Chirp{}, false
+	}
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "Not your chirp", nil)
+		return database.Chirp{}, false
+	}
+	return chirp, true
+}
+
+func (cfg *apiConfig) handlerChirpUpdate(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	chirp, ok := cfg.ownChirp(w, r, userID)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		Body string `json:"body"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	perks, err := cfg.entitlementsFor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve entitlements", err)
+		return
+	}
+	if !perks.CanEditChirps {
+		respondWithError(w, http.StatusForbidden, "Editing chirps requires Chirpy Red", nil)
+		return
+	}
+	if !validChirpLength(w, params.Body, perks) {
+		return
+	}
+	moderation, ok := cfg.moderateChirp(w, params.Body)
+	if !ok {
+		return
+	}
+
+	updated, err := cfg.chirps.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		ID:   chirp.ID,
+		Body: moderation.Body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+	if moderation.Flagged {
+		updated, err = cfg.flagChirp(r.Context(), updated, moderation.Matches)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't flag chirp for review", err)
+			return
 		}
 	}
-	return strings.Join(filtered, " ")
+	cfg.emitEvent(r.Context(), eventChirpUpdated, userID, chirpEventData(updated))
+	cfg.respondWithChirp(w, r, http.StatusOK, updated)
+}
+
+func (cfg *apiConfig) handlerChirpPin(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	chirp, ok := cfg.ownChirp(w, r, userID)
+	if !ok {
+		return
+	}
+	if chirp.PinnedAt.Valid {
+		cfg.respondWithChirp(w, r, http.StatusOK, chirp)
+		return
+	}
+
+	perks, err := cfg.entitlementsFor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve entitlements", err)
+		return
+	}
+	pinned, err := cfg.chirps.CountPinnedChirpsByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't count pinned chirps", err)
+		return
+	}
+	if pinned >= int64(perks.MaxPinnedChirps) {
+		respondWithError(w, http.StatusForbidden, fmt.Sprintf("You can pin at most %d chirps", perks.MaxPinnedChirps), nil)
+		return
+	}
+
+	chirp, err = cfg.chirps.PinChirp(r.Context(), chirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't pin chirp", err)
+		return
+	}
+	cfg.respondWithChirp(w, r, http.StatusOK, chirp)
+}
+
+func (cfg *apiConfig) handlerChirpUnpin(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	chirp, ok := cfg.ownChirp(w, r, userID)
+	if !ok {
+		return
+	}
+	chirp, err = cfg.chirps.UnpinChirp(r.Context(), chirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unpin chirp", err)
+		return
+	}
+	cfg.respondWithChirp(w, r, http.StatusOK, chirp)
 }
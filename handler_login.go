@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"github.com/acramatte/Chirpy/internal/auth"
 	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
 	"net/http"
 	"time"
 )
@@ -23,18 +27,101 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := cfg.db.GetUserByEmail(r.Context(), params.Email)
+	emailKey := emailThrottleKey(params.Email)
+	ipKey := ipThrottleKey(cfg.clientIP(r))
+	lockout, err := cfg.loginLockout(r.Context(), emailKey, ipKey)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "User not found", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check login attempts", err)
 		return
 	}
-	err = auth.CheckPasswordHash(params.Password, user.HashedPassword)
+	if lockout > 0 {
+		cfg.audit(r.Context(), auditEntry{
+			Action:    auditLoginFailed,
+			ActorType: actorAnonymous,
+			Details:   map[string]interface{}{"email": params.Email, "reason": "locked_out"},
+		})
+		cfg.metrics.logins.WithLabelValues("password", "locked_out").Inc()
+		respondWithLockout(w, lockout)
+		return
+	}
+
+	// Unknown emails and password-less accounts go through the same hashing work and the same
+	// response as a wrong password so that the endpoint doesn't reveal which accounts exist.
+	user, err := cfg.users.GetUserByEmail(r.Context(), params.Email)
+	if err == nil && user.HashedPassword.Valid {
+		err = auth.CheckPasswordHash(params.Password, user.HashedPassword.String)
+	} else {
+		auth.CheckDummyPassword(params.Password)
+		if err == nil {
+			err = errors.New("user has no password, sign in with OIDC")
+		}
+	}
+	if err != nil {
+		for key, policy := range map[string]auth.LoginThrottle{emailKey: emailLoginThrottle, ipKey: ipLoginThrottle} {
+			recordErr := cfg.recordLoginFailure(r.Context(), key, policy)
+			if recordErr != nil {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't record login attempt", recordErr)
+				return
+			}
+		}
+		entry := auditEntry{
+			Action:    auditLoginFailed,
+			ActorType: actorAnonymous,
+			Details:   map[string]interface{}{"email": params.Email, "reason": "invalid_credentials"},
+		}
+		if user.ID != uuid.Nil {
+			entry.TargetType, entry.TargetID = "user", user.ID.String()
+		}
+		cfg.audit(r.Context(), entry)
+		cfg.metrics.logins.WithLabelValues("password", "invalid_credentials").Inc()
+		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+		return
+	}
+
+	_, err = cfg.loginThrottles.ClearLoginThrottle(r.Context(), emailKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reset login attempts", err)
+		return
+	}
+	if auth.NeedsRehash(user.HashedPassword.String) {
+		cfg.rehashPassword(r.Context(), user.ID, params.Password)
+	}
+	cfg.respondWithSession(w, r, user, "password")
+}
+
+// rehashPassword upgrades a legacy or outdated hash while the plaintext password is at hand.
+// Failing to do so doesn't affect the login; it is retried on the next one.
+func (cfg *apiConfig) rehashPassword(ctx context.Context, userID uuid.UUID, password string) {
+	hashedPassword, err := auth.HashPassword(password)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		loggerFromContext(ctx).Error("Couldn't rehash password", "err", err)
 		return
 	}
+	err = cfg.users.UpdatePasswordHash(ctx, database.UpdatePasswordHashParams{
+		ID:             userID,
+		HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
+	})
+	if err != nil {
+		loggerFromContext(ctx).Error("Couldn't store rehashed password", "err", err)
+	}
+}
 
-	jwt, err := auth.MakeJWT(user.ID, cfg.jwtSecret, time.Hour)
+// respondWithSession issues a new access token and refresh token pair for a user who just
+// authenticated with the given method.
+func (cfg *apiConfig) respondWithSession(w http.ResponseWriter, r *http.Request, user database.User, method string) {
+	if isSuspended(user, time.Now().UTC()) {
+		cfg.audit(r.Context(), auditEntry{
+			Action:     auditLoginFailed,
+			ActorType:  actorAnonymous,
+			TargetType: "user",
+			TargetID:   user.ID.String(),
+			Details:    map[string]interface{}{"method": method, "reason": "suspended"},
+		})
+		cfg.metrics.logins.WithLabelValues(method, "suspended").Inc()
+		respondWithError(w, http.StatusForbidden, suspensionMessage(user), nil)
+		return
+	}
+	jwt, err := auth.MakeJWT(user.ID, cfg.config.JWTSecret, cfg.config.AccessTokenTTL)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create a JWT", err)
 		return
@@ -44,16 +131,31 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create a refresh token", err)
 		return
 	}
-	_, err = cfg.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+	_, err = cfg.refreshTokens.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
 		Token:     refreshToken,
 		UserID:    user.ID,
-		ExpiresAt: time.Now().UTC().AddDate(0, 0, 60),
+		ExpiresAt: time.Now().UTC().Add(cfg.config.RefreshTokenTTL),
 	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't persist a refresh token", err)
 		return
 	}
-	respondWithJSON(w, http.StatusOK, User{ID: user.ID, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, Email: user.Email, IsChirpyRed: user.IsChirpyRed, Token: jwt, RefreshToken: refreshToken})
+	actorType, actorID := userActor(user.ID)
+	cfg.audit(r.Context(), auditEntry{
+		Action:     auditLogin,
+		ActorType:  actorType,
+		ActorID:    actorID,
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+		Details:    map[string]interface{}{"method": method},
+	})
+	cfg.metrics.logins.WithLabelValues(method, "success").Inc()
+	isChirpyRed, err := cfg.isChirpyRed(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve subscription", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, User{ID: user.ID, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, Email: user.Email, IsChirpyRed: isChirpyRed, Token: jwt, RefreshToken: refreshToken})
 }
 
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
@@ -62,12 +164,16 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "No token found", err)
 		return
 	}
-	user, err := cfg.db.GetUserFromRefreshToken(r.Context(), refreshToken)
+	user, err := cfg.refreshTokens.GetUserFromRefreshToken(r.Context(), refreshToken)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token", err)
 		return
 	}
-	accessToken, err := auth.MakeJWT(user.ID, cfg.jwtSecret, time.Hour)
+	if isSuspended(user, time.Now().UTC()) {
+		respondWithError(w, http.StatusForbidden, suspensionMessage(user), nil)
+		return
+	}
+	accessToken, err := auth.MakeJWT(user.ID, cfg.config.JWTSecret, cfg.config.AccessTokenTTL)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Couldn't create a JWT", err)
 		return
@@ -84,10 +190,21 @@ func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "No token found", err)
 		return
 	}
-	err = cfg.db.RevokeToken(r.Context(), refreshToken)
+	// The owner is looked up first for the audit log; revoking an unknown token is still fine.
+	entry := auditEntry{
+		Action:    auditTokenRevoked,
+		ActorType: actorAnonymous,
+		Details:   map[string]interface{}{"token_type": "refresh_token"},
+	}
+	if user, err := cfg.refreshTokens.GetUserFromRefreshToken(r.Context(), refreshToken); err == nil {
+		entry.ActorType, entry.ActorID = userActor(user.ID)
+		entry.TargetType, entry.TargetID = "user", user.ID.String()
+	}
+	err = cfg.refreshTokens.RevokeToken(r.Context(), refreshToken)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the token", err)
 		return
 	}
+	cfg.audit(r.Context(), entry)
 	respondWithJSON(w, http.StatusNoContent, struct{}{})
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"github.com/acramatte/Chirpy/internal/auth"
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/acramatte/Chirpy/internal/oidc"
+	"net/http"
+	"time"
+)
+
+const (
+	oidcStateCookie   = "chirpy_oidc_state"
+	oidcStateLifetime = 10 * time.Minute
+)
+
+func (cfg *apiConfig) handlerLoginOIDC(w http.ResponseWriter, r *http.Request) {
+	if cfg.oidcProvider == nil {
+		respondWithError(w, http.StatusNotFound, "OIDC login is not configured", nil)
+		return
+	}
+
+	state := oidc.LoginState{}
+	for _, value := range []*string{&state.State, &state.Nonce, &state.CodeVerifier} {
+		random, err := auth.MakeRefreshToken()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't start OIDC login", err)
+			return
+		}
+		*value = random
+	}
+	sealed, err := oidc.SealState(state, cfg.config.JWTSecret, oidcStateLifetime)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start OIDC login", err)
+		return
+	}
+	authURL, err := cfg.oidcProvider.AuthCodeURL(r.Context(), state.State, state.Nonce, auth.S256Challenge(state.CodeVerifier))
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Couldn't reach the identity provider", err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    sealed,
+		Path:     "/api/login/oidc",
+		MaxAge:   int(oidcStateLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (cfg *apiConfig) handlerLoginOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if cfg.oidcProvider == nil {
+		respondWithError(w, http.StatusNotFound, "OIDC login is not configured", nil)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Missing OIDC login state", err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/api/login/oidc", MaxAge: -1})
+	state, err := oidc.OpenState(cookie.Value, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid OIDC login state", err)
+		return
+	}
+	query := r.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state.State)) != 1 {
+		respondWithError(w, http.StatusBadRequest, "OIDC state mismatch", nil)
+		return
+	}
+	if providerErr := query.Get("error"); providerErr != "" {
+		respondWithError(w, http.StatusUnauthorized, "Identity provider refused the login", errors.New(providerErr))
+		return
+	}
+
+	claims, err := cfg.oidcProvider.Exchange(r.Context(), query.Get("code"), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+	user, err := cfg.userForIdentity(r, claims)
+	if errors.Is(err, errUnverifiedEmail) {
+		respondWithError(w, http.StatusForbidden, "The identity provider has not verified this email", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't sign in with OIDC", err)
+		return
+	}
+	cfg.respondWithSession(w, r, user, "oidc")
+}
+
+var errUnverifiedEmail = errors.New("email not verified by identity provider")
+
+// userForIdentity returns the user linked to the external identity. Unknown identities are linked by
+// verified email to an existing account, or to a new password-less account.
+func (cfg *apiConfig) userForIdentity(r *http.Request, claims *oidc.IDTokenClaims) (database.User, error) {
+	user, err := cfg.identities.GetUserByIdentity(r.Context(), database.GetUserByIdentityParams{
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+	})
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		return database.User{}, errUnverifiedEmail
+	}
+
+	user, err = cfg.users.GetUserByEmail(r.Context(), claims.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		user, err = cfg.users.CreateUser(r.Context(), database.CreateUserParams{
+			Email:          claims.Email,
+			HashedPassword: sql.NullString{},
+		})
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+	_, err = cfg.identities.CreateUserIdentity(r.Context(), database.CreateUserIdentityParams{
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+		UserID:  user.ID,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return user, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/acramatte/Chirpy/internal/config"
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/acramatte/Chirpy/internal/entitlements"
+	"github.com/acramatte/Chirpy/internal/memory"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+// newTestAPIConfig returns an apiConfig whose repositories are backed by an in-memory store.
+func newTestAPIConfig(t *testing.T) (*apiConfig, *memory.Store) {
+	t.Helper()
+	conf := config.Default()
+	conf.JWTSecret = "test-secret"
+	store := memory.New()
+	cfg := &apiConfig{
+		config:       conf,
+		entitlements: entitlements.Default,
+		metrics:      newServerMetrics(nil),
+	}
+	cfg.useRepositories(store)
+	return cfg, store
+}
+
+// serve runs a handler on a request with an optional JSON body and bearer token.
+func serve(t *testing.T, handler http.HandlerFunc, method, path string, body interface{}, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		err := json.NewEncoder(&buf).Encode(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestLoginSessionOnMemoryStore(t *testing.T) {
+	cfg, store := newTestAPIConfig(t)
+	credentials := map[string]string{"email": "alice@example.com", "password": "correct horse battery"}
+
+	rec := serve(t, cfg.handlerUsersCreation, "POST", "/api/users", credentials, "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Signup returned %d: %s", rec.Code, rec.Body)
+	}
+	var created User
+	err := json.NewDecoder(rec.Body).Decode(&created)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrong := map[string]string{"email": "alice@example.com", "password": "wrong password"}
+	rec = serve(t, cfg.handlerLogin, "POST", "/api/login", wrong, "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Login with a wrong password returned %d", rec.Code)
+	}
+	throttle, err := store.GetLoginThrottle(context.Background(), emailThrottleKey("alice@example.com"))
+	if err != nil || throttle.Failures != 1 {
+		t.Errorf("Expected the failed login to be counted, got %+v, %v", throttle, err)
+	}
+
+	rec = serve(t, cfg.handlerLogin, "POST", "/api/login", credentials, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Login returned %d: %s", rec.Code, rec.Body)
+	}
+	var session User
+	err = json.NewDecoder(rec.Body).Decode(&session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.ID != created.ID || session.Token == "" || session.RefreshToken == "" {
+		t.Errorf("Expected a session for %s, got %+v", created.ID, session)
+	}
+
+	_, err = store.CreateChirp(context.Background(), database.CreateChirpParams{Body: "Hello from memory", UserID: created.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = serve(t, cfg.handlerChirpsRetrieve, "GET", "/api/chirps", nil, session.Token)
+	var chirps []Chirp
+	err = json.NewDecoder(rec.Body).Decode(&chirps)
+	if err != nil || rec.Code != http.StatusOK || len(chirps) != 1 || chirps[0].Body != "Hello from memory" {
+		t.Errorf("Chirps returned %d: %+v, %v", rec.Code, chirps, err)
+	}
+
+	rec = serve(t, cfg.handlerRefresh, "POST", "/api/refresh", nil, session.RefreshToken)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Refresh returned %d: %s", rec.Code, rec.Body)
+	}
+	rec = serve(t, cfg.handlerRevoke, "POST", "/api/revoke", nil, session.RefreshToken)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Revoke returned %d: %s", rec.Code, rec.Body)
+	}
+	rec = serve(t, cfg.handlerRefresh, "POST", "/api/refresh", nil, session.RefreshToken)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Refresh with a revoked token returned %d", rec.Code)
+	}
+
+	entries, err := store.GetAuditEntries(context.Background(), database.GetAuditEntriesParams{MaxEntries: 10})
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("Expected 3 audit entries, got %d, %v", len(entries), err)
+	}
+	for i, action := range []string{auditLoginFailed, auditLogin, auditTokenRevoked} {
+		if entries[i].Action != action {
+			t.Errorf("Expected audit entry %d to be %s, got %s", i, action, entries[i].Action)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	cfg, _ := newTestAPIConfig(t)
+	cfg.trustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		expected   string
+	}{
+		{"direct", "203.0.113.7:4321", nil, "203.0.113.7"},
+		{"forged by a client", "203.0.113.7:4321", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.1:4321", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed hop before the proxy", "10.0.0.1:4321", []string{"192.0.2.99, 198.51.100.1"}, "198.51.100.1"},
+		{"chain of proxies", "10.0.0.1:4321", []string{"198.51.100.1, 10.0.0.2", "10.0.0.3"}, "198.51.100.1"},
+		{"proxy without header", "10.0.0.1:4321", nil, "10.0.0.1"},
+		{"garbage header", "10.0.0.1:4321", []string{"198.51.100.1, unknown"}, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/login", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if got := cfg.clientIP(req); got != tt.expected {
+				t.Errorf("clientIP() = %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+
+	cfg.trustedProxies = nil
+	req := httptest.NewRequest("POST", "/api/login", nil)
+	req.RemoteAddr = "10.0.0.1:4321"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	if got := cfg.clientIP(req); got != "10.0.0.1" {
+		t.Errorf("Expected X-Forwarded-For to be ignored without trusted proxies, got %s", got)
+	}
+}
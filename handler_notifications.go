@@ -0,0 +1,73 @@
+package main
+
+import (
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Notification struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Message   string     `json:"message"`
+	ReadAt    *time.Time `json:"read_at"`
+}
+
+func (cfg *apiConfig) handlerNotificationsList(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.sessionUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+	limit := 50
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		limit = min(n, 500)
+	}
+	dbNotifications, err := cfg.notifications.GetNotificationsByUser(r.Context(), database.GetNotificationsByUserParams{
+		UserID: userID,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve notifications", err)
+		return
+	}
+	notifications := []Notification{}
+	for _, n := range dbNotifications {
+		notifications = append(notifications, Notification{
+			ID:        n.ID,
+			CreatedAt: n.CreatedAt,
+			Message:   n.Message,
+			ReadAt:    nullTimePtr(n.ReadAt),
+		})
+	}
+	respondWithJSON(w, http.StatusOK, notifications)
+}
+
+func (cfg *apiConfig) handlerNotificationRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.sessionUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+	notificationID, err := uuid.Parse(r.PathValue("notificationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid notification ID", err)
+		return
+	}
+	_, err = cfg.notifications.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ID:     notificationID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark notification as read", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,541 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/acramatte/Chirpy/internal/auth"
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	oauthCodeLifetime         = 10 * time.Minute
+	oauthAccessTokenLifetime  = time.Hour
+	oauthRefreshTokenLifetime = 60 * 24 * time.Hour
+)
+
+type OAuthClient struct {
+	ID           uuid.UUID `json:"client_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	Secret       string    `json:"client_secret,omitempty"`
+}
+
+func oauthClientFromDB(client database.OauthClient) OAuthClient {
+	return OAuthClient{
+		ID:           client.ID,
+		CreatedAt:    client.CreatedAt,
+		Name:         client.Name,
+		RedirectURIs: strings.Fields(client.RedirectUris),
+		Scopes:       strings.Fields(client.Scopes),
+		Confidential: client.SecretHash.Valid,
+	}
+}
+
+// respondWithOAuthError writes an RFC 6749 section 5.2 error response.
+func respondWithOAuthError(w http.ResponseWriter, code int, oauthErr, description string, err error) {
+	if err != nil {
+		if rec, ok := w.(errorRecorder); ok {
+			rec.setError(err)
+		} else {
+			slog.Error("OAuth request failed", "status", code, "err", err)
+		}
+	}
+	type errorResponse struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, code, errorResponse{Error: oauthErr, ErrorDescription: description})
+}
+
+func (cfg *apiConfig) handlerOAuthClientsCreate(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.sessionUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	type parameters struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		Confidential bool     `json:"confidential"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if params.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "Client name is required", nil)
+		return
+	}
+	if len(params.RedirectURIs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one redirect URI is required", nil)
+		return
+	}
+	for _, redirectURI := range params.RedirectURIs {
+		u, err := url.Parse(redirectURI)
+		if err != nil || !u.IsAbs() || u.Fragment != "" || strings.ContainsAny(redirectURI, " ") {
+			respondWithError(w, http.StatusBadRequest, "Invalid redirect URI", err)
+			return
+		}
+	}
+	scopes, err := auth.ParseScopes(strings.Join(params.Scopes, " "))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if len(scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one scope is required", nil)
+		return
+	}
+
+	secret := ""
+	secretHash := sql.NullString{}
+	if params.Confidential {
+		secret, err = auth.MakeRefreshToken()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create a client secret", err)
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+
+	client, err := cfg.oauth.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		OwnerID:      userID,
+		Name:         params.Name,
+		SecretHash:   secretHash,
+		RedirectUris: strings.Join(params.RedirectURIs, " "),
+		Scopes:       strings.Join(scopes, " "),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't register client", err)
+		return
+	}
+	resp := oauthClientFromDB(client)
+	resp.Secret = secret
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *apiConfig) handlerOAuthClientsList(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.sessionUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+	dbClients, err := cfg.oauth.GetOAuthClientsByOwner(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve clients", err)
+		return
+	}
+	clients := []OAuthClient{}
+	for _, client := range dbClients {
+		clients = append(clients, oauthClientFromDB(client))
+	}
+	respondWithJSON(w, http.StatusOK, clients)
+}
+
+func (cfg *apiConfig) handlerOAuthClientDelete(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.sessionUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+	clientID, err := uuid.Parse(r.PathValue("clientID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid client ID", err)
+		return
+	}
+	deleted, err := cfg.oauth.DeleteOAuthClient(r.Context(), database.DeleteOAuthClientParams{
+		ID:      clientID,
+		OwnerID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete client", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Client not found", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type authorizationRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+// validateAuthorizationRequest checks the request against the registered client and returns
+// the client together with the scopes that will be granted.
+func (cfg *apiConfig) validateAuthorizationRequest(r *http.Request, req authorizationRequest) (database.OauthClient, []string, error) {
+	clientID, err := uuid.Parse(req.ClientID)
+	if err != nil {
+		return database.OauthClient{}, nil, errors.New("invalid client_id")
+	}
+	client, err := cfg.oauth.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		return database.OauthClient{}, nil, errors.New("unknown client")
+	}
+	if !slices.Contains(strings.Fields(client.RedirectUris), req.RedirectURI) {
+		return database.OauthClient{}, nil, errors.New("redirect_uri is not registered for this client")
+	}
+	if req.ResponseType != "code" {
+		return database.OauthClient{}, nil, errors.New("response_type must be code")
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != auth.PKCEMethodS256 {
+		return database.OauthClient{}, nil, errors.New("PKCE with code_challenge_method S256 is required")
+	}
+
+	requested := req.Scope
+	if requested == "" {
+		requested = client.Scopes
+	}
+	scopes, err := auth.ParseScopes(requested)
+	if err != nil {
+		return database.OauthClient{}, nil, err
+	}
+	for _, scope := range scopes {
+		if !auth.HasScope(client.Scopes, scope) {
+			return database.OauthClient{}, nil, errors.New("scope not allowed for this client: " + scope)
+		}
+	}
+	return client, scopes, nil
+}
+
+// handlerOAuthAuthorizeInfo describes a pending authorization request so the front-end can render a consent screen.
+func (cfg *apiConfig) handlerOAuthAuthorizeInfo(w http.ResponseWriter, r *http.Request) {
+	_, err := cfg.sessionUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+	query := r.URL.Query()
+	req := authorizationRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+	client, scopes, err := cfg.validateAuthorizationRequest(r, req)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+		return
+	}
+
+	type response struct {
+		ClientID    uuid.UUID `json:"client_id"`
+		ClientName  string    `json:"client_name"`
+		RedirectURI string    `json:"redirect_uri"`
+		Scopes      []string  `json:"scopes"`
+		State       string    `json:"state"`
+	}
+	respondWithJSON(w, http.StatusOK, response{
+		ClientID:    client.ID,
+		ClientName:  client.Name,
+		RedirectURI: req.RedirectURI,
+		Scopes:      scopes,
+		State:       req.State,
+	})
+}
+
+// handlerOAuthAuthorize records the user's consent decision and returns the redirect the client expects.
+func (cfg *apiConfig) handlerOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.sessionUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	type parameters struct {
+		authorizationRequest
+		Approve bool `json:"approve"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	client, scopes, err := cfg.validateAuthorizationRequest(r, params.authorizationRequest)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+		return
+	}
+
+	redirect, err := url.Parse(params.RedirectURI)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "invalid redirect_uri", err)
+		return
+	}
+	values := redirect.Query()
+	if params.State != "" {
+		values.Set("state", params.State)
+	}
+
+	if !params.Approve {
+		values.Set("error", "access_denied")
+	} else {
+		code, err := auth.MakeRefreshToken()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create an authorization code", err)
+			return
+		}
+		err = cfg.oauth.CreateOAuthAuthorizationCode(r.Context(), database.CreateOAuthAuthorizationCodeParams{
+			CodeHash:            auth.HashToken(code),
+			ClientID:            client.ID,
+			UserID:              userID,
+			RedirectUri:         params.RedirectURI,
+			Scopes:              strings.Join(scopes, " "),
+			CodeChallenge:       params.CodeChallenge,
+			CodeChallengeMethod: params.CodeChallengeMethod,
+			ExpiresAt:           time.Now().UTC().Add(oauthCodeLifetime),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't persist the authorization code", err)
+			return
+		}
+		values.Set("code", code)
+	}
+	redirect.RawQuery = values.Encode()
+
+	type response struct {
+		RedirectTo string `json:"redirect_to"`
+	}
+	respondWithJSON(w, http.StatusOK, response{RedirectTo: redirect.String()})
+}
+
+// authenticateOAuthClient identifies the client from HTTP Basic credentials or form fields.
+// Public clients have no secret and are identified by client_id alone.
+func (cfg *apiConfig) authenticateOAuthClient(r *http.Request) (database.OauthClient, error) {
+	clientIDString, secret, hasBasic := r.BasicAuth()
+	if !hasBasic {
+		clientIDString = r.PostFormValue("client_id")
+		secret = r.PostFormValue("client_secret")
+	}
+	clientID, err := uuid.Parse(clientIDString)
+	if err != nil {
+		return database.OauthClient{}, errors.New("invalid client_id")
+	}
+	client, err := cfg.oauth.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		return database.OauthClient{}, errors.New("unknown client")
+	}
+	if client.SecretHash.Valid {
+		if subtle.ConstantTimeCompare([]byte(auth.HashToken(secret)), []byte(client.SecretHash.String)) != 1 {
+			return database.OauthClient{}, errors.New("invalid client credentials")
+		}
+	}
+	return client, nil
+}
+
+func (cfg *apiConfig) handlerOAuthToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "couldn't parse form", err)
+		return
+	}
+	client, err := cfg.authenticateOAuthClient(r)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error(), nil)
+		return
+	}
+
+	var userID uuid.UUID
+	var scope string
+	switch r.PostFormValue("grant_type") {
+	case "authorization_code":
+		code, err := cfg.oauth.ConsumeOAuthAuthorizationCode(r.Context(), auth.HashToken(r.PostFormValue("code")))
+		if err != nil {
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid or expired authorization code", err)
+			return
+		}
+		if code.ClientID != client.ID || code.RedirectUri != r.PostFormValue("redirect_uri") {
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "authorization code was issued to another client or redirect_uri", nil)
+			return
+		}
+		err = auth.VerifyPKCE(r.PostFormValue("code_verifier"), code.CodeChallenge, code.CodeChallengeMethod)
+		if err != nil {
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", err.Error(), nil)
+			return
+		}
+		userID, scope = code.UserID, code.Scopes
+	case "refresh_token":
+		refreshToken := r.PostFormValue("refresh_token")
+		// Refresh tokens are rotated on every use, so using one revokes it.
+		stored, err := cfg.refreshTokens.ConsumeOAuthRefreshToken(r.Context(), database.ConsumeOAuthRefreshTokenParams{
+			Token:    refreshToken,
+			ClientID: uuid.NullUUID{UUID: client.ID, Valid: true},
+		})
+		if err != nil {
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid or expired refresh token", err)
+			return
+		}
+		userID, scope = stored.UserID, stored.Scopes.String
+	default:
+		respondWithOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "", nil)
+		return
+	}
+	err = cfg.checkAccountActive(r.Context(), userID)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "the account can't be used", err)
+		return
+	}
+
+	accessToken, err := auth.MakeScopedJWT(userID, cfg.config.JWTSecret, oauthAccessTokenLifetime, client.ID.String(), scope)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "couldn't create an access token", err)
+		return
+	}
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "couldn't create a refresh token", err)
+		return
+	}
+	_, err = cfg.refreshTokens.CreateOAuthRefreshToken(r.Context(), database.CreateOAuthRefreshTokenParams{
+		Token:     refreshToken,
+		UserID:    userID,
+		ExpiresAt: time.Now().UTC().Add(oauthRefreshTokenLifetime),
+		ClientID:  uuid.NullUUID{UUID: client.ID, Valid: true},
+		Scopes:    sql.NullString{String: scope, Valid: true},
+	})
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "couldn't persist the refresh token", err)
+		return
+	}
+
+	type response struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, response{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenLifetime.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        scope,
+	})
+}
+
+// handlerOAuthIntrospect implements RFC 7662. Clients can only introspect tokens issued to themselves.
+func (cfg *apiConfig) handlerOAuthIntrospect(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "couldn't parse form", err)
+		return
+	}
+	client, err := cfg.authenticateOAuthClient(r)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error(), nil)
+		return
+	}
+
+	type response struct {
+		Active    bool   `json:"active"`
+		Scope     string `json:"scope,omitempty"`
+		ClientID  string `json:"client_id,omitempty"`
+		Subject   string `json:"sub,omitempty"`
+		ExpiresAt int64  `json:"exp,omitempty"`
+		TokenType string `json:"token_type,omitempty"`
+	}
+	token := r.PostFormValue("token")
+
+	claims, err := auth.ParseJWT(token, cfg.config.JWTSecret)
+	if err == nil && claims.ClientID == client.ID.String() {
+		// Access tokens of suspended users are rejected by the API until they expire.
+		userID, err := claims.UserID()
+		if err == nil {
+			err = cfg.checkAccountActive(r.Context(), userID)
+		}
+		if err != nil {
+			respondWithJSON(w, http.StatusOK, response{Active: false})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, response{
+			Active:    true,
+			Scope:     claims.Scope,
+			ClientID:  claims.ClientID,
+			Subject:   claims.Subject,
+			ExpiresAt: claims.ExpiresAt.Unix(),
+			TokenType: "access_token",
+		})
+		return
+	}
+	stored, err := cfg.refreshTokens.GetActiveOAuthRefreshToken(r.Context(), database.GetActiveOAuthRefreshTokenParams{
+		Token:    token,
+		ClientID: uuid.NullUUID{UUID: client.ID, Valid: true},
+	})
+	if err == nil {
+		respondWithJSON(w, http.StatusOK, response{
+			Active:    true,
+			Scope:     stored.Scopes.String,
+			ClientID:  client.ID.String(),
+			Subject:   stored.UserID.String(),
+			ExpiresAt: stored.ExpiresAt.Unix(),
+			TokenType: "refresh_token",
+		})
+		return
+	}
+	respondWithJSON(w, http.StatusOK, response{Active: false})
+}
+
+// handlerOAuthRevoke implements RFC 7009. Access tokens are stateless JWTs and simply expire,
+// so only refresh tokens can be revoked; unknown tokens are still answered with 200 as the RFC requires.
+func (cfg *apiConfig) handlerOAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "couldn't parse form", err)
+		return
+	}
+	client, err := cfg.authenticateOAuthClient(r)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error(), nil)
+		return
+	}
+	err = cfg.refreshTokens.RevokeOAuthRefreshToken(r.Context(), database.RevokeOAuthRefreshTokenParams{
+		Token:    r.PostFormValue("token"),
+		ClientID: uuid.NullUUID{UUID: client.ID, Valid: true},
+	})
+	if err != nil {
+		respondWithOAuthError(w, http.StatusServiceUnavailable, "server_error", "couldn't revoke the token", err)
+		return
+	}
+	cfg.audit(r.Context(), auditEntry{
+		Action:     auditTokenRevoked,
+		ActorType:  actorOAuthClient,
+		TargetType: "oauth_client",
+		TargetID:   client.ID.String(),
+		Details:    map[string]interface{}{"token_type": "oauth_refresh_token"},
+	})
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Reasons users can give when reporting a chirp or a user.
+var reportReasons = []string{"spam", "harassment", "hate", "violence", "sexual_content", "misinformation", "other"}
+
+// reportReasonProhibitedWords is used for reports filed by the profanity filter.
+const reportReasonProhibitedWords = "prohibited_words"
+
+const (
+	reportStatusOpen      = "open"
+	reportStatusDismissed = "dismissed"
+	reportStatusActioned  = "actioned"
+
+	maxReportDetailsLength = 1000
+)
+
+type Report struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReporterID *uuid.UUID `json:"reporter_id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	ChirpID    *uuid.UUID `json:"chirp_id"`
+	// ChirpBody is the body of the chirp when it was reported, which stays available after the
+	// chirp is edited or deleted.
+	ChirpBody  string     `json:"chirp_body,omitempty"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+func reportFromDB(report database.Report) Report {
+	return Report{
+		ID:         report.ID,
+		CreatedAt:  report.CreatedAt,
+		ReporterID: nullUUIDPtr(report.ReporterID),
+		UserID:     report.UserID,
+		ChirpID:    nullUUIDPtr(report.ChirpID),
+		ChirpBody:  report.ChirpBody,
+		Reason:     report.Reason,
+		Details:    report.Details,
+		Status:     report.Status,
+		ResolvedAt: nullTimePtr(report.ResolvedAt),
+	}
+}
+
+// decodeReport reads the reason and details of a report. It responds with an error and returns
+// false when they are invalid.
+func decodeReport(w http.ResponseWriter, r *http.Request) (reason, details string, ok bool) {
+	type parameters struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return "", "", false
+	}
+	if !slices.Contains(reportReasons, params.Reason) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Reason must be one of %s", strings.Join(reportReasons, ", ")), nil)
+		return "", "", false
+	}
+	if len(params.Details) > maxReportDetailsLength {
+		respondWithError(w, http.StatusBadRequest, "Report details are too long", nil)
+		return "", "", false
+	}
+	return params.Reason, params.Details, true
+}
+
+func (cfg *apiConfig) handlerChirpReport(w http.ResponseWriter, r *http.Request) {
+	reporterID, err := cfg.sessionUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+	chirp, err := cfg.chirps.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: reporterID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	if chirp.UserID == reporterID {
+		respondWithError(w, http.StatusBadRequest, "You can't report your own chirp", nil)
+		return
+	}
+	reason, details, ok := decodeReport(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := cfg.reports.CreateReport(r.Context(), database.CreateReportParams{
+		ReporterID: uuid.NullUUID{UUID: reporterID, Valid: true},
+		UserID:     chirp.UserID,
+		ChirpID:    uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ChirpBody:  chirp.Body,
+		Reason:     reason,
+		Details:    details,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create report", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, reportFromDB(report))
+}
+
+func (cfg *apiConfig) handlerUserReport(w http.ResponseWriter, r *http.Request) {
+	reporterID, err := cfg.sessionUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+	user, err := cfg.users.GetUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if user.ID == reporterID {
+		respondWithError(w, http.StatusBadRequest, "You can't report yourself", nil)
+		return
+	}
+	reason, details, ok := decodeReport(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := cfg.reports.CreateReport(r.Context(), database.CreateReportParams{
+		ReporterID: uuid.NullUUID{UUID: reporterID, Valid: true},
+		UserID:     user.ID,
+		Reason:     reason,
+		Details:    details,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create report", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, reportFromDB(report))
+}
+
+// flagChirp marks a chirp the profanity filter flagged and files a report so it shows up in the
+// moderation queue.
+func (cfg *apiConfig) flagChirp(ctx context.Context, chirp database.Chirp, matches []string) (database.Chirp, error) {
+	chirp, err := cfg.chirps.FlagChirp(ctx, chirp.ID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	_, err = cfg.reports.CreateReport(ctx, database.CreateReportParams{
+		UserID:    chirp.UserID,
+		ChirpID:   uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ChirpBody: chirp.Body,
+		Reason:    reportReasonProhibitedWords,
+		Details:   strings.Join(matches, ", "),
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	return chirp, nil
+}
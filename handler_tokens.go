@@ -0,0 +1,156 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/acramatte/Chirpy/internal/auth"
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func personalAccessTokenFromDB(pat database.PersonalAccessToken) PersonalAccessToken {
+	return PersonalAccessToken{
+		ID:         pat.ID,
+		CreatedAt:  pat.CreatedAt,
+		Name:       pat.Name,
+		Scopes:     strings.Fields(pat.Scopes),
+		ExpiresAt:  nullTimePtr(pat.ExpiresAt),
+		LastUsedAt: nullTimePtr(pat.LastUsedAt),
+		RevokedAt:  nullTimePtr(pat.RevokedAt),
+	}
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func (cfg *apiConfig) handlerTokensCreate(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.sessionUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	type parameters struct {
+		Name             string   `json:"name"`
+		Scopes           []string `json:"scopes"`
+		ExpiresInSeconds int      `json:"expires_in_seconds"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if params.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "Token name is required", nil)
+		return
+	}
+	scopes, err := auth.ParseScopes(strings.Join(params.Scopes, " "))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if len(scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one scope is required", nil)
+		return
+	}
+	if params.ExpiresInSeconds < 0 {
+		respondWithError(w, http.StatusBadRequest, "Expiry must be positive", nil)
+		return
+	}
+	expiresAt := sql.NullTime{}
+	if params.ExpiresInSeconds > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().UTC().Add(time.Duration(params.ExpiresInSeconds) * time.Second), Valid: true}
+	}
+
+	token, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create a personal access token", err)
+		return
+	}
+	pat, err := cfg.personalAccessTokens.CreatePersonalAccessToken(r.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:    userID,
+		Name:      params.Name,
+		TokenHash: auth.HashToken(token),
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't persist the personal access token", err)
+		return
+	}
+
+	// The plaintext token is only ever returned here; only its hash is stored.
+	resp := personalAccessTokenFromDB(pat)
+	resp.Token = token
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *apiConfig) handlerTokensList(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.sessionUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+	dbTokens, err := cfg.personalAccessTokens.GetPersonalAccessTokensByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve personal access tokens", err)
+		return
+	}
+	tokens := []PersonalAccessToken{}
+	for _, pat := range dbTokens {
+		tokens = append(tokens, personalAccessTokenFromDB(pat))
+	}
+	respondWithJSON(w, http.StatusOK, tokens)
+}
+
+func (cfg *apiConfig) handlerTokenRevoke(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.sessionUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+	tokenID, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid token ID", err)
+		return
+	}
+	_, err = cfg.personalAccessTokens.RevokePersonalAccessToken(r.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Token not found", err)
+		return
+	}
+	actorType, actorID := userActor(userID)
+	cfg.audit(r.Context(), auditEntry{
+		Action:     auditTokenRevoked,
+		ActorType:  actorType,
+		ActorID:    actorID,
+		TargetType: "personal_access_token",
+		TargetID:   tokenID.String(),
+		Details:    map[string]interface{}{"token_type": "personal_access_token"},
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
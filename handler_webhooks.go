@@ -1,45 +1,178 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
-	"github.com/acramatte/Chirpy/internal/auth"
-	"github.com/google/uuid"
+	"errors"
+	"fmt"
+	"github.com/acramatte/Chirpy/internal/database"
+	"io"
 	"net/http"
+	"time"
 )
 
+const maxWebhookBodyBytes = 1 << 20
+
+const (
+	webhookProviderPolka = "polka"
+
+	webhookStatusProcessing = "processing"
+	webhookStatusProcessed  = "processed"
+	webhookStatusIgnored    = "ignored"
+	webhookStatusFailed     = "failed"
+
+	// webhookEventLease is how long a claim on an event lasts. Processing takes a few queries, so
+	// an event still claimed after that was abandoned, and the next delivery or replay takes over.
+	webhookEventLease = 5 * time.Minute
+)
+
+var errWebhookIgnored = errors.New("event type is not handled")
+
 func (cfg *apiConfig) handlerUpgradeRed(w http.ResponseWriter, r *http.Request) {
-	apiKey, err := auth.GetAPIKey(r.Header)
+	defer r.Body.Close()
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find api key", err)
+		respondWithError(w, http.StatusBadRequest, "Couldn't read body", err)
 		return
 	}
-	if apiKey != cfg.polkaWebhookKey {
-		respondWithError(w, http.StatusUnauthorized, "API key not authorized", err)
+	now := time.Now()
+	replayKey, err := cfg.polkaVerifier.Verify(r.Header, body, now)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid webhook signature", err)
+		return
+	}
+
+	// A signature is only valid within the tolerance window, so remembering signatures for
+	// that long is enough to reject every replay.
+	err = cfg.webhookEvents.DeleteWebhookSignaturesBefore(r.Context(), now.UTC().Add(-2*cfg.polkaVerifier.Tolerance))
+	if err != nil {
+		loggerFromContext(r.Context()).Warn("Couldn't prune webhook signatures", "err", err)
+	}
+	recorded, err := cfg.webhookEvents.RecordWebhookSignature(r.Context(), replayKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record webhook signature", err)
+		return
+	}
+	if recorded == 0 {
+		respondWithError(w, http.StatusConflict, "Webhook already received", nil)
 		return
 	}
 
 	type parameters struct {
+		ID    string `json:"id"`
 		Event string `json:"event"`
-		Data  struct {
-			UserID uuid.UUID `json:"user_id"`
-		} `json:"data"`
 	}
-	decoder := json.NewDecoder(r.Body)
-	defer r.Body.Close()
 	params := parameters{}
-	err = decoder.Decode(&params)
+	err = json.Unmarshal(body, &params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if params.ID == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing event id", nil)
+		return
+	}
+
+	// Polka redelivers events until it gets a 2XX, so the same event ID can arrive several times.
+	event, err := cfg.webhookEvents.CreateWebhookEvent(r.Context(), database.CreateWebhookEventParams{
+		Provider:  webhookProviderPolka,
+		EventID:   params.ID,
+		EventType: params.Event,
+		Payload:   string(body),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		event, err = cfg.webhookEvents.GetWebhookEventByProviderID(r.Context(), database.GetWebhookEventByProviderIDParams{
+			Provider: webhookProviderPolka,
+			EventID:  params.ID,
+		})
+	}
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record webhook event", err)
 		return
 	}
-	if params.Event != "user.upgraded" {
+
+	claimed, err := cfg.webhookEvents.ClaimWebhookEvent(r.Context(), database.ClaimWebhookEventParams{
+		ID:          event.ID,
+		StaleBefore: time.Now().UTC().Add(-webhookEventLease),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// Already handled, or being handled by a concurrent delivery that Polka should retry later.
+		current, err := cfg.webhookEvents.GetWebhookEvent(r.Context(), event.ID)
+		if err == nil && current.Status == webhookStatusProcessing {
+			respondWithError(w, http.StatusConflict, "Webhook event is being processed", nil)
+			return
+		}
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
-	_, err = cfg.db.UpgradeToRed(r.Context(), params.Data.UserID)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "User not found", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't claim webhook event", err)
 		return
 	}
-	respondWithJSON(w, http.StatusNoContent, struct{}{})
+
+	// Processing failures are recorded on the event and acknowledged anyway: redelivering an
+	// event for an unknown user would fail forever. Admins can replay failed events instead.
+	_, err = cfg.runWebhookEvent(r.Context(), claimed)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record webhook outcome", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runWebhookEvent processes a claimed event and records its outcome.
+func (cfg *apiConfig) runWebhookEvent(ctx context.Context, event database.WebhookEvent) (database.WebhookEvent, error) {
+	processErr := cfg.processPolkaEvent(ctx, event)
+
+	status := webhookStatusProcessed
+	errorMessage := sql.NullString{}
+	if errors.Is(processErr, errWebhookIgnored) {
+		status = webhookStatusIgnored
+	} else if processErr != nil {
+		status = webhookStatusFailed
+		errorMessage = sql.NullString{String: processErr.Error(), Valid: true}
+		loggerFromContext(ctx).Warn("Webhook event failed", "webhook_event_id", event.ID, "err", processErr)
+	}
+	cfg.metrics.webhookEvents.WithLabelValues(event.Provider, event.EventType, status).Inc()
+	return cfg.webhookEvents.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{
+		ID:     event.ID,
+		Status: status,
+		Error:  errorMessage,
+	})
+}
+
+func (cfg *apiConfig) processPolkaEvent(ctx context.Context, event database.WebhookEvent) error {
+	type payload struct {
+		Data subscriptionEvent `json:"data"`
+	}
+	params := payload{}
+	err := json.Unmarshal([]byte(event.Payload), &params)
+	if err != nil {
+		return fmt.Errorf("couldn't decode payload: %w", err)
+	}
+
+	switch event.EventType {
+	case "user.upgraded":
+		err = cfg.handleUserUpgraded(ctx, params.Data)
+	case "subscription.renewed":
+		err = cfg.handleSubscriptionRenewed(ctx, event.ID, params.Data)
+	case "payment.failed":
+		err = cfg.handlePaymentFailed(ctx, params.Data)
+	case "user.downgraded":
+		err = cfg.handleUserDowngraded(ctx, params.Data)
+	default:
+		return errWebhookIgnored
+	}
+	if err != nil {
+		return err
+	}
+	cfg.audit(ctx, auditEntry{
+		Action:     auditSubscription,
+		ActorType:  actorPolka,
+		TargetType: "user",
+		TargetID:   params.Data.UserID.String(),
+		Details:    map[string]interface{}{"event": event.EventType, "webhook_event_id": event.ID, "plan": params.Data.Plan},
+	})
+	return nil
 }
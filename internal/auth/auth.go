@@ -7,57 +7,73 @@ import (
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 	"net/http"
 	"strings"
 	"time"
 )
 
-func HashPassword(password string) (string, error) {
-	fromPassword, err := bcrypt.GenerateFromPassword([]byte(password), 10)
-	if err != nil {
-		return "", err
-	}
-	return string(fromPassword), nil
+// Claims are the JWT claims issued by Chirpy. Session tokens leave ClientID and Scope empty;
+// tokens issued to OAuth clients carry the client ID and the space-separated scopes granted.
+type Claims struct {
+	jwt.RegisteredClaims
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
 }
 
-func CheckPasswordHash(password, hash string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	return MakeScopedJWT(userID, tokenSecret, expiresIn, "", "")
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		Subject:   userID.String(),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+// MakeScopedJWT creates an access token on behalf of an OAuth client restricted to the given scopes.
+func MakeScopedJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration, clientID, scope string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			Subject:   userID.String(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+		},
+		ClientID: clientID,
+		Scope:    scope,
 	})
 	return token.SignedString([]byte(tokenSecret))
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
-	})
+	claims, err := ParseJWT(tokenString, tokenSecret)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
-	expirationTime, err := token.Claims.GetExpirationTime()
+	return claims.UserID()
+}
+
+// ParseJWT validates the token signature and expiry and returns its claims.
+func ParseJWT(tokenString, tokenSecret string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(tokenSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 	if err != nil {
-		return uuid.UUID{}, err
-	}
-	if expirationTime.Time.Before(time.Now().UTC()) {
-		return uuid.UUID{}, errors.New("token has expired")
+		return nil, err
 	}
-	userID, err := token.Claims.GetSubject()
+	expirationTime, err := token.Claims.GetExpirationTime()
 	if err != nil {
-		return uuid.UUID{}, err
+		return nil, err
 	}
-	parsedUUID, err := uuid.Parse(userID)
-	if err != nil {
-		return uuid.UUID{}, err
+	if expirationTime == nil || expirationTime.Time.Before(time.Now().UTC()) {
+		return nil, errors.New("token has expired")
 	}
-	return parsedUUID, nil
+	return claims, nil
+}
+
+// UserID returns the user the token was issued for.
+func (c *Claims) UserID() (uuid.UUID, error) {
+	return uuid.Parse(c.Subject)
+}
+
+// IsSession reports whether the token is a first-party session token rather than one issued to an OAuth client.
+func (c *Claims) IsSession() bool {
+	return c.ClientID == ""
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
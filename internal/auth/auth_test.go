@@ -1,8 +1,13 @@
 package auth
 
 import (
+	"errors"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -75,3 +80,197 @@ func TestGetBearerToken(t *testing.T) {
 		t.Error("TestGetBearerToken() error - token parsed not matching")
 	}
 }
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes("chirps:write chirps:read chirps:write")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(scopes) != 2 || scopes[0] != ScopeChirpsWrite || scopes[1] != ScopeChirpsRead {
+		t.Errorf("Expected deduplicated scopes, got %v", scopes)
+	}
+
+	_, err = ParseScopes("chirps:write admin:everything")
+	if err == nil {
+		t.Error("Expected error for unknown scope, got none")
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	if !HasScope("chirps:read chirps:write", ScopeChirpsWrite) {
+		t.Error("Expected chirps:write to be granted")
+	}
+	if HasScope("chirps:read", ScopeChirpsWrite) {
+		t.Error("Expected chirps:write not to be granted")
+	}
+}
+
+func TestMakePersonalAccessToken(t *testing.T) {
+	token, err := MakePersonalAccessToken()
+	if err != nil {
+		t.Fatalf("Failed to create personal access token: %v", err)
+	}
+	if !IsPersonalAccessToken(token) {
+		t.Errorf("Expected %q to be recognised as a personal access token", token)
+	}
+	if HashToken(token) == token || HashToken(token) != HashToken(token) {
+		t.Error("Expected a stable hash distinct from the token")
+	}
+}
+
+func TestScopedJWT(t *testing.T) {
+	tokenSecret := "secret"
+	userID := uuid.New()
+
+	tokenString, err := MakeScopedJWT(userID, tokenSecret, time.Hour, "client-1", "chirps:read")
+	if err != nil {
+		t.Fatalf("Failed to create JWT: %v", err)
+	}
+	claims, err := ParseJWT(tokenString, tokenSecret)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if claims.IsSession() || claims.ClientID != "client-1" || claims.Scope != "chirps:read" {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+
+	sessionToken, err := MakeJWT(userID, tokenSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create JWT: %v", err)
+	}
+	claims, err = ParseJWT(sessionToken, tokenSecret)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !claims.IsSession() {
+		t.Error("Expected a session token")
+	}
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	// Example from RFC 7636 appendix B.
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if S256Challenge(verifier) != challenge {
+		t.Errorf("Expected challenge %s, got %s", challenge, S256Challenge(verifier))
+	}
+	if err := VerifyPKCE(verifier, challenge, PKCEMethodS256); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if err := VerifyPKCE(verifier+"x", challenge, PKCEMethodS256); err == nil {
+		t.Error("Expected error for mismatched verifier, got none")
+	}
+	if err := VerifyPKCE(verifier, verifier, "plain"); err == nil {
+		t.Error("Expected error for plain method, got none")
+	}
+}
+
+func TestLoginThrottleLockout(t *testing.T) {
+	throttle := LoginThrottle{Threshold: 3, BaseDelay: time.Minute, MaxDelay: 10 * time.Minute}
+	cases := map[int]time.Duration{
+		1:  0,
+		2:  0,
+		3:  time.Minute,
+		4:  2 * time.Minute,
+		5:  4 * time.Minute,
+		6:  8 * time.Minute,
+		7:  10 * time.Minute,
+		50: 10 * time.Minute,
+	}
+	for failures, expected := range cases {
+		if got := throttle.Lockout(failures); got != expected {
+			t.Errorf("Lockout(%d) = %v, expected %v", failures, got, expected)
+		}
+	}
+}
+
+func TestCheckLegacyBcryptHash(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("mysecretpassword"), 10)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if err := CheckPasswordHash("mysecretpassword", string(hash)); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if err := CheckPasswordHash("wrongpassword", string(hash)); err == nil {
+		t.Error("Expected error for incorrect password, got none")
+	}
+	if !NeedsRehash(string(hash)) {
+		t.Error("Expected bcrypt hash to need a rehash")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hash, err := HashPassword("mysecretpassword")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if NeedsRehash(hash) {
+		t.Error("Expected fresh hash not to need a rehash")
+	}
+
+	stronger := DefaultArgon2Params
+	stronger.Iterations++
+	if err := SetPasswordParams(stronger); err != nil {
+		t.Fatalf("Failed to set params: %v", err)
+	}
+	defer SetPasswordParams(DefaultArgon2Params)
+	if !NeedsRehash(hash) {
+		t.Error("Expected hash with outdated parameters to need a rehash")
+	}
+	if err := CheckPasswordHash("mysecretpassword", hash); err != nil {
+		t.Errorf("Expected old hash to keep verifying, got %v", err)
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := "password123\n" + "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\n" // SHA-1 of "password"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write breach list: %v", err)
+	}
+	policy := PasswordPolicy{MinLength: 8, MaxLength: 64}
+	if err := policy.LoadBreachedPasswords(path); err != nil {
+		t.Fatalf("Failed to load breach list: %v", err)
+	}
+
+	cases := map[string]error{
+		"short":                   ErrPasswordTooShort,
+		strings.Repeat("a", 65):   ErrPasswordTooLong,
+		"password123":             ErrPasswordBreached,
+		"password":                ErrPasswordBreached,
+		"correct horse battery":   nil,
+		"ünïcødé-pässwörd-éñøügh": nil,
+	}
+	for password, expected := range cases {
+		if err := policy.Validate(password); !errors.Is(err, expected) {
+			t.Errorf("Validate(%q) = %v, expected %v", password, err, expected)
+		}
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	tests := []struct {
+		role     string
+		required string
+		expected bool
+	}{
+		{RoleAdmin, RoleModerator, true},
+		{RoleAdmin, RoleAdmin, true},
+		{RoleModerator, RoleModerator, true},
+		{RoleModerator, RoleAdmin, false},
+		{RoleUser, RoleModerator, false},
+		{"superuser", RoleUser, false},
+	}
+	for _, tt := range tests {
+		if got := HasRole(tt.role, tt.required); got != tt.expected {
+			t.Errorf("HasRole(%q, %q) = %v, expected %v", tt.role, tt.required, got, tt.expected)
+		}
+	}
+
+	_, err := ParseRole("superuser")
+	if err == nil {
+		t.Error("Expected error for unknown role, got none")
+	}
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// PasswordPolicy is enforced whenever a user chooses a password.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	breached  map[string]struct{}
+}
+
+var (
+	ErrPasswordTooShort = errors.New("password is too short")
+	ErrPasswordTooLong  = errors.New("password is too long")
+	ErrPasswordBreached = errors.New("password appears in a known data breach")
+)
+
+// LoadBreachedPasswords reads a breach list with one entry per line. Lines may be plaintext
+// passwords or SHA-1 hashes in the Have I Been Pwned "HASH:count" format.
+func (p *PasswordPolicy) LoadBreachedPasswords(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("couldn't open breached password list: %w", err)
+	}
+	defer f.Close()
+
+	breached := map[string]struct{}{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		if len(hash) != sha1.Size*2 || !isHex(hash) {
+			hash = sha1Hex(line)
+		}
+		breached[strings.ToUpper(hash)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("couldn't read breached password list: %w", err)
+	}
+	p.breached = breached
+	return nil
+}
+
+// Validate returns an error describing why the password is not acceptable.
+func (p PasswordPolicy) Validate(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("%w: at least %d characters are required", ErrPasswordTooShort, p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return fmt.Errorf("%w: at most %d characters are allowed", ErrPasswordTooLong, p.MaxLength)
+	}
+	if _, ok := p.breached[sha1Hex(password)]; ok {
+		return ErrPasswordBreached
+	}
+	return nil
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
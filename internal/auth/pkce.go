@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+)
+
+// PKCEMethodS256 is the only code challenge method accepted; "plain" offers no protection against interception.
+const PKCEMethodS256 = "S256"
+
+// VerifyPKCE checks an authorization code's code_verifier against the challenge sent to /oauth/authorize (RFC 7636).
+func VerifyPKCE(verifier, challenge, method string) error {
+	if method != PKCEMethodS256 {
+		return errors.New("unsupported code challenge method")
+	}
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return errors.New("code verifier must be between 43 and 128 characters")
+	}
+	if subtle.ConstantTimeCompare([]byte(S256Challenge(verifier)), []byte(challenge)) != 1 {
+		return errors.New("code verifier does not match challenge")
+	}
+	return nil
+}
+
+// S256Challenge derives the S256 code challenge for a verifier.
+func S256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
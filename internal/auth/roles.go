@@ -0,0 +1,34 @@
+package auth
+
+import "fmt"
+
+// Roles a user can have, from least to most privileged.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleRanks = map[string]int{
+	RoleUser:      0,
+	RoleModerator: 1,
+	RoleAdmin:     2,
+}
+
+// ParseRole validates a role name.
+func ParseRole(role string) (string, error) {
+	if _, ok := roleRanks[role]; !ok {
+		return "", fmt.Errorf("unknown role %q", role)
+	}
+	return role, nil
+}
+
+// HasRole reports whether a user with the given role may act as the required role. Roles are
+// hierarchical, so admins can do everything moderators can.
+func HasRole(role, required string) bool {
+	rank, ok := roleRanks[role]
+	if !ok {
+		return false
+	}
+	return rank >= roleRanks[required]
+}
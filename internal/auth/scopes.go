@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Scopes that can be granted to a personal access token.
+const (
+	ScopeChirpsRead  = "chirps:read"
+	ScopeChirpsWrite = "chirps:write"
+)
+
+// AllScopes lists every scope known to the API. Session JWTs implicitly carry all of them.
+var AllScopes = []string{ScopeChirpsRead, ScopeChirpsWrite}
+
+// PersonalAccessTokenPrefix marks a bearer token as a personal access token rather than a JWT.
+const PersonalAccessTokenPrefix = "chirpy_pat_"
+
+// MakePersonalAccessToken generates a new prefixed personal access token.
+func MakePersonalAccessToken() (string, error) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + token, nil
+}
+
+// IsPersonalAccessToken reports whether a bearer token looks like a personal access token.
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// HashToken returns the hex-encoded SHA-256 digest under which opaque tokens are stored.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseScopes splits a space-separated scope string, rejecting unknown scopes and dropping duplicates.
+func ParseScopes(scopes string) ([]string, error) {
+	var parsed []string
+	for _, scope := range strings.Fields(scopes) {
+		if !slices.Contains(AllScopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+		if !slices.Contains(parsed, scope) {
+			parsed = append(parsed, scope)
+		}
+	}
+	return parsed, nil
+}
+
+// HasScope reports whether the space-separated scope string grants the required scope.
+func HasScope(scopes, required string) bool {
+	return slices.Contains(strings.Fields(scopes), required)
+}
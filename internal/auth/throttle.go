@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// LoginThrottle describes how failed login attempts are penalised: once Threshold failures have
+// accumulated, every further failure locks the key for BaseDelay, doubling up to MaxDelay.
+type LoginThrottle struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// Lockout returns how long a key must wait after its failures-th consecutive failure.
+func (t LoginThrottle) Lockout(failures int) time.Duration {
+	if failures < t.Threshold {
+		return 0
+	}
+	delay := t.BaseDelay
+	for i := t.Threshold; i < failures && delay < t.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, t.MaxDelay)
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// CheckDummyPassword spends the same time as CheckPasswordHash against a real hash. It is used
+// when the account does not exist so response timing does not reveal which emails are registered.
+func CheckDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = HashPassword("chirpy-dummy-password")
+	})
+	_ = CheckPasswordHash(password, dummyHash)
+}
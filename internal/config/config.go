@@ -0,0 +1,380 @@
+// Package config loads Chirpy's settings from defaults, an optional YAML file, environment
+// variables and command line flags, in increasing order of precedence.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting. Each field's config tag is its key in the YAML file; the
+// environment variable is the key in upper case and the flag is the key with dashes.
+type Config struct {
+	HTTPAddr         string        `config:"http_addr"`
+	HTTPReadTimeout  time.Duration `config:"http_read_timeout"`
+	HTTPWriteTimeout time.Duration `config:"http_write_timeout"`
+	HTTPIdleTimeout  time.Duration `config:"http_idle_timeout"`
+	ShutdownDelay    time.Duration `config:"shutdown_delay"`
+	ShutdownTimeout  time.Duration `config:"shutdown_timeout"`
+	TrustedProxies   string        `config:"trusted_proxies"`
+
+	DBURL       string `config:"db_url" required:"true" secret:"url"`
+	AutoMigrate bool   `config:"auto_migrate"`
+	Platform    string `config:"platform" required:"true"`
+
+	JWTSecret       string        `config:"jwt_secret" required:"true" secret:"true"`
+	AccessTokenTTL  time.Duration `config:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `config:"refresh_token_ttl"`
+
+	AdminAPIKey         string `config:"admin_api_key" secret:"true"`
+	BootstrapAdminEmail string `config:"bootstrap_admin_email"`
+
+	PasswordMinLength     int    `config:"password_min_length"`
+	BreachedPasswordsFile string `config:"breached_passwords_file"`
+	Argon2MemoryKiB       int    `config:"argon2_memory_kib"`
+	Argon2Iterations      int    `config:"argon2_iterations"`
+	Argon2Parallelism     int    `config:"argon2_parallelism"`
+
+	PolkaKey         string        `config:"polka_key" required:"true" secret:"true"`
+	PolkaKeyPrevious string        `config:"polka_key_previous" secret:"true"`
+	PolkaTolerance   time.Duration `config:"polka_tolerance"`
+	WebhookTimeout   time.Duration `config:"webhook_timeout"`
+
+	EntitlementsFile string `config:"entitlements_file"`
+
+	OIDCIssuer       string `config:"oidc_issuer"`
+	OIDCClientID     string `config:"oidc_client_id"`
+	OIDCClientSecret string `config:"oidc_client_secret" secret:"true"`
+	OIDCRedirectURL  string `config:"oidc_redirect_url"`
+
+	LogLevel           string `config:"log_level"`
+	LogFormat          string `config:"log_format"`
+	OTELTracesExporter string `config:"otel_traces_exporter"`
+}
+
+// Default returns the settings used when nothing else is configured.
+func Default() Config {
+	return Config{
+		HTTPAddr:           ":8080",
+		HTTPReadTimeout:    15 * time.Second,
+		HTTPWriteTimeout:   30 * time.Second,
+		HTTPIdleTimeout:    2 * time.Minute,
+		ShutdownTimeout:    30 * time.Second,
+		AccessTokenTTL:     time.Hour,
+		RefreshTokenTTL:    60 * 24 * time.Hour,
+		PasswordMinLength:  8,
+		Argon2MemoryKiB:    64 * 1024,
+		Argon2Iterations:   3,
+		Argon2Parallelism:  2,
+		PolkaTolerance:     5 * time.Minute,
+		WebhookTimeout:     10 * time.Second,
+		LogLevel:           "info",
+		LogFormat:          "json",
+		OTELTracesExporter: "none",
+	}
+}
+
+// Options are the command line flags that aren't settings.
+type Options struct {
+	// File is the YAML file settings were read from, if any.
+	File string
+	// PrintConfig asks to print the effective settings and exit.
+	PrintConfig bool
+	// Args are the arguments after the flags, naming a command such as "migrate up".
+	Args []string
+}
+
+// Load builds the configuration from the defaults, the YAML file named by --config or
+// CONFIG_FILE, the environment and the flags at the start of args, then validates it.
+func Load(args []string, getenv func(string) string) (Config, Options, error) {
+	cfg := Default()
+	opts := Options{}
+	fields := settings(&cfg)
+
+	fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.File, "config", getenv("CONFIG_FILE"), "YAML file to read settings from")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective settings with secrets redacted and exit")
+	flagValues := map[string]string{}
+	for _, f := range fields {
+		key := f.key
+		setFlag := func(value string) error {
+			flagValues[key] = value
+			return nil
+		}
+		if f.value.Kind() == reflect.Bool {
+			fs.BoolFunc(f.flagName(), "sets "+key, setFlag)
+		} else {
+			fs.Func(f.flagName(), "sets "+key, setFlag)
+		}
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return Config{}, opts, err
+	}
+	opts.Args = fs.Args()
+
+	if opts.File != "" {
+		err = loadFile(opts.File, fields)
+		if err != nil {
+			return Config{}, opts, err
+		}
+	}
+	for _, f := range fields {
+		value := getenv(f.envName())
+		if value == "" {
+			continue
+		}
+		err = f.set(value)
+		if err != nil {
+			return Config{}, opts, fmt.Errorf("environment variable %s %w", f.envName(), err)
+		}
+	}
+	for _, f := range fields {
+		value, ok := flagValues[f.key]
+		if !ok {
+			continue
+		}
+		err = f.set(value)
+		if err != nil {
+			return Config{}, opts, fmt.Errorf("flag --%s %w", f.flagName(), err)
+		}
+	}
+	return cfg, opts, cfg.Validate()
+}
+
+func loadFile(path string, fields []setting) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	values := map[string]interface{}{}
+	err = yaml.Unmarshal(data, &values)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	byKey := map[string]setting{}
+	for _, f := range fields {
+		byKey[f.key] = f
+	}
+	for key, value := range values {
+		f, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+		if value == nil {
+			continue
+		}
+		err = f.set(fmt.Sprint(value))
+		if err != nil {
+			return fmt.Errorf("%s: %s %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// Validate reports every missing or invalid setting at once.
+func (cfg Config) Validate() error {
+	var errs []error
+	for _, f := range settings(&cfg) {
+		if f.required && f.value.IsZero() {
+			errs = append(errs, fmt.Errorf("%s is required", f.key))
+		}
+		if f.value.Kind() == reflect.Int64 && f.value.Int() < 0 {
+			errs = append(errs, fmt.Errorf("%s can't be negative", f.key))
+		}
+	}
+	positive := []struct {
+		key   string
+		value int64
+	}{
+		{"access_token_ttl", int64(cfg.AccessTokenTTL)},
+		{"refresh_token_ttl", int64(cfg.RefreshTokenTTL)},
+		{"polka_tolerance", int64(cfg.PolkaTolerance)},
+		{"webhook_timeout", int64(cfg.WebhookTimeout)},
+		{"shutdown_timeout", int64(cfg.ShutdownTimeout)},
+		{"password_min_length", int64(cfg.PasswordMinLength)},
+		{"argon2_memory_kib", int64(cfg.Argon2MemoryKiB)},
+		{"argon2_iterations", int64(cfg.Argon2Iterations)},
+		{"argon2_parallelism", int64(cfg.Argon2Parallelism)},
+	}
+	for _, p := range positive {
+		if p.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", p.key))
+		}
+	}
+	if cfg.Argon2Parallelism > 255 {
+		errs = append(errs, errors.New("argon2_parallelism can't exceed 255"))
+	}
+	if cfg.OIDCIssuer != "" && (cfg.OIDCClientID == "" || cfg.OIDCClientSecret == "" || cfg.OIDCRedirectURL == "") {
+		errs = append(errs, errors.New("oidc_issuer requires oidc_client_id, oidc_client_secret and oidc_redirect_url"))
+	}
+	if _, err := parseProxies(cfg.TrustedProxies); err != nil {
+		errs = append(errs, fmt.Errorf("trusted_proxies %w", err))
+	}
+	var level slog.Level
+	if level.UnmarshalText([]byte(cfg.LogLevel)) != nil {
+		errs = append(errs, errors.New("log_level must be debug, info, warn or error"))
+	}
+	if cfg.LogFormat != "json" && cfg.LogFormat != "text" {
+		errs = append(errs, errors.New("log_format must be json or text"))
+	}
+	switch cfg.OTELTracesExporter {
+	case "none", "otlp", "console", "stdout":
+	default:
+		errs = append(errs, errors.New("otel_traces_exporter must be none, otlp or console"))
+	}
+	return errors.Join(errs...)
+}
+
+// SlogLevel returns the log level. It is only meaningful for a valid Config.
+func (cfg Config) SlogLevel() slog.Level {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(cfg.LogLevel))
+	return level
+}
+
+// TrustedProxyPrefixes returns the proxies whose X-Forwarded-For header is believed, a single
+// address being a prefix of its full length. It is only meaningful for a valid Config.
+func (cfg Config) TrustedProxyPrefixes() []netip.Prefix {
+	prefixes, _ := parseProxies(cfg.TrustedProxies)
+	return prefixes
+}
+
+// parseProxies parses a comma-separated list of IP addresses and CIDR ranges.
+func parseProxies(value string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("has %q, which is neither an IP address nor a CIDR range", entry)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// WriteRedacted writes the settings as YAML that Load accepts, with secrets redacted.
+func (cfg Config) WriteRedacted(w io.Writer) error {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range settings(&cfg) {
+		doc.Content = append(doc.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: f.key},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: f.redacted(), Style: yaml.DoubleQuotedStyle},
+		)
+	}
+	encoder := yaml.NewEncoder(w)
+	defer encoder.Close()
+	return encoder.Encode(doc)
+}
+
+// setting is one field of a Config.
+type setting struct {
+	key      string
+	required bool
+	// secret is "true" for values that are redacted when printed, or "url" for URLs
+	// of which only the password is, see redactedURL.
+	secret string
+	value  reflect.Value
+}
+
+func settings(cfg *Config) []setting {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	fields := make([]setting, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fields = append(fields, setting{
+			key:      field.Tag.Get("config"),
+			required: field.Tag.Get("required") == "true",
+			secret:   field.Tag.Get("secret"),
+			value:    v.Field(i),
+		})
+	}
+	return fields
+}
+
+func (f setting) envName() string {
+	return strings.ToUpper(f.key)
+}
+
+func (f setting) flagName() string {
+	return strings.ReplaceAll(f.key, "_", "-")
+}
+
+func (f setting) set(value string) error {
+	switch f.value.Interface().(type) {
+	case string:
+		f.value.SetString(value)
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		f.value.SetBool(b)
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		f.value.SetInt(int64(n))
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("must be a duration such as 30s or 1h")
+		}
+		f.value.SetInt(int64(d))
+	default:
+		return fmt.Errorf("has unsupported type %s", f.value.Type())
+	}
+	return nil
+}
+
+func (f setting) redacted() string {
+	value := fmt.Sprint(f.value.Interface())
+	if value == "" || f.secret == "" {
+		return value
+	}
+	if f.secret == "url" {
+		return redactedURL(value)
+	}
+	return "[REDACTED]"
+}
+
+// redactedURL masks the password in a URL with userinfo and drops password query parameters.
+// Anything else, such as a key/value connection string like "host=db password=secret", might
+// hold a password anywhere, so it is redacted entirely.
+func redactedURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return "[REDACTED]"
+	}
+	query := u.Query()
+	for key := range query {
+		if strings.Contains(strings.ToLower(key), "password") {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.Redacted()
+}
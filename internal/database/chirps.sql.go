@@ -7,10 +7,38 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const countChirpsByUserSince = `-- name: CountChirpsByUserSince :one
+SELECT COUNT(*) FROM chirps WHERE user_id = $1 AND created_at > $2
+`
+
+type CountChirpsByUserSinceParams struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) CountChirpsByUserSince(ctx context.Context, arg CountChirpsByUserSinceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpsByUserSince, arg.UserID, arg.CreatedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countPinnedChirpsByUser = `-- name: CountPinnedChirpsByUser :one
+SELECT COUNT(*) FROM chirps WHERE user_id = $1 AND pinned_at IS NOT NULL
+`
+
+func (q *Queries) CountPinnedChirpsByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPinnedChirpsByUser, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
 INSERT INTO chirps (id, created_at, updated_at, body, user_id)
 VALUES (
@@ -20,7 +48,7 @@ VALUES (
     $1,
     $2
        )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, pinned_at, flagged_at, hidden_at
 `
 
 type CreateChirpParams struct {
@@ -37,6 +65,9 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.PinnedAt,
+		&i.FlaggedAt,
+		&i.HiddenAt,
 	)
 	return i, err
 }
@@ -50,8 +81,67 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteChirpsByUser = `-- name: DeleteChirpsByUser :many
+DELETE FROM chirps WHERE user_id = $1
+RETURNING id, created_at, updated_at, body, user_id, pinned_at, flagged_at, hidden_at
+`
+
+func (q *Queries) DeleteChirpsByUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, deleteChirpsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.PinnedAt,
+			&i.FlaggedAt,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const flagChirp = `-- name: FlagChirp :one
+UPDATE chirps SET flagged_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, pinned_at, flagged_at, hidden_at
+`
+
+func (q *Queries) FlagChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, flagChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PinnedAt,
+		&i.FlaggedAt,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, pinned_at, flagged_at, hidden_at FROM chirps WHERE id = $1
 `
 
 func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -63,24 +153,38 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.PinnedAt,
+		&i.FlaggedAt,
+		&i.HiddenAt,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.pinned_at, c.flagged_at, c.hidden_at FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE c.hidden_at IS NULL
+AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+AND (u.shadowbanned_at IS NULL OR c.user_id = $1)
 ORDER BY
     CASE
-        WHEN $1 = 'desc' THEN created_at
+        WHEN $2::text = 'desc' THEN c.created_at
         ELSE NULL -- Use NULL for invalid cases
         END DESC,
     CASE
-        WHEN $1 != 'desc' THEN created_at
+        WHEN $2::text != 'desc' THEN c.created_at
         END ASC
 `
 
-func (q *Queries) GetChirps(ctx context.Context, dollar_1 interface{}) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps, dollar_1)
+type GetChirpsParams struct {
+	ViewerID  uuid.NullUUID
+	SortOrder string
+}
+
+// Chirps of suspended authors are hidden from everyone, those of shadowbanned authors from everyone
+// but the author.
+func (q *Queries) GetChirps(ctx context.Context, arg GetChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, arg.ViewerID, arg.SortOrder)
 	if err != nil {
 		return nil, err
 	}
@@ -94,6 +198,9 @@ func (q *Queries) GetChirps(ctx context.Context, dollar_1 interface{}) ([]Chirp,
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.PinnedAt,
+			&i.FlaggedAt,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -109,24 +216,68 @@ func (q *Queries) GetChirps(ctx context.Context, dollar_1 interface{}) ([]Chirp,
 }
 
 const getChirpsByAuthorId = `-- name: GetChirpsByAuthorId :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE user_id = $1
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.pinned_at, c.flagged_at, c.hidden_at FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE c.user_id = $1
+AND c.hidden_at IS NULL
+AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+AND (u.shadowbanned_at IS NULL OR c.user_id = $2)
 ORDER BY
     CASE
-        WHEN $2 = 'desc' THEN created_at
+        WHEN $3::text = 'desc' THEN c.created_at
         ELSE NULL -- Use NULL for invalid cases
         END DESC,
     CASE
-        WHEN $2 != 'desc' THEN created_at
+        WHEN $3::text != 'desc' THEN c.created_at
         END ASC
 `
 
 type GetChirpsByAuthorIdParams struct {
-	UserID  uuid.UUID
-	Column2 interface{}
+	UserID    uuid.UUID
+	ViewerID  uuid.NullUUID
+	SortOrder string
 }
 
 func (q *Queries) GetChirpsByAuthorId(ctx context.Context, arg GetChirpsByAuthorIdParams) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByAuthorId, arg.UserID, arg.Column2)
+	rows, err := q.db.QueryContext(ctx, getChirpsByAuthorId, arg.UserID, arg.ViewerID, arg.SortOrder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.PinnedAt,
+			&i.FlaggedAt,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFlaggedChirps = `-- name: GetFlaggedChirps :many
+SELECT id, created_at, updated_at, body, user_id, pinned_at, flagged_at, hidden_at FROM chirps
+WHERE flagged_at IS NOT NULL
+ORDER BY flagged_at
+`
+
+func (q *Queries) GetFlaggedChirps(ctx context.Context) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getFlaggedChirps)
 	if err != nil {
 		return nil, err
 	}
@@ -140,6 +291,9 @@ func (q *Queries) GetChirpsByAuthorId(ctx context.Context, arg GetChirpsByAuthor
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.PinnedAt,
+			&i.FlaggedAt,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -153,3 +307,126 @@ func (q *Queries) GetChirpsByAuthorId(ctx context.Context, arg GetChirpsByAuthor
 	}
 	return items, nil
 }
+
+const getVisibleChirp = `-- name: GetVisibleChirp :one
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.pinned_at, c.flagged_at, c.hidden_at FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE c.id = $1
+AND c.hidden_at IS NULL
+AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+AND (u.shadowbanned_at IS NULL OR c.user_id = $2)
+`
+
+type GetVisibleChirpParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+func (q *Queries) GetVisibleChirp(ctx context.Context, arg GetVisibleChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getVisibleChirp, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PinnedAt,
+		&i.FlaggedAt,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const hideChirp = `-- name: HideChirp :one
+UPDATE chirps SET hidden_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, pinned_at, flagged_at, hidden_at
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, hideChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PinnedAt,
+		&i.FlaggedAt,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const pinChirp = `-- name: PinChirp :one
+UPDATE chirps SET pinned_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, pinned_at, flagged_at, hidden_at
+`
+
+func (q *Queries) PinChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, pinChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PinnedAt,
+		&i.FlaggedAt,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const unpinChirp = `-- name: UnpinChirp :one
+UPDATE chirps SET pinned_at = NULL
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, pinned_at, flagged_at, hidden_at
+`
+
+func (q *Queries) UnpinChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, unpinChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PinnedAt,
+		&i.FlaggedAt,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, pinned_at, flagged_at, hidden_at
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.PinnedAt,
+		&i.FlaggedAt,
+		&i.HiddenAt,
+	)
+	return i, err
+}
@@ -11,12 +11,100 @@ import (
 	"github.com/google/uuid"
 )
 
+type AuditLog struct {
+	ID         int64
+	CreatedAt  time.Time
+	Action     string
+	ActorType  string
+	ActorID    uuid.NullUUID
+	TargetType string
+	TargetID   string
+	Ip         string
+	RequestID  string
+	Details    string
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Body      string
 	UserID    uuid.UUID
+	PinnedAt  sql.NullTime
+	FlaggedAt sql.NullTime
+	HiddenAt  sql.NullTime
+}
+
+type LoginThrottle struct {
+	Key           string
+	Failures      int32
+	LastFailureAt time.Time
+	LockedUntil   sql.NullTime
+}
+
+type ModerationAction struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	ReportID      uuid.NullUUID
+	ModeratorID   uuid.NullUUID
+	Action        string
+	UserID        uuid.UUID
+	ChirpID       uuid.NullUUID
+	Note          string
+	ModeratorType string
+}
+
+type ModerationWord struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Word      string
+	Severity  string
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Message   string
+	ReadAt    sql.NullTime
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash            string
+	CreatedAt           time.Time
+	ClientID            uuid.UUID
+	UserID              uuid.UUID
+	RedirectUri         string
+	Scopes              string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	ExpiresAt           time.Time
+	UsedAt              sql.NullTime
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	OwnerID      uuid.UUID
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris string
+	Scopes       string
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scopes     string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
 }
 
 type RefreshToken struct {
@@ -26,6 +114,41 @@ type RefreshToken struct {
 	UserID    uuid.UUID
 	ExpiresAt time.Time
 	RevokedAt sql.NullTime
+	ClientID  uuid.NullUUID
+	Scopes    sql.NullString
+}
+
+type Report struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ReporterID uuid.NullUUID
+	UserID     uuid.UUID
+	ChirpID    uuid.NullUUID
+	Reason     string
+	Details    string
+	Status     string
+	ResolvedAt sql.NullTime
+	ChirpBody  string
+}
+
+type Subscription struct {
+	ID                 uuid.UUID
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	UserID             uuid.UUID
+	Plan               string
+	Status             string
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+	GracePeriodEnd     time.Time
+	CanceledAt         sql.NullTime
+}
+
+type SubscriptionRenewal struct {
+	WebhookEventID uuid.UUID
+	SubscriptionID uuid.UUID
+	CreatedAt      time.Time
 }
 
 type User struct {
@@ -33,6 +156,61 @@ type User struct {
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	HashedPassword sql.NullString
+	SuspendedUntil sql.NullTime
+	Role           string
+	SuspendedAt    sql.NullTime
+	ShadowbannedAt sql.NullTime
+}
+
+type UserIdentity struct {
+	Issuer    string
+	Subject   string
+	CreatedAt time.Time
+	UserID    uuid.UUID
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	EndpointID     uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Payload        string
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.NullUUID
+	Url       string
+	Secret    string
+	Events    string
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID
+	Provider    string
+	EventID     string
+	EventType   string
+	Payload     string
+	Status      string
+	Error       sql.NullString
+	Attempts    int32
+	ReceivedAt  time.Time
+	ProcessedAt sql.NullTime
+	ClaimedAt   sql.NullTime
+}
+
+type WebhookSignature struct {
+	Signature  string
+	ReceivedAt time.Time
 }
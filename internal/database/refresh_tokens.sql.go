@@ -7,11 +7,87 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const consumeOAuthRefreshToken = `-- name: ConsumeOAuthRefreshToken :one
+UPDATE refresh_tokens SET updated_at = NOW(), revoked_at = NOW()
+WHERE token = $1
+AND client_id = $2
+AND revoked_at IS NULL
+AND expires_at > NOW()
+RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scopes
+`
+
+type ConsumeOAuthRefreshTokenParams struct {
+	Token    string
+	ClientID uuid.NullUUID
+}
+
+// Revoking the token in the same statement that checks it means a token can only be rotated once,
+// even by concurrent requests.
+func (q *Queries) ConsumeOAuthRefreshToken(ctx context.Context, arg ConsumeOAuthRefreshTokenParams) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, consumeOAuthRefreshToken, arg.Token, arg.ClientID)
+	var i RefreshToken
+	err := row.Scan(
+		&i.Token,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.ClientID,
+		&i.Scopes,
+	)
+	return i, err
+}
+
+const createOAuthRefreshToken = `-- name: CreateOAuthRefreshToken :one
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, client_id, scopes)
+values ($1,
+        NOW(),
+        NOW(),
+        $2,
+        $3,
+        $4,
+        $5
+       )
+RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scopes
+`
+
+type CreateOAuthRefreshTokenParams struct {
+	Token     string
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	ClientID  uuid.NullUUID
+	Scopes    sql.NullString
+}
+
+func (q *Queries) CreateOAuthRefreshToken(ctx context.Context, arg CreateOAuthRefreshTokenParams) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, createOAuthRefreshToken,
+		arg.Token,
+		arg.UserID,
+		arg.ExpiresAt,
+		arg.ClientID,
+		arg.Scopes,
+	)
+	var i RefreshToken
+	err := row.Scan(
+		&i.Token,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.ClientID,
+		&i.Scopes,
+	)
+	return i, err
+}
+
 const createRefreshToken = `-- name: CreateRefreshToken :one
 INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
 values ($1,
@@ -20,7 +96,7 @@ values ($1,
         $2,
         $3
        )
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scopes
 `
 
 type CreateRefreshTokenParams struct {
@@ -39,12 +115,43 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.ClientID,
+		&i.Scopes,
+	)
+	return i, err
+}
+
+const getActiveOAuthRefreshToken = `-- name: GetActiveOAuthRefreshToken :one
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scopes FROM refresh_tokens
+WHERE token = $1
+AND client_id = $2
+AND revoked_at IS NULL
+AND expires_at > NOW()
+`
+
+type GetActiveOAuthRefreshTokenParams struct {
+	Token    string
+	ClientID uuid.NullUUID
+}
+
+func (q *Queries) GetActiveOAuthRefreshToken(ctx context.Context, arg GetActiveOAuthRefreshTokenParams) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getActiveOAuthRefreshToken, arg.Token, arg.ClientID)
+	var i RefreshToken
+	err := row.Scan(
+		&i.Token,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.ClientID,
+		&i.Scopes,
 	)
 	return i, err
 }
 
 const getRefreshToken = `-- name: GetRefreshToken :one
-SELECT token, created_at, updated_at, user_id, expires_at, revoked_at FROM refresh_tokens WHERE token = $1
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scopes FROM refresh_tokens WHERE token = $1
 `
 
 func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
@@ -57,14 +164,17 @@ func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshTok
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.ClientID,
+		&i.Scopes,
 	)
 	return i, err
 }
 
 const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
-SELECT u.id, u.created_at, u.updated_at, u.email, u.hashed_password, u.is_chirpy_red FROM users u
+SELECT u.id, u.created_at, u.updated_at, u.email, u.hashed_password, u.suspended_until, u.role, u.suspended_at, u.shadowbanned_at FROM users u
 JOIN refresh_tokens rt ON u.id = rt.user_id
 WHERE rt.token = $1
+AND rt.client_id IS NULL
 AND revoked_at IS NULL
 AND expires_at > NOW()
 `
@@ -78,11 +188,29 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Us
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.ShadowbannedAt,
 	)
 	return i, err
 }
 
+const revokeOAuthRefreshToken = `-- name: RevokeOAuthRefreshToken :exec
+UPDATE refresh_tokens SET updated_at = NOW(), revoked_at = NOW()
+WHERE token = $1 AND client_id = $2
+`
+
+type RevokeOAuthRefreshTokenParams struct {
+	Token    string
+	ClientID uuid.NullUUID
+}
+
+func (q *Queries) RevokeOAuthRefreshToken(ctx context.Context, arg RevokeOAuthRefreshTokenParams) error {
+	_, err := q.db.ExecContext(ctx, revokeOAuthRefreshToken, arg.Token, arg.ClientID)
+	return err
+}
+
 const revokeToken = `-- name: RevokeToken :exec
 UPDATE refresh_tokens SET updated_at = NOW(), revoked_at = NOW() WHERE token = $1
 `
@@ -91,3 +219,13 @@ func (q *Queries) RevokeToken(ctx context.Context, token string) error {
 	_, err := q.db.ExecContext(ctx, revokeToken, token)
 	return err
 }
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens SET updated_at = NOW(), revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeUserRefreshTokens, userID)
+	return err
+}
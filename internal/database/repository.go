@@ -0,0 +1,214 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserRepository stores users. *Queries implements it on Postgres.
+type UserRepository interface {
+	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateUserWithRole(ctx context.Context, arg CreateUserWithRoleParams) (User, error)
+	GetUser(ctx context.Context, id uuid.UUID) (User, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	UpdateEmailAndPassword(ctx context.Context, arg UpdateEmailAndPasswordParams) (User, error)
+	UpdatePasswordHash(ctx context.Context, arg UpdatePasswordHashParams) error
+	// UpdateUserRole returns sql.ErrNoRows rather than demote the last admin.
+	UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error)
+	CountUsersByRole(ctx context.Context, role string) (int64, error)
+	SuspendUser(ctx context.Context, arg SuspendUserParams) (User, error)
+	UnsuspendUser(ctx context.Context, id uuid.UUID) (User, error)
+	ShadowbanUser(ctx context.Context, id uuid.UUID) (User, error)
+	UnshadowbanUser(ctx context.Context, id uuid.UUID) (User, error)
+	// DeleteAll deletes every user along with everything they own.
+	DeleteAll(ctx context.Context) error
+}
+
+// ChirpRepository stores chirps. *Queries implements it on Postgres.
+type ChirpRepository interface {
+	CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error)
+	GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error)
+	// GetChirps, GetChirpsByAuthorId and GetVisibleChirp only return chirps the viewer may
+	// see: not hidden by a moderator, not by a suspended author, and not by a shadowbanned
+	// author unless the viewer is that author.
+	GetChirps(ctx context.Context, arg GetChirpsParams) ([]Chirp, error)
+	GetChirpsByAuthorId(ctx context.Context, arg GetChirpsByAuthorIdParams) ([]Chirp, error)
+	GetVisibleChirp(ctx context.Context, arg GetVisibleChirpParams) (Chirp, error)
+	GetFlaggedChirps(ctx context.Context) ([]Chirp, error)
+	UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error)
+	PinChirp(ctx context.Context, id uuid.UUID) (Chirp, error)
+	UnpinChirp(ctx context.Context, id uuid.UUID) (Chirp, error)
+	FlagChirp(ctx context.Context, id uuid.UUID) (Chirp, error)
+	HideChirp(ctx context.Context, id uuid.UUID) (Chirp, error)
+	CountPinnedChirpsByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	CountChirpsByUserSince(ctx context.Context, arg CountChirpsByUserSinceParams) (int64, error)
+	DeleteChirp(ctx context.Context, id uuid.UUID) error
+	DeleteChirpsByUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error)
+}
+
+// RefreshTokenRepository stores the refresh tokens of login sessions and OAuth clients.
+// *Queries implements it on Postgres.
+type RefreshTokenRepository interface {
+	CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error)
+	CreateOAuthRefreshToken(ctx context.Context, arg CreateOAuthRefreshTokenParams) (RefreshToken, error)
+	GetRefreshToken(ctx context.Context, token string) (RefreshToken, error)
+	// GetUserFromRefreshToken returns the owner of an active login session token.
+	GetUserFromRefreshToken(ctx context.Context, token string) (User, error)
+	GetActiveOAuthRefreshToken(ctx context.Context, arg GetActiveOAuthRefreshTokenParams) (RefreshToken, error)
+	// ConsumeOAuthRefreshToken revokes an active OAuth refresh token and returns it.
+	ConsumeOAuthRefreshToken(ctx context.Context, arg ConsumeOAuthRefreshTokenParams) (RefreshToken, error)
+	RevokeToken(ctx context.Context, token string) error
+	RevokeOAuthRefreshToken(ctx context.Context, arg RevokeOAuthRefreshTokenParams) error
+	RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error
+}
+
+// SubscriptionRepository stores Chirpy Red subscriptions. *Queries implements it on Postgres.
+type SubscriptionRepository interface {
+	CreateSubscription(ctx context.Context, arg CreateSubscriptionParams) (Subscription, error)
+	// GetActiveSubscription returns the user's active or past due subscription whose grace
+	// period hasn't ended, with the latest period end.
+	GetActiveSubscription(ctx context.Context, userID uuid.UUID) (Subscription, error)
+	GetSubscriptionsByUser(ctx context.Context, userID uuid.UUID) ([]Subscription, error)
+	RenewSubscription(ctx context.Context, arg RenewSubscriptionParams) (Subscription, error)
+	MarkSubscriptionPastDue(ctx context.Context, arg MarkSubscriptionPastDueParams) (Subscription, error)
+	CancelSubscription(ctx context.Context, id uuid.UUID) (Subscription, error)
+	// CreateSubscriptionRenewal returns sql.ErrNoRows for a webhook event that already renewed
+	// a subscription.
+	CreateSubscriptionRenewal(ctx context.Context, arg CreateSubscriptionRenewalParams) (SubscriptionRenewal, error)
+	DeleteSubscriptionRenewal(ctx context.Context, webhookEventID uuid.UUID) error
+}
+
+// LoginThrottleRepository stores failed login counts. *Queries implements it on Postgres.
+type LoginThrottleRepository interface {
+	GetLoginThrottle(ctx context.Context, key string) (LoginThrottle, error)
+	// RecordLoginFailure counts a failure, starting over when the last one is a day old.
+	RecordLoginFailure(ctx context.Context, key string) (LoginThrottle, error)
+	LockLoginThrottle(ctx context.Context, arg LockLoginThrottleParams) error
+	ClearLoginThrottle(ctx context.Context, key string) (int64, error)
+}
+
+// AuditLogRepository stores the append-only audit log. *Queries implements it on Postgres.
+type AuditLogRepository interface {
+	CreateAuditEntry(ctx context.Context, arg CreateAuditEntryParams) error
+	GetAuditEntries(ctx context.Context, arg GetAuditEntriesParams) ([]AuditLog, error)
+	GetRecentAuditEntries(ctx context.Context, arg GetRecentAuditEntriesParams) ([]AuditLog, error)
+}
+
+// PersonalAccessTokenRepository stores personal access tokens. *Queries implements it on Postgres.
+type PersonalAccessTokenRepository interface {
+	CreatePersonalAccessToken(ctx context.Context, arg CreatePersonalAccessTokenParams) (PersonalAccessToken, error)
+	// GetPersonalAccessTokenByHash returns an unrevoked, unexpired token.
+	GetPersonalAccessTokenByHash(ctx context.Context, tokenHash string) (PersonalAccessToken, error)
+	GetPersonalAccessTokensByUser(ctx context.Context, userID uuid.UUID) ([]PersonalAccessToken, error)
+	TouchPersonalAccessToken(ctx context.Context, id uuid.UUID) error
+	RevokePersonalAccessToken(ctx context.Context, arg RevokePersonalAccessTokenParams) (PersonalAccessToken, error)
+	RevokeUserPersonalAccessTokens(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+// OAuthRepository stores OAuth clients and their authorization codes. *Queries implements it
+// on Postgres.
+type OAuthRepository interface {
+	CreateOAuthClient(ctx context.Context, arg CreateOAuthClientParams) (OauthClient, error)
+	GetOAuthClient(ctx context.Context, id uuid.UUID) (OauthClient, error)
+	GetOAuthClientsByOwner(ctx context.Context, ownerID uuid.UUID) ([]OauthClient, error)
+	DeleteOAuthClient(ctx context.Context, arg DeleteOAuthClientParams) (int64, error)
+	CreateOAuthAuthorizationCode(ctx context.Context, arg CreateOAuthAuthorizationCodeParams) error
+	// ConsumeOAuthAuthorizationCode marks an unused, unexpired code as used and returns it.
+	ConsumeOAuthAuthorizationCode(ctx context.Context, codeHash string) (OauthAuthorizationCode, error)
+}
+
+// IdentityRepository links users to their identities at OIDC providers. *Queries implements it
+// on Postgres.
+type IdentityRepository interface {
+	GetUserByIdentity(ctx context.Context, arg GetUserByIdentityParams) (User, error)
+	CreateUserIdentity(ctx context.Context, arg CreateUserIdentityParams) (UserIdentity, error)
+}
+
+// WebhookEndpointRepository stores outgoing webhook endpoints and their deliveries. *Queries
+// implements it on Postgres.
+type WebhookEndpointRepository interface {
+	CreateWebhookEndpoint(ctx context.Context, arg CreateWebhookEndpointParams) (WebhookEndpoint, error)
+	GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (WebhookEndpoint, error)
+	GetWebhookEndpointsByUser(ctx context.Context, userID uuid.NullUUID) ([]WebhookEndpoint, error)
+	GetAdminWebhookEndpoints(ctx context.Context) ([]WebhookEndpoint, error)
+	// GetWebhookEndpointsForOwner returns the user's endpoints and the admin endpoints.
+	GetWebhookEndpointsForOwner(ctx context.Context, userID uuid.NullUUID) ([]WebhookEndpoint, error)
+	DeleteWebhookEndpoint(ctx context.Context, id uuid.UUID) error
+	CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, id uuid.UUID) (WebhookDelivery, error)
+	GetWebhookDeliveriesByEndpoint(ctx context.Context, arg GetWebhookDeliveriesByEndpointParams) ([]WebhookDelivery, error)
+	// ClaimDueWebhookDeliveries leases pending deliveries that are due until NextAttemptAt.
+	ClaimDueWebhookDeliveries(ctx context.Context, arg ClaimDueWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	MarkWebhookDeliveryDelivered(ctx context.Context, arg MarkWebhookDeliveryDeliveredParams) error
+	MarkWebhookDeliveryFailed(ctx context.Context, arg MarkWebhookDeliveryFailedParams) error
+	// RetryWebhookDelivery returns sql.ErrNoRows unless the delivery is dead.
+	RetryWebhookDelivery(ctx context.Context, id uuid.UUID) (WebhookDelivery, error)
+}
+
+// WebhookEventRepository stores incoming webhook events and the signatures already seen.
+// *Queries implements it on Postgres.
+type WebhookEventRepository interface {
+	// RecordWebhookSignature returns 0 for a signature that was already recorded.
+	RecordWebhookSignature(ctx context.Context, signature string) (int64, error)
+	DeleteWebhookSignaturesBefore(ctx context.Context, receivedAt time.Time) error
+	// CreateWebhookEvent returns sql.ErrNoRows for an event that was already received.
+	CreateWebhookEvent(ctx context.Context, arg CreateWebhookEventParams) (WebhookEvent, error)
+	GetWebhookEvent(ctx context.Context, id uuid.UUID) (WebhookEvent, error)
+	GetWebhookEventByProviderID(ctx context.Context, arg GetWebhookEventByProviderIDParams) (WebhookEvent, error)
+	ListWebhookEvents(ctx context.Context, arg ListWebhookEventsParams) ([]WebhookEvent, error)
+	// ClaimWebhookEvent and ClaimWebhookEventForReplay return sql.ErrNoRows when the event
+	// can't be claimed.
+	ClaimWebhookEvent(ctx context.Context, arg ClaimWebhookEventParams) (WebhookEvent, error)
+	ClaimWebhookEventForReplay(ctx context.Context, arg ClaimWebhookEventForReplayParams) (WebhookEvent, error)
+	FinishWebhookEvent(ctx context.Context, arg FinishWebhookEventParams) (WebhookEvent, error)
+}
+
+// ReportRepository stores reports and the moderation actions taken on them. *Queries
+// implements it on Postgres.
+type ReportRepository interface {
+	CreateReport(ctx context.Context, arg CreateReportParams) (Report, error)
+	GetReport(ctx context.Context, id uuid.UUID) (Report, error)
+	GetReportsByStatus(ctx context.Context, arg GetReportsByStatusParams) ([]Report, error)
+	GetOpenReportsForChirp(ctx context.Context, chirpID uuid.NullUUID) ([]Report, error)
+	// GetOpenReportsForUser returns the open reports of the user rather than of their chirps.
+	GetOpenReportsForUser(ctx context.Context, userID uuid.UUID) ([]Report, error)
+	ResolveReport(ctx context.Context, arg ResolveReportParams) error
+	CreateModerationAction(ctx context.Context, arg CreateModerationActionParams) (ModerationAction, error)
+	GetModerationActionsByUser(ctx context.Context, userID uuid.UUID) ([]ModerationAction, error)
+	GetModerationActionsByReport(ctx context.Context, reportID uuid.NullUUID) ([]ModerationAction, error)
+}
+
+// NotificationRepository stores notifications for users. *Queries implements it on Postgres.
+type NotificationRepository interface {
+	CreateNotification(ctx context.Context, arg CreateNotificationParams) (Notification, error)
+	GetNotificationsByUser(ctx context.Context, arg GetNotificationsByUserParams) ([]Notification, error)
+	MarkNotificationRead(ctx context.Context, arg MarkNotificationReadParams) (int64, error)
+}
+
+// ModerationWordRepository stores the profanity filter's word list. *Queries implements it on
+// Postgres.
+type ModerationWordRepository interface {
+	CreateModerationWord(ctx context.Context, arg CreateModerationWordParams) (ModerationWord, error)
+	GetModerationWords(ctx context.Context) ([]ModerationWord, error)
+	UpdateModerationWordSeverity(ctx context.Context, arg UpdateModerationWordSeverityParams) (ModerationWord, error)
+	DeleteModerationWord(ctx context.Context, id uuid.UUID) (int64, error)
+}
+
+var (
+	_ UserRepository                = (*Queries)(nil)
+	_ ChirpRepository               = (*Queries)(nil)
+	_ RefreshTokenRepository        = (*Queries)(nil)
+	_ SubscriptionRepository        = (*Queries)(nil)
+	_ LoginThrottleRepository       = (*Queries)(nil)
+	_ AuditLogRepository            = (*Queries)(nil)
+	_ PersonalAccessTokenRepository = (*Queries)(nil)
+	_ OAuthRepository               = (*Queries)(nil)
+	_ IdentityRepository            = (*Queries)(nil)
+	_ WebhookEndpointRepository     = (*Queries)(nil)
+	_ WebhookEventRepository        = (*Queries)(nil)
+	_ ReportRepository              = (*Queries)(nil)
+	_ NotificationRepository        = (*Queries)(nil)
+	_ ModerationWordRepository      = (*Queries)(nil)
+)
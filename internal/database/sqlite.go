@@ -0,0 +1,150 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLiteDriver is the database/sql driver for SQLite databases. It is go-sqlite3 with the
+// Postgres functions the queries use: gen_random_uuid() and now(). Unlike in Postgres, now()
+// is read on every call rather than once per transaction.
+const SQLiteDriver = "chirpy-sqlite3"
+
+// sqliteTimeFormat is how times are stored in SQLite. Fixed-width UTC text sorts
+// chronologically, so times can be compared as strings.
+const sqliteTimeFormat = "2006-01-02 15:04:05.000000000-07:00"
+
+func init() {
+	sql.Register(SQLiteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			err := conn.RegisterFunc("gen_random_uuid", uuid.NewString, false)
+			if err != nil {
+				return err
+			}
+			return conn.RegisterFunc("now", func() string { return sqliteTime(time.Now()) }, false)
+		},
+	})
+}
+
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+// SQLiteDB runs sqlc's Postgres queries against SQLite by swapping each one for the SQLite
+// query of the same name.
+type SQLiteDB struct {
+	db      DBTX
+	queries map[string]string
+}
+
+// NewSQLiteDB reads the SQLite queries from the sqlc query files in queries. Their parameters
+// are numbered ?1, ?2, … in the order sqlc generated for the Postgres query of the same name.
+func NewSQLiteDB(db DBTX, queries fs.FS) (*SQLiteDB, error) {
+	parsed, err := ParseQueries(queries)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteDB{db: db, queries: parsed}, nil
+}
+
+// ParseQueries returns the queries in the sqlc query files in fsys by name.
+func ParseQueries(fsys fs.FS) (map[string]string, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	queries := map[string]string{}
+	for _, file := range names {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		var name string
+		var query strings.Builder
+		save := func() error {
+			if name == "" {
+				return nil
+			}
+			if _, ok := queries[name]; ok {
+				return fmt.Errorf("query %s is defined twice", name)
+			}
+			queries[name] = strings.TrimSpace(query.String())
+			return nil
+		}
+		scanner := bufio.NewScanner(strings.NewReader(string(data)))
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.HasPrefix(line, "-- name: ") {
+				if err := save(); err != nil {
+					return nil, err
+				}
+				name = QueryName(line)
+				query.Reset()
+			}
+			if name != "" {
+				query.WriteString(line)
+				query.WriteByte('\n')
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		if err := save(); err != nil {
+			return nil, err
+		}
+	}
+	return queries, nil
+}
+
+// translate returns the SQLite version of query, or query itself if it wasn't generated by sqlc.
+func (s *SQLiteDB) translate(query string) string {
+	if q, ok := s.queries[QueryName(query)]; ok {
+		return q
+	}
+	return query
+}
+
+// sqliteArgs formats times the way they are stored, since go-sqlite3 would keep their time zone
+// and drop trailing zeros, and then they'd no longer compare correctly.
+func sqliteArgs(args []interface{}) []interface{} {
+	converted := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			converted[i] = sqliteTime(v)
+		case sql.NullTime:
+			if v.Valid {
+				converted[i] = sqliteTime(v.Time)
+			}
+		default:
+			converted[i] = arg
+		}
+	}
+	return converted
+}
+
+func (s *SQLiteDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return s.db.ExecContext(ctx, s.translate(query), sqliteArgs(args)...)
+}
+
+// PrepareContext prepares the SQLite version of query. Times passed to the statement aren't
+// converted, so callers should run queries through the other methods.
+func (s *SQLiteDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return s.db.PrepareContext(ctx, s.translate(query))
+}
+
+func (s *SQLiteDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db.QueryContext(ctx, s.translate(query), sqliteArgs(args)...)
+}
+
+func (s *SQLiteDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return s.db.QueryRowContext(ctx, s.translate(query), sqliteArgs(args)...)
+}
@@ -0,0 +1,504 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/acramatte/Chirpy/internal/migrations"
+	"github.com/google/uuid"
+)
+
+var (
+	postgresParam = regexp.MustCompile(`\$(\d+)`)
+	sqliteParam   = regexp.MustCompile(`\?(\d+)`)
+)
+
+func maxParam(re *regexp.Regexp, query string) int {
+	highest := 0
+	for _, m := range re.FindAllStringSubmatch(query, -1) {
+		n, _ := strconv.Atoi(m[1])
+		highest = max(highest, n)
+	}
+	return highest
+}
+
+func TestSQLiteQueriesMatchPostgres(t *testing.T) {
+	postgres, err := ParseQueries(os.DirFS("../../sql/queries"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := ParseQueries(os.DirFS("../../sql/sqlite/queries"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The generated queries number sqlc.arg() parameters too, so they are compared instead of the files.
+	generated := map[string]string{
+		"ClaimWebhookEvent":          claimWebhookEvent,
+		"ClaimWebhookEventForReplay": claimWebhookEventForReplay,
+		"GetAuditEntries":            getAuditEntries,
+		"GetChirps":                  getChirps,
+		"GetRecentAuditEntries":      getRecentAuditEntries,
+		"GetChirpsByAuthorId":        getChirpsByAuthorId,
+		"GetVisibleChirp":            getVisibleChirp,
+		"ListWebhookEvents":          listWebhookEvents,
+	}
+	for name, query := range postgres {
+		if q, ok := generated[name]; ok {
+			query = q
+		}
+		sqliteQuery, ok := sqlite[name]
+		if !ok {
+			t.Errorf("Query %s has no SQLite version", name)
+			continue
+		}
+		if got, want := maxParam(sqliteParam, sqliteQuery), maxParam(postgresParam, query); got != want {
+			t.Errorf("SQLite query %s has %d parameters, Postgres query %d", name, got, want)
+		}
+	}
+	for name := range sqlite {
+		if _, ok := postgres[name]; !ok {
+			t.Errorf("SQLite query %s has no Postgres version", name)
+		}
+	}
+}
+
+func newSQLiteDB(t *testing.T) (*sql.DB, *Queries) {
+	t.Helper()
+	db, err := sql.Open(SQLiteDriver, "file::memory:?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	_, err = migrations.Up(context.Background(), db, migrations.SQLite, os.DirFS("../../sql/sqlite/schema"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqliteDB, err := NewSQLiteDB(db, os.DirFS("../../sql/sqlite/queries"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, New(sqliteDB)
+}
+
+func TestSQLiteQueriesCompile(t *testing.T) {
+	db, _ := newSQLiteDB(t)
+	queries, err := ParseQueries(os.DirFS("../../sql/sqlite/queries"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, query := range queries {
+		stmt, err := db.Prepare(query)
+		if err != nil {
+			t.Errorf("Query %s doesn't compile: %v", name, err)
+			continue
+		}
+		stmt.Close()
+	}
+}
+
+func TestSQLiteChirps(t *testing.T) {
+	ctx := context.Background()
+	_, q := newSQLiteDB(t)
+	alice, err := q.CreateUser(ctx, CreateUserParams{Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.ID == uuid.Nil || alice.Role != "user" {
+		t.Errorf("CreateUser() = %+v", alice)
+	}
+	_, err = q.CreateUser(ctx, CreateUserParams{Email: "alice@example.com"})
+	if err == nil {
+		t.Error("Expected duplicate emails to be rejected")
+	}
+	bob, err := q.CreateUser(ctx, CreateUserParams{Email: "bob@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mod, err := q.CreateUserWithRole(ctx, CreateUserWithRoleParams{Email: "mod@example.com", Role: "moderator"})
+	if err != nil || mod.Role != "moderator" {
+		t.Errorf("CreateUserWithRole() = %+v, %v", mod, err)
+	}
+	_, err = q.CreateChirp(ctx, CreateChirpParams{Body: "orphan", UserID: uuid.New()})
+	if err == nil {
+		t.Error("Expected a chirp by an unknown user to be rejected")
+	}
+
+	for _, c := range []CreateChirpParams{{Body: "first", UserID: alice.ID}, {Body: "second", UserID: bob.ID}} {
+		_, err = q.CreateChirp(ctx, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	chirps, err := q.GetChirps(ctx, GetChirpsParams{SortOrder: "desc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chirps) != 2 || chirps[0].Body != "second" || chirps[1].Body != "first" {
+		t.Errorf("Expected chirps newest first, got %+v", chirps)
+	}
+
+	_, err = q.ShadowbanUser(ctx, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirps, _ = q.GetChirps(ctx, GetChirpsParams{SortOrder: "asc"})
+	if len(chirps) != 1 || chirps[0].Body != "first" {
+		t.Errorf("Expected a shadowbanned author's chirps to be hidden, got %+v", chirps)
+	}
+	chirps, _ = q.GetChirps(ctx, GetChirpsParams{ViewerID: uuid.NullUUID{UUID: bob.ID, Valid: true}, SortOrder: "asc"})
+	if len(chirps) != 2 {
+		t.Errorf("Expected a shadowbanned author to see their own chirps, got %+v", chirps)
+	}
+
+	// Times in other zones must compare correctly with the stored UTC times.
+	zone := time.FixedZone("UTC+2", 2*60*60)
+	until := time.Now().In(zone).Add(time.Hour)
+	_, err = q.SuspendUser(ctx, SuspendUserParams{ID: alice.ID, SuspendedUntil: sql.NullTime{Time: until, Valid: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirps, _ = q.GetChirpsByAuthorId(ctx, GetChirpsByAuthorIdParams{UserID: alice.ID})
+	if len(chirps) != 0 {
+		t.Errorf("Expected a suspended author's chirps to be hidden, got %+v", chirps)
+	}
+	recent, err := q.CountChirpsByUserSince(ctx, CountChirpsByUserSinceParams{UserID: alice.ID, CreatedAt: time.Now().In(zone).Add(-time.Minute)})
+	if err != nil || recent != 1 {
+		t.Errorf("CountChirpsByUserSince() = %d, %v", recent, err)
+	}
+
+	deleted, err := q.DeleteChirpsByUser(ctx, alice.ID)
+	if err != nil || len(deleted) != 1 || deleted[0].UserID != alice.ID {
+		t.Errorf("DeleteChirpsByUser() = %+v, %v", deleted, err)
+	}
+	err = q.DeleteAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirps, _ = q.GetChirps(ctx, GetChirpsParams{ViewerID: uuid.NullUUID{UUID: bob.ID, Valid: true}})
+	if len(chirps) != 0 {
+		t.Errorf("Expected deleting users to delete their chirps, got %+v", chirps)
+	}
+}
+
+func TestSQLiteRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	_, q := newSQLiteDB(t)
+	alice, err := q.CreateUser(ctx, CreateUserParams{Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Microsecond)
+	token, err := q.CreateRefreshToken(ctx, CreateRefreshTokenParams{Token: "valid", UserID: alice.ID, ExpiresAt: expiresAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !token.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Expected the token to expire at %v, got %v", expiresAt, token.ExpiresAt)
+	}
+	_, err = q.CreateRefreshToken(ctx, CreateRefreshTokenParams{Token: "expired", UserID: alice.ID, ExpiresAt: time.Now().Add(-time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := q.GetUserFromRefreshToken(ctx, "valid")
+	if err != nil || user.ID != alice.ID {
+		t.Errorf("GetUserFromRefreshToken() = %v, %v", user.ID, err)
+	}
+	_, err = q.GetUserFromRefreshToken(ctx, "expired")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected an expired token to be rejected, got %v", err)
+	}
+	err = q.RevokeUserRefreshTokens(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = q.GetUserFromRefreshToken(ctx, "valid")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected a revoked token to be rejected, got %v", err)
+	}
+}
+
+func TestSQLiteLastAdmin(t *testing.T) {
+	ctx := context.Background()
+	_, q := newSQLiteDB(t)
+	var admins []User
+	for _, email := range []string{"alice@example.com", "bob@example.com"} {
+		user, err := q.CreateUser(ctx, CreateUserParams{Email: email})
+		if err != nil {
+			t.Fatal(err)
+		}
+		user, err = q.UpdateUserRole(ctx, UpdateUserRoleParams{ID: user.ID, Role: "admin"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		admins = append(admins, user)
+	}
+
+	_, err := q.UpdateUserRole(ctx, UpdateUserRoleParams{ID: admins[0].ID, Role: "moderator"})
+	if err != nil {
+		t.Fatalf("Expected an admin to be demoted while another is left, got %v", err)
+	}
+	_, err = q.UpdateUserRole(ctx, UpdateUserRoleParams{ID: admins[1].ID, Role: "user"})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected the last admin not to be demoted, got %v", err)
+	}
+	_, err = q.UpdateUserRole(ctx, UpdateUserRoleParams{ID: admins[1].ID, Role: "admin"})
+	if err != nil {
+		t.Errorf("Expected the last admin to keep the admin role, got %v", err)
+	}
+}
+
+func TestSQLiteUpserts(t *testing.T) {
+	ctx := context.Background()
+	_, q := newSQLiteDB(t)
+
+	throttle, err := q.RecordLoginFailure(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	throttle, err = q.RecordLoginFailure(ctx, "alice@example.com")
+	if err != nil || throttle.Failures != 2 {
+		t.Errorf("RecordLoginFailure() = %+v, %v", throttle, err)
+	}
+
+	event := CreateWebhookEventParams{Provider: "polka", EventID: "evt_1", EventType: "user.upgraded", Payload: "{}"}
+	created, err := q.CreateWebhookEvent(ctx, event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = q.CreateWebhookEvent(ctx, event)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows for a duplicate event, got %v", err)
+	}
+
+	alice, err := q.CreateUser(ctx, CreateUserParams{Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	sub, err := q.CreateSubscription(ctx, CreateSubscriptionParams{
+		UserID:             alice.ID,
+		Plan:               "chirpy_red_monthly",
+		CurrentPeriodStart: now,
+		CurrentPeriodEnd:   now,
+		GracePeriodEnd:     now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewal := CreateSubscriptionRenewalParams{WebhookEventID: created.ID, SubscriptionID: sub.ID}
+	_, err = q.CreateSubscriptionRenewal(ctx, renewal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = q.CreateSubscriptionRenewal(ctx, renewal)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows for a duplicate renewal, got %v", err)
+	}
+
+	for _, want := range []int64{1, 0} {
+		got, err := q.RecordWebhookSignature(ctx, "signature")
+		if err != nil || got != want {
+			t.Errorf("RecordWebhookSignature() = %d, %v, want %d", got, err, want)
+		}
+	}
+}
+
+func TestSQLiteWebhookDeliveries(t *testing.T) {
+	ctx := context.Background()
+	_, q := newSQLiteDB(t)
+	endpoint, err := q.CreateWebhookEndpoint(ctx, CreateWebhookEndpointParams{Url: "https://example.com/hook", Secret: "secret", Events: "*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		_, err = q.CreateWebhookDelivery(ctx, CreateWebhookDeliveryParams{EndpointID: endpoint.ID, EventID: uuid.New(), EventType: "chirp.created", Payload: "{}"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lease := time.Now().Add(time.Minute)
+	claimed, err := q.ClaimDueWebhookDeliveries(ctx, ClaimDueWebhookDeliveriesParams{NextAttemptAt: lease, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 2 || claimed[0].Attempts != 1 {
+		t.Errorf("Expected 2 deliveries claimed once, got %+v", claimed)
+	}
+	claimed, _ = q.ClaimDueWebhookDeliveries(ctx, ClaimDueWebhookDeliveriesParams{NextAttemptAt: lease, Limit: 2})
+	if len(claimed) != 1 {
+		t.Errorf("Expected leased deliveries to be skipped, got %+v", claimed)
+	}
+}
+
+func TestSQLiteWebhookEventClaims(t *testing.T) {
+	ctx := context.Background()
+	_, q := newSQLiteDB(t)
+	event, err := q.CreateWebhookEvent(ctx, CreateWebhookEventParams{Provider: "polka", EventID: "evt-1", EventType: "user.upgraded", Payload: "{}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaseStart := time.Now().Add(-5 * time.Minute)
+	claimed, err := q.ClaimWebhookEvent(ctx, ClaimWebhookEventParams{ID: event.ID, StaleBefore: leaseStart})
+	if err != nil || !claimed.ClaimedAt.Valid {
+		t.Fatalf("ClaimWebhookEvent() = %+v, %v", claimed, err)
+	}
+	_, err = q.ClaimWebhookEvent(ctx, ClaimWebhookEventParams{ID: event.ID, StaleBefore: leaseStart})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected a claimed event not to be claimed again, got %v", err)
+	}
+	_, err = q.ClaimWebhookEventForReplay(ctx, ClaimWebhookEventForReplayParams{ID: event.ID, StaleBefore: leaseStart})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected a claimed event not to be replayed, got %v", err)
+	}
+
+	// Once the claim is older than the lease, the event can be claimed again.
+	reclaimed, err := q.ClaimWebhookEvent(ctx, ClaimWebhookEventParams{ID: event.ID, StaleBefore: time.Now().Add(time.Second)})
+	if err != nil || reclaimed.Attempts != 2 {
+		t.Errorf("Expected an abandoned claim to be taken over, got %+v, %v", reclaimed, err)
+	}
+}
+
+func TestSQLiteReportOutlivesChirp(t *testing.T) {
+	ctx := context.Background()
+	db, q := newSQLiteDB(t)
+	alice, err := q.CreateUser(ctx, CreateUserParams{Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirp, err := q.CreateChirp(ctx, CreateChirpParams{Body: "reported", UserID: alice.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := q.CreateReport(ctx, CreateReportParams{
+		UserID:    alice.ID,
+		ChirpID:   uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ChirpBody: chirp.Body,
+		Reason:    "spam",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = q.CreateModerationAction(ctx, CreateModerationActionParams{
+		ReportID: uuid.NullUUID{UUID: report.ID, Valid: true},
+		Action:   "dismiss",
+		UserID:   alice.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rebuilding the reports table mustn't unlink the actions taken on them.
+	schema := os.DirFS("../../sql/sqlite/schema")
+	for {
+		migration, _, err := migrations.Down(ctx, db, migrations.SQLite, schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if migration.Version == 21 {
+			break
+		}
+	}
+	_, err = migrations.Up(ctx, db, migrations.SQLite, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions, err := q.GetModerationActionsByReport(ctx, uuid.NullUUID{UUID: report.ID, Valid: true})
+	if err != nil || len(actions) != 1 {
+		t.Fatalf("Expected the moderation action to keep its report, got %d, %v", len(actions), err)
+	}
+	if actions[0].ModeratorType != "admin_api_key" {
+		t.Errorf("Expected an action without a moderator to be attributed to the admin API key, got %q", actions[0].ModeratorType)
+	}
+
+	err = q.DeleteChirp(ctx, chirp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = q.GetReport(ctx, report.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ChirpID.UUID != chirp.ID || report.ChirpBody != "reported" {
+		t.Errorf("Expected the report to keep the deleted chirp, got %+v", report)
+	}
+	reports, err := q.GetOpenReportsForUser(ctx, alice.ID)
+	if err != nil || len(reports) != 0 {
+		t.Errorf("Expected no reports of the user, got %d, %v", len(reports), err)
+	}
+}
+
+func TestSQLiteAuditLog(t *testing.T) {
+	ctx := context.Background()
+	db, q := newSQLiteDB(t)
+	actor := uuid.New()
+	for _, action := range []string{"user.login", "chirp.deleted"} {
+		err := q.CreateAuditEntry(ctx, CreateAuditEntryParams{Action: action, ActorType: "user", ActorID: uuid.NullUUID{UUID: actor, Valid: true}, Details: "{}"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := q.GetAuditEntries(ctx, GetAuditEntriesParams{MaxEntries: 10})
+	if err != nil || len(entries) != 2 || entries[0].ID >= entries[1].ID {
+		t.Errorf("GetAuditEntries() = %+v, %v", entries, err)
+	}
+	entries, _ = q.GetAuditEntries(ctx, GetAuditEntriesParams{
+		Action:     sql.NullString{String: "chirp.deleted", Valid: true},
+		ActorID:    uuid.NullUUID{UUID: actor, Valid: true},
+		Since:      sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+		Until:      sql.NullTime{Time: time.Now().Add(time.Minute), Valid: true},
+		MaxEntries: 10,
+	})
+	if len(entries) != 1 || entries[0].Action != "chirp.deleted" {
+		t.Errorf("Expected the filters to match one entry, got %+v", entries)
+	}
+	entries, err = q.GetRecentAuditEntries(ctx, GetRecentAuditEntriesParams{MaxEntries: 10})
+	if err != nil || len(entries) != 2 || entries[0].ID <= entries[1].ID {
+		t.Errorf("GetRecentAuditEntries() = %+v, %v", entries, err)
+	}
+	entries, _ = q.GetRecentAuditEntries(ctx, GetRecentAuditEntriesParams{BeforeID: sql.NullInt64{Int64: entries[0].ID, Valid: true}, MaxEntries: 10})
+	if len(entries) != 1 || entries[0].Action != "user.login" {
+		t.Errorf("Expected the entry before the newest one, got %+v", entries)
+	}
+
+	for _, stmt := range []string{"UPDATE audit_log SET action = 'changed'", "DELETE FROM audit_log"} {
+		_, err = db.Exec(stmt)
+		if err == nil {
+			t.Errorf("Expected %q to be rejected", stmt)
+		}
+	}
+}
+
+func TestSQLiteMigrateDown(t *testing.T) {
+	ctx := context.Background()
+	db, _ := newSQLiteDB(t)
+	schema := os.DirFS("../../sql/sqlite/schema")
+	for {
+		_, found, err := migrations.Down(ctx, db, migrations.SQLite, schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found {
+			break
+		}
+	}
+	var tables int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name != 'goose_db_version'").Scan(&tables)
+	if err != nil || tables != 0 {
+		t.Errorf("Expected every table to be dropped, got %d, %v", tables, err)
+	}
+	applied, err := migrations.Up(ctx, db, migrations.SQLite, schema)
+	if err != nil || len(applied) == 0 {
+		t.Errorf("Up() after rolling back = %v, %v", applied, err)
+	}
+}
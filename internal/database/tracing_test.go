@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestQueryName(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: createAuditEntry, want: "CreateAuditEntry"},
+		{query: "-- name: GetChirps :many\nSELECT 1", want: "GetChirps"},
+		{query: "SELECT 1", want: "query"},
+		{query: "-- name: ", want: "query"},
+	}
+	for _, tt := range tests {
+		if got := QueryName(tt.query); got != tt.want {
+			t.Errorf("QueryName(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+type fakeDB struct {
+	DBTX
+	err error
+}
+
+func (db fakeDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func TestTracingDBExec(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	q := New(NewTracingDB(fakeDB{}, tracer))
+	err := q.DeleteAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q = New(NewTracingDB(fakeDB{err: errors.New("connection refused")}, tracer))
+	err = q.DeleteAll(context.Background())
+	if err == nil {
+		t.Fatal("Expected the error to be passed through")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Name() != "db DeleteAll" {
+		t.Errorf("Unexpected span name %q", spans[0].Name())
+	}
+	if spans[0].Status().Code == codes.Error {
+		t.Error("Expected a successful query not to be marked as failed")
+	}
+	if spans[1].Status().Code != codes.Error {
+		t.Error("Expected a failed query to be marked as failed")
+	}
+}
@@ -7,10 +7,22 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
+const countUsersByRole = `-- name: CountUsersByRole :one
+SELECT COUNT(*) FROM users WHERE role = $1
+`
+
+func (q *Queries) CountUsersByRole(ctx context.Context, role string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsersByRole, role)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
@@ -20,12 +32,12 @@ VALUES (
             $1,
             $2
        )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, suspended_until, role, suspended_at, shadowbanned_at
 `
 
 type CreateUserParams struct {
 	Email          string
-	HashedPassword string
+	HashedPassword sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -37,7 +49,46 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.ShadowbannedAt,
+	)
+	return i, err
+}
+
+const createUserWithRole = `-- name: CreateUserWithRole :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, role)
+VALUES (
+           gen_random_uuid(),
+            NOW(),
+            NOW(),
+            $1,
+            $2,
+            $3
+       )
+RETURNING id, created_at, updated_at, email, hashed_password, suspended_until, role, suspended_at, shadowbanned_at
+`
+
+type CreateUserWithRoleParams struct {
+	Email          string
+	HashedPassword sql.NullString
+	Role           string
+}
+
+func (q *Queries) CreateUserWithRole(ctx context.Context, arg CreateUserWithRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, createUserWithRole, arg.Email, arg.HashedPassword, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.ShadowbannedAt,
 	)
 	return i, err
 }
@@ -51,8 +102,29 @@ func (q *Queries) DeleteAll(ctx context.Context) error {
 	return err
 }
 
+const getUser = `-- name: GetUser :one
+SELECT id, created_at, updated_at, email, hashed_password, suspended_until, role, suspended_at, shadowbanned_at FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.ShadowbannedAt,
+	)
+	return i, err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red from users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, suspended_until, role, suspended_at, shadowbanned_at from users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -64,7 +136,107 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.ShadowbannedAt,
+	)
+	return i, err
+}
+
+const shadowbanUser = `-- name: ShadowbanUser :one
+UPDATE users SET updated_at = NOW(), shadowbanned_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, suspended_until, role, suspended_at, shadowbanned_at
+`
+
+func (q *Queries) ShadowbanUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, shadowbanUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.ShadowbannedAt,
+	)
+	return i, err
+}
+
+const suspendUser = `-- name: SuspendUser :one
+UPDATE users SET updated_at = NOW(), suspended_at = NOW(), suspended_until = $2
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, suspended_until, role, suspended_at, shadowbanned_at
+`
+
+type SuspendUserParams struct {
+	ID             uuid.UUID
+	SuspendedUntil sql.NullTime
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, suspendUser, arg.ID, arg.SuspendedUntil)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.ShadowbannedAt,
+	)
+	return i, err
+}
+
+const unshadowbanUser = `-- name: UnshadowbanUser :one
+UPDATE users SET updated_at = NOW(), shadowbanned_at = NULL
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, suspended_until, role, suspended_at, shadowbanned_at
+`
+
+func (q *Queries) UnshadowbanUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, unshadowbanUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.ShadowbannedAt,
+	)
+	return i, err
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :one
+UPDATE users SET updated_at = NOW(), suspended_at = NULL, suspended_until = NULL
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, suspended_until, role, suspended_at, shadowbanned_at
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, unsuspendUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.ShadowbannedAt,
 	)
 	return i, err
 }
@@ -72,13 +244,13 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 const updateEmailAndPassword = `-- name: UpdateEmailAndPassword :one
 UPDATE users SET email = $2, hashed_password = $3, updated_at = NOW()
 where id =$1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, suspended_until, role, suspended_at, shadowbanned_at
 `
 
 type UpdateEmailAndPasswordParams struct {
 	ID             uuid.UUID
 	Email          string
-	HashedPassword string
+	HashedPassword sql.NullString
 }
 
 func (q *Queries) UpdateEmailAndPassword(ctx context.Context, arg UpdateEmailAndPasswordParams) (User, error) {
@@ -90,19 +262,49 @@ func (q *Queries) UpdateEmailAndPassword(ctx context.Context, arg UpdateEmailAnd
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.ShadowbannedAt,
 	)
 	return i, err
 }
 
-const upgradeToRed = `-- name: UpgradeToRed :one
-UPDATE users SET is_chirpy_red = true, updated_at = NOW()
+const updatePasswordHash = `-- name: UpdatePasswordHash :exec
+UPDATE users SET hashed_password = $2
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
 `
 
-func (q *Queries) UpgradeToRed(ctx context.Context, id uuid.UUID) (User, error) {
-	row := q.db.QueryRowContext(ctx, upgradeToRed, id)
+type UpdatePasswordHashParams struct {
+	ID             uuid.UUID
+	HashedPassword sql.NullString
+}
+
+func (q *Queries) UpdatePasswordHash(ctx context.Context, arg UpdatePasswordHashParams) error {
+	_, err := q.db.ExecContext(ctx, updatePasswordHash, arg.ID, arg.HashedPassword)
+	return err
+}
+
+const updateUserRole = `-- name: UpdateUserRole :one
+WITH admins AS (
+    SELECT id FROM users WHERE role = 'admin' ORDER BY id FOR UPDATE
+)
+UPDATE users SET updated_at = NOW(), role = $2
+WHERE id = $1
+AND (role <> 'admin' OR $2 = 'admin' OR (SELECT COUNT(*) FROM admins) > 1)
+RETURNING id, created_at, updated_at, email, hashed_password, suspended_until, role, suspended_at, shadowbanned_at
+`
+
+type UpdateUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+// The last admin can't be demoted: no row is returned. Locking every admin first, in a fixed
+// order, makes concurrent demotions of different admins wait for each other instead of both
+// seeing the other one still in place.
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserRole, arg.ID, arg.Role)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -110,7 +312,10 @@ func (q *Queries) UpgradeToRed(ctx context.Context, id uuid.UUID) (User, error)
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.SuspendedUntil,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.ShadowbannedAt,
 	)
 	return i, err
 }
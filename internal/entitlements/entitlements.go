@@ -0,0 +1,83 @@
+package entitlements
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Tiers a user can be on.
+const (
+	TierFree      = "free"
+	TierChirpyRed = "chirpy_red"
+)
+
+// Entitlements are the perks granted to a tier. Handlers only ever read these values, so the
+// product team can change what a tier gets without touching them.
+type Entitlements struct {
+	MaxChirpLength  int    `json:"max_chirp_length"`
+	CanEditChirps   bool   `json:"can_edit_chirps"`
+	MaxPinnedChirps int    `json:"max_pinned_chirps"`
+	ChirpsPerHour   int    `json:"chirps_per_hour"`
+	Badge           string `json:"badge"`
+}
+
+// Table maps each tier to its entitlements.
+type Table map[string]Entitlements
+
+// Default is used when no entitlement file is configured.
+var Default = Table{
+	TierFree: {
+		MaxChirpLength:  140,
+		CanEditChirps:   false,
+		MaxPinnedChirps: 1,
+		ChirpsPerHour:   30,
+	},
+	TierChirpyRed: {
+		MaxChirpLength:  500,
+		CanEditChirps:   true,
+		MaxPinnedChirps: 5,
+		ChirpsPerHour:   300,
+		Badge:           "chirpy_red",
+	},
+}
+
+// Load reads a table from a JSON file keyed by tier. Every tier must be present.
+func Load(path string) (Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read entitlements: %w", err)
+	}
+	table := Table{}
+	err = json.Unmarshal(data, &table)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse entitlements: %w", err)
+	}
+	err = table.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Validate checks that every tier is defined with usable limits.
+func (t Table) Validate() error {
+	for _, tier := range []string{TierFree, TierChirpyRed} {
+		e, ok := t[tier]
+		if !ok {
+			return fmt.Errorf("entitlements for tier %q are missing", tier)
+		}
+		if e.MaxChirpLength <= 0 || e.MaxPinnedChirps < 0 || e.ChirpsPerHour <= 0 {
+			return fmt.Errorf("entitlements for tier %q have invalid limits", tier)
+		}
+	}
+	return nil
+}
+
+// For returns the entitlements of a user depending on their Chirpy Red membership.
+func (t Table) For(isChirpyRed bool) Entitlements {
+	if isChirpyRed {
+		return t[TierChirpyRed]
+	}
+	return t[TierFree]
+}
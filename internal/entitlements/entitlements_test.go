@@ -0,0 +1,68 @@
+package entitlements
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultTable(t *testing.T) {
+	err := Default.Validate()
+	if err != nil {
+		t.Fatalf("Expected default table to be valid, got %v", err)
+	}
+	free, red := Default.For(false), Default.For(true)
+	if red.MaxChirpLength <= free.MaxChirpLength {
+		t.Errorf("Expected Chirpy Red to allow longer chirps, got %d <= %d", red.MaxChirpLength, free.MaxChirpLength)
+	}
+	if free.CanEditChirps || !red.CanEditChirps {
+		t.Error("Expected only Chirpy Red to edit chirps")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name:    "Valid table",
+			content: `{"free": {"max_chirp_length": 100, "chirps_per_hour": 10}, "chirpy_red": {"max_chirp_length": 1000, "can_edit_chirps": true, "max_pinned_chirps": 3, "chirps_per_hour": 50, "badge": "red"}}`,
+			wantErr: false,
+		},
+		{
+			name:    "Missing tier",
+			content: `{"free": {"max_chirp_length": 100, "chirps_per_hour": 10}}`,
+			wantErr: true,
+		},
+		{
+			name:    "Invalid limit",
+			content: `{"free": {"max_chirp_length": 0, "chirps_per_hour": 10}, "chirpy_red": {"max_chirp_length": 1000, "chirps_per_hour": 50}}`,
+			wantErr: true,
+		},
+		{
+			name:    "Malformed JSON",
+			content: `{"free":`,
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, string(rune('a'+i))+".json")
+			err := os.WriteFile(path, []byte(tt.content), 0o600)
+			if err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			table, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && table.For(true).Badge != "red" {
+				t.Errorf("Expected badge %q, got %q", "red", table.For(true).Badge)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Package health runs the dependency checks behind the readiness probe.
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Statuses of a check and of the overall report.
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// Checker reports whether a dependency is usable. Check should return promptly once ctx is done.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to a Checker.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Report is the outcome of all checks. Its Status is only ok when every check passed.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// OK reports whether every check passed.
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Registry holds the checks subsystems register for readiness.
+type Registry struct {
+	// Timeout bounds each check. Zero means the checks only stop when the caller's context is done.
+	Timeout time.Duration
+
+	mu     sync.Mutex
+	checks map[string]Checker
+}
+
+// Register adds a named check, replacing any check registered under the same name.
+func (reg *Registry) Register(name string, checker Checker) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.checks == nil {
+		reg.checks = map[string]Checker{}
+	}
+	reg.checks[name] = checker
+}
+
+// Names returns the names of the registered checks in order.
+func (reg *Registry) Names() []string {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	names := make([]string, 0, len(reg.checks))
+	for name := range reg.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run runs all checks concurrently and waits for them to finish or time out.
+func (reg *Registry) Run(ctx context.Context) Report {
+	reg.mu.Lock()
+	checks := make(map[string]Checker, len(reg.checks))
+	for name, checker := range reg.checks {
+		checks[name] = checker
+	}
+	reg.mu.Unlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, checker := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := reg.run(ctx, checker)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusUnavailable
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func (reg *Registry) run(ctx context.Context, checker Checker) Result {
+	if reg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, reg.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- checker.Check(ctx)
+	}()
+
+	// A check that ignores its context still can't hold up the probe past the timeout.
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := Result{Status: StatusOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusUnavailable
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,58 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	reg := &Registry{Timeout: 50 * time.Millisecond}
+	reg.Register("ok", CheckerFunc(func(ctx context.Context) error { return nil }))
+	report := reg.Run(context.Background())
+	if !report.OK() {
+		t.Fatalf("Expected report to be ok, got %+v", report)
+	}
+
+	reg.Register("down", CheckerFunc(func(ctx context.Context) error { return errors.New("connection refused") }))
+	report = reg.Run(context.Background())
+	if report.OK() {
+		t.Fatal("Expected a failing check to fail the report")
+	}
+	if got := report.Checks["down"]; got.Status != StatusUnavailable || got.Error != "connection refused" {
+		t.Errorf("Unexpected result for failing check: %+v", got)
+	}
+	if got := report.Checks["ok"]; got.Status != StatusOK {
+		t.Errorf("Expected passing check to stay ok, got %+v", got)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	reg := &Registry{Timeout: 20 * time.Millisecond}
+	release := make(chan struct{})
+	defer close(release)
+	reg.Register("stuck", CheckerFunc(func(ctx context.Context) error {
+		<-release
+		return nil
+	}))
+
+	start := time.Now()
+	report := reg.Run(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected a stuck check to time out, took %s", elapsed)
+	}
+	if got := report.Checks["stuck"]; got.Status != StatusUnavailable || got.Error != context.DeadlineExceeded.Error() {
+		t.Errorf("Expected stuck check to time out, got %+v", got)
+	}
+}
+
+func TestNames(t *testing.T) {
+	reg := &Registry{}
+	reg.Register("migrations", CheckerFunc(func(ctx context.Context) error { return nil }))
+	reg.Register("database", CheckerFunc(func(ctx context.Context) error { return nil }))
+	names := reg.Names()
+	if len(names) != 2 || names[0] != "database" || names[1] != "migrations" {
+		t.Errorf("Expected sorted names, got %v", names)
+	}
+}
@@ -0,0 +1,858 @@
+// Package memory implements every Chirpy repository in memory, with the same behavior as the
+// Postgres queries, including the cascades of its foreign keys, for tests and demos that have no
+// database.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/acramatte/Chirpy/internal/auth"
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Store holds all of Chirpy's data. It is safe for concurrent use.
+type Store struct {
+	// Now returns the current time. Tests can replace it before using the Store.
+	Now func() time.Time
+
+	mu            sync.Mutex
+	users         map[uuid.UUID]database.User
+	chirps        map[uuid.UUID]database.Chirp
+	refreshTokens map[string]database.RefreshToken
+	subscriptions map[uuid.UUID]database.Subscription
+	renewals      map[uuid.UUID]database.SubscriptionRenewal
+	throttles     map[string]database.LoginThrottle
+	auditLog      []database.AuditLog
+	// chirpOrder keeps chirps in insertion order so that chirps created at the same time
+	// are listed consistently.
+	chirpOrder []uuid.UUID
+
+	// The remaining tables are slices, in insertion order, for the same reason.
+	pats              []database.PersonalAccessToken
+	oauthClients      []database.OauthClient
+	oauthCodes        []database.OauthAuthorizationCode
+	identities        []database.UserIdentity
+	webhookEndpoints  []database.WebhookEndpoint
+	webhookDeliveries []database.WebhookDelivery
+	webhookEvents     []database.WebhookEvent
+	webhookSignatures map[string]time.Time
+	reports           []database.Report
+	moderationActions []database.ModerationAction
+	notifications     []database.Notification
+	moderationWords   []database.ModerationWord
+}
+
+var (
+	_ database.UserRepository          = (*Store)(nil)
+	_ database.ChirpRepository         = (*Store)(nil)
+	_ database.RefreshTokenRepository  = (*Store)(nil)
+	_ database.SubscriptionRepository  = (*Store)(nil)
+	_ database.LoginThrottleRepository = (*Store)(nil)
+	_ database.AuditLogRepository      = (*Store)(nil)
+
+	_ database.PersonalAccessTokenRepository = (*Store)(nil)
+	_ database.OAuthRepository               = (*Store)(nil)
+	_ database.IdentityRepository            = (*Store)(nil)
+	_ database.WebhookEndpointRepository     = (*Store)(nil)
+	_ database.WebhookEventRepository        = (*Store)(nil)
+	_ database.ReportRepository              = (*Store)(nil)
+	_ database.NotificationRepository        = (*Store)(nil)
+	_ database.ModerationWordRepository      = (*Store)(nil)
+)
+
+// New returns an empty Store.
+func New() *Store {
+	return &Store{
+		Now:           func() time.Time { return time.Now().UTC() },
+		users:         map[uuid.UUID]database.User{},
+		chirps:        map[uuid.UUID]database.Chirp{},
+		refreshTokens: map[string]database.RefreshToken{},
+		subscriptions: map[uuid.UUID]database.Subscription{},
+		renewals:      map[uuid.UUID]database.SubscriptionRenewal{},
+		throttles:     map[string]database.LoginThrottle{},
+
+		webhookSignatures: map[string]time.Time{},
+	}
+}
+
+func nullNow(now time.Time) sql.NullTime {
+	return sql.NullTime{Time: now, Valid: true}
+}
+
+// filter returns a new slice with the items that keep returns true for.
+func filter[T any](items []T, keep func(item T) bool) []T {
+	var kept []T
+	for _, item := range items {
+		if keep(item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// limit truncates items to n, like a LIMIT clause.
+func limit[T any](items []T, n int32) []T {
+	if len(items) > int(n) {
+		return items[:max(n, 0)]
+	}
+	return items
+}
+
+// Users
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	return s.CreateUserWithRole(ctx, database.CreateUserWithRoleParams{Email: arg.Email, HashedPassword: arg.HashedPassword, Role: "user"})
+}
+
+func (s *Store) CreateUserWithRole(ctx context.Context, arg database.CreateUserWithRoleParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.emailTaken(arg.Email, uuid.Nil) {
+		return database.User{}, fmt.Errorf("memory: email %s is already in use", arg.Email)
+	}
+	now := s.Now()
+	user := database.User{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		Role:           arg.Role,
+	}
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (s *Store) emailTaken(email string, except uuid.UUID) bool {
+	for _, user := range s.users {
+		if user.Email == email && user.ID != except {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) GetUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+// updateUser applies update to a user and returns it, like an UPDATE ... RETURNING *.
+func (s *Store) updateUser(id uuid.UUID, update func(user *database.User, now time.Time) error) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	err := update(&user, s.Now())
+	if err != nil {
+		return database.User{}, err
+	}
+	s.users[id] = user
+	return user, nil
+}
+
+func (s *Store) UpdateEmailAndPassword(ctx context.Context, arg database.UpdateEmailAndPasswordParams) (database.User, error) {
+	return s.updateUser(arg.ID, func(user *database.User, now time.Time) error {
+		if s.emailTaken(arg.Email, arg.ID) {
+			return fmt.Errorf("memory: email %s is already in use", arg.Email)
+		}
+		user.Email = arg.Email
+		user.HashedPassword = arg.HashedPassword
+		user.UpdatedAt = now
+		return nil
+	})
+}
+
+func (s *Store) UpdatePasswordHash(ctx context.Context, arg database.UpdatePasswordHashParams) error {
+	_, err := s.updateUser(arg.ID, func(user *database.User, now time.Time) error {
+		user.HashedPassword = arg.HashedPassword
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+func (s *Store) UpdateUserRole(ctx context.Context, arg database.UpdateUserRoleParams) (database.User, error) {
+	return s.updateUser(arg.ID, func(user *database.User, now time.Time) error {
+		if user.Role == auth.RoleAdmin && arg.Role != auth.RoleAdmin && s.countRole(auth.RoleAdmin) <= 1 {
+			return sql.ErrNoRows
+		}
+		user.Role = arg.Role
+		user.UpdatedAt = now
+		return nil
+	})
+}
+
+func (s *Store) CountUsersByRole(ctx context.Context, role string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.countRole(role), nil
+}
+
+func (s *Store) countRole(role string) int64 {
+	var count int64
+	for _, user := range s.users {
+		if user.Role == role {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Store) SuspendUser(ctx context.Context, arg database.SuspendUserParams) (database.User, error) {
+	return s.updateUser(arg.ID, func(user *database.User, now time.Time) error {
+		user.SuspendedAt = nullNow(now)
+		user.SuspendedUntil = arg.SuspendedUntil
+		user.UpdatedAt = now
+		return nil
+	})
+}
+
+func (s *Store) UnsuspendUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	return s.updateUser(id, func(user *database.User, now time.Time) error {
+		user.SuspendedAt = sql.NullTime{}
+		user.SuspendedUntil = sql.NullTime{}
+		user.UpdatedAt = now
+		return nil
+	})
+}
+
+func (s *Store) ShadowbanUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	return s.updateUser(id, func(user *database.User, now time.Time) error {
+		user.ShadowbannedAt = nullNow(now)
+		user.UpdatedAt = now
+		return nil
+	})
+}
+
+func (s *Store) UnshadowbanUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	return s.updateUser(id, func(user *database.User, now time.Time) error {
+		user.ShadowbannedAt = sql.NullTime{}
+		user.UpdatedAt = now
+		return nil
+	})
+}
+
+// DeleteAll deletes every user, and with them, like the foreign keys in Postgres, their chirps,
+// refresh tokens and subscriptions. Login throttles and the audit log aren't tied to users and
+// are kept.
+func (s *Store) DeleteAll(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.users)
+	clear(s.chirps)
+	clear(s.refreshTokens)
+	clear(s.subscriptions)
+	clear(s.renewals)
+	s.chirpOrder = nil
+	// Every row below belongs to a user, except admin webhook endpoints and their deliveries,
+	// incoming webhook events and moderation words.
+	s.pats = nil
+	s.oauthClients = nil
+	s.oauthCodes = nil
+	s.identities = nil
+	s.deleteWebhookEndpoints(func(endpoint database.WebhookEndpoint) bool { return endpoint.UserID.Valid })
+	s.reports = nil
+	s.moderationActions = nil
+	s.notifications = nil
+	return nil
+}
+
+// Chirps
+
+func (s *Store) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.Chirp{}, fmt.Errorf("memory: user %s does not exist", arg.UserID)
+	}
+	now := s.Now()
+	chirp := database.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+	}
+	s.chirps[chirp.ID] = chirp
+	s.chirpOrder = append(s.chirpOrder, chirp.ID)
+	return chirp, nil
+}
+
+func (s *Store) GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chirp, ok := s.chirps[id]
+	if !ok {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+// visible mirrors the visibility conditions of the GetChirps queries.
+func (s *Store) visible(chirp database.Chirp, viewerID uuid.NullUUID, now time.Time) bool {
+	if chirp.HiddenAt.Valid {
+		return false
+	}
+	author := s.users[chirp.UserID]
+	if author.SuspendedAt.Valid && !(author.SuspendedUntil.Valid && !author.SuspendedUntil.Time.After(now)) {
+		return false
+	}
+	if author.ShadowbannedAt.Valid && !(viewerID.Valid && viewerID.UUID == chirp.UserID) {
+		return false
+	}
+	return true
+}
+
+// listChirps returns the chirps matching keep, oldest first unless sortOrder is "desc".
+func (s *Store) listChirps(sortOrder string, keep func(chirp database.Chirp) bool) []database.Chirp {
+	chirps := []database.Chirp{}
+	for _, id := range s.chirpOrder {
+		chirp := s.chirps[id]
+		if keep(chirp) {
+			chirps = append(chirps, chirp)
+		}
+	}
+	slices.SortStableFunc(chirps, func(a, b database.Chirp) int {
+		if sortOrder == "desc" {
+			return b.CreatedAt.Compare(a.CreatedAt)
+		}
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return chirps
+}
+
+func (s *Store) GetChirps(ctx context.Context, arg database.GetChirpsParams) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.Now()
+	return s.listChirps(arg.SortOrder, func(chirp database.Chirp) bool {
+		return s.visible(chirp, arg.ViewerID, now)
+	}), nil
+}
+
+func (s *Store) GetChirpsByAuthorId(ctx context.Context, arg database.GetChirpsByAuthorIdParams) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.Now()
+	return s.listChirps(arg.SortOrder, func(chirp database.Chirp) bool {
+		return chirp.UserID == arg.UserID && s.visible(chirp, arg.ViewerID, now)
+	}), nil
+}
+
+func (s *Store) GetVisibleChirp(ctx context.Context, arg database.GetVisibleChirpParams) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chirp, ok := s.chirps[arg.ID]
+	if !ok || !s.visible(chirp, arg.ViewerID, s.Now()) {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+func (s *Store) GetFlaggedChirps(ctx context.Context) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chirps := s.listChirps("asc", func(chirp database.Chirp) bool {
+		return chirp.FlaggedAt.Valid
+	})
+	slices.SortStableFunc(chirps, func(a, b database.Chirp) int {
+		return a.FlaggedAt.Time.Compare(b.FlaggedAt.Time)
+	})
+	return chirps, nil
+}
+
+// updateChirp applies update to a chirp and returns it, like an UPDATE ... RETURNING *.
+func (s *Store) updateChirp(id uuid.UUID, update func(chirp *database.Chirp, now time.Time)) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chirp, ok := s.chirps[id]
+	if !ok {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	update(&chirp, s.Now())
+	s.chirps[id] = chirp
+	return chirp, nil
+}
+
+func (s *Store) UpdateChirpBody(ctx context.Context, arg database.UpdateChirpBodyParams) (database.Chirp, error) {
+	return s.updateChirp(arg.ID, func(chirp *database.Chirp, now time.Time) {
+		chirp.Body = arg.Body
+		chirp.UpdatedAt = now
+	})
+}
+
+func (s *Store) PinChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	return s.updateChirp(id, func(chirp *database.Chirp, now time.Time) {
+		chirp.PinnedAt = nullNow(now)
+	})
+}
+
+func (s *Store) UnpinChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	return s.updateChirp(id, func(chirp *database.Chirp, now time.Time) {
+		chirp.PinnedAt = sql.NullTime{}
+	})
+}
+
+func (s *Store) FlagChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	return s.updateChirp(id, func(chirp *database.Chirp, now time.Time) {
+		chirp.FlaggedAt = nullNow(now)
+	})
+}
+
+func (s *Store) HideChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	return s.updateChirp(id, func(chirp *database.Chirp, now time.Time) {
+		chirp.HiddenAt = nullNow(now)
+	})
+}
+
+func (s *Store) CountPinnedChirpsByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	for _, chirp := range s.chirps {
+		if chirp.UserID == userID && chirp.PinnedAt.Valid {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) CountChirpsByUserSince(ctx context.Context, arg database.CountChirpsByUserSinceParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	for _, chirp := range s.chirps {
+		if chirp.UserID == arg.UserID && chirp.CreatedAt.After(arg.CreatedAt) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.chirps, id)
+	s.pruneChirpOrder()
+	return nil
+}
+
+func (s *Store) DeleteChirpsByUser(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted := []database.Chirp{}
+	for _, id := range s.chirpOrder {
+		if chirp := s.chirps[id]; chirp.UserID == userID {
+			delete(s.chirps, id)
+			deleted = append(deleted, chirp)
+		}
+	}
+	s.pruneChirpOrder()
+	return deleted, nil
+}
+
+func (s *Store) pruneChirpOrder() {
+	s.chirpOrder = slices.DeleteFunc(s.chirpOrder, func(id uuid.UUID) bool {
+		_, ok := s.chirps[id]
+		return !ok
+	})
+}
+
+// Refresh tokens
+
+func (s *Store) createRefreshToken(token database.RefreshToken) (database.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[token.UserID]; !ok {
+		return database.RefreshToken{}, fmt.Errorf("memory: user %s does not exist", token.UserID)
+	}
+	if _, ok := s.refreshTokens[token.Token]; ok {
+		return database.RefreshToken{}, errors.New("memory: refresh token already exists")
+	}
+	now := s.Now()
+	token.CreatedAt = now
+	token.UpdatedAt = now
+	s.refreshTokens[token.Token] = token
+	return token, nil
+}
+
+func (s *Store) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	return s.createRefreshToken(database.RefreshToken{
+		Token:     arg.Token,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+	})
+}
+
+func (s *Store) CreateOAuthRefreshToken(ctx context.Context, arg database.CreateOAuthRefreshTokenParams) (database.RefreshToken, error) {
+	return s.createRefreshToken(database.RefreshToken{
+		Token:     arg.Token,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+		ClientID:  arg.ClientID,
+		Scopes:    arg.Scopes,
+	})
+}
+
+func (s *Store) GetRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rt, ok := s.refreshTokens[token]
+	if !ok {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	return rt, nil
+}
+
+func (s *Store) active(rt database.RefreshToken) bool {
+	return !rt.RevokedAt.Valid && rt.ExpiresAt.After(s.Now())
+}
+
+func (s *Store) GetUserFromRefreshToken(ctx context.Context, token string) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rt, ok := s.refreshTokens[token]
+	if !ok || rt.ClientID.Valid || !s.active(rt) {
+		return database.User{}, sql.ErrNoRows
+	}
+	user, ok := s.users[rt.UserID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (s *Store) GetActiveOAuthRefreshToken(ctx context.Context, arg database.GetActiveOAuthRefreshTokenParams) (database.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rt, ok := s.refreshTokens[arg.Token]
+	if !ok || !sameID(rt.ClientID, arg.ClientID) || !s.active(rt) {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	return rt, nil
+}
+
+func (s *Store) ConsumeOAuthRefreshToken(ctx context.Context, arg database.ConsumeOAuthRefreshTokenParams) (database.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rt, ok := s.refreshTokens[arg.Token]
+	if !ok || !sameID(rt.ClientID, arg.ClientID) || !s.active(rt) {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	s.revoke(rt)
+	return s.refreshTokens[arg.Token], nil
+}
+
+// sameID compares nullable IDs like SQL does, where NULL equals nothing.
+func sameID(a, b uuid.NullUUID) bool {
+	return a.Valid && b.Valid && a.UUID == b.UUID
+}
+
+func (s *Store) revoke(rt database.RefreshToken) {
+	now := s.Now()
+	rt.RevokedAt = nullNow(now)
+	rt.UpdatedAt = now
+	s.refreshTokens[rt.Token] = rt
+}
+
+func (s *Store) RevokeToken(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rt, ok := s.refreshTokens[token]; ok {
+		s.revoke(rt)
+	}
+	return nil
+}
+
+func (s *Store) RevokeOAuthRefreshToken(ctx context.Context, arg database.RevokeOAuthRefreshTokenParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rt, ok := s.refreshTokens[arg.Token]; ok && sameID(rt.ClientID, arg.ClientID) {
+		s.revoke(rt)
+	}
+	return nil
+}
+
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rt := range s.refreshTokens {
+		if rt.UserID == userID && !rt.RevokedAt.Valid {
+			s.revoke(rt)
+		}
+	}
+	return nil
+}
+
+// Subscriptions
+
+func (s *Store) CreateSubscription(ctx context.Context, arg database.CreateSubscriptionParams) (database.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.Subscription{}, fmt.Errorf("memory: user %s does not exist", arg.UserID)
+	}
+	now := s.Now()
+	sub := database.Subscription{
+		ID:                 uuid.New(),
+		CreatedAt:          now,
+		UpdatedAt:          now,
+		UserID:             arg.UserID,
+		Plan:               arg.Plan,
+		Status:             "active",
+		CurrentPeriodStart: arg.CurrentPeriodStart,
+		CurrentPeriodEnd:   arg.CurrentPeriodEnd,
+		GracePeriodEnd:     arg.GracePeriodEnd,
+	}
+	s.subscriptions[sub.ID] = sub
+	return sub, nil
+}
+
+func (s *Store) GetActiveSubscription(ctx context.Context, userID uuid.UUID) (database.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.Now()
+	var active database.Subscription
+	found := false
+	for _, sub := range s.subscriptions {
+		if sub.UserID != userID || (sub.Status != "active" && sub.Status != "past_due") || !sub.GracePeriodEnd.After(now) {
+			continue
+		}
+		if !found || sub.CurrentPeriodEnd.After(active.CurrentPeriodEnd) {
+			active = sub
+			found = true
+		}
+	}
+	if !found {
+		return database.Subscription{}, sql.ErrNoRows
+	}
+	return active, nil
+}
+
+func (s *Store) GetSubscriptionsByUser(ctx context.Context, userID uuid.UUID) ([]database.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subs := []database.Subscription{}
+	for _, sub := range s.subscriptions {
+		if sub.UserID == userID {
+			subs = append(subs, sub)
+		}
+	}
+	slices.SortFunc(subs, func(a, b database.Subscription) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return subs, nil
+}
+
+// updateSubscription applies update to a subscription and returns it, like an UPDATE ... RETURNING *.
+func (s *Store) updateSubscription(id uuid.UUID, update func(sub *database.Subscription, now time.Time)) (database.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subscriptions[id]
+	if !ok {
+		return database.Subscription{}, sql.ErrNoRows
+	}
+	now := s.Now()
+	update(&sub, now)
+	sub.UpdatedAt = now
+	s.subscriptions[id] = sub
+	return sub, nil
+}
+
+func (s *Store) RenewSubscription(ctx context.Context, arg database.RenewSubscriptionParams) (database.Subscription, error) {
+	return s.updateSubscription(arg.ID, func(sub *database.Subscription, now time.Time) {
+		sub.Status = "active"
+		sub.Plan = arg.Plan
+		sub.CurrentPeriodStart = arg.CurrentPeriodStart
+		sub.CurrentPeriodEnd = arg.CurrentPeriodEnd
+		sub.GracePeriodEnd = arg.GracePeriodEnd
+	})
+}
+
+func (s *Store) MarkSubscriptionPastDue(ctx context.Context, arg database.MarkSubscriptionPastDueParams) (database.Subscription, error) {
+	return s.updateSubscription(arg.ID, func(sub *database.Subscription, now time.Time) {
+		sub.Status = "past_due"
+		sub.GracePeriodEnd = arg.GracePeriodEnd
+	})
+}
+
+func (s *Store) CancelSubscription(ctx context.Context, id uuid.UUID) (database.Subscription, error) {
+	return s.updateSubscription(id, func(sub *database.Subscription, now time.Time) {
+		sub.Status = "canceled"
+		sub.CanceledAt = nullNow(now)
+	})
+}
+
+func (s *Store) CreateSubscriptionRenewal(ctx context.Context, arg database.CreateSubscriptionRenewalParams) (database.SubscriptionRenewal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscriptions[arg.SubscriptionID]; !ok {
+		return database.SubscriptionRenewal{}, fmt.Errorf("memory: subscription %s does not exist", arg.SubscriptionID)
+	}
+	if _, ok := s.renewals[arg.WebhookEventID]; ok {
+		return database.SubscriptionRenewal{}, sql.ErrNoRows
+	}
+	renewal := database.SubscriptionRenewal{
+		WebhookEventID: arg.WebhookEventID,
+		SubscriptionID: arg.SubscriptionID,
+		CreatedAt:      s.Now(),
+	}
+	s.renewals[arg.WebhookEventID] = renewal
+	return renewal, nil
+}
+
+func (s *Store) DeleteSubscriptionRenewal(ctx context.Context, webhookEventID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.renewals, webhookEventID)
+	return nil
+}
+
+// Login throttles
+
+func (s *Store) GetLoginThrottle(ctx context.Context, key string) (database.LoginThrottle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	throttle, ok := s.throttles[key]
+	if !ok {
+		return database.LoginThrottle{}, sql.ErrNoRows
+	}
+	return throttle, nil
+}
+
+func (s *Store) RecordLoginFailure(ctx context.Context, key string) (database.LoginThrottle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.Now()
+	throttle, ok := s.throttles[key]
+	if !ok || throttle.LastFailureAt.Before(now.Add(-24*time.Hour)) {
+		throttle.Key = key
+		throttle.Failures = 0
+	}
+	throttle.Failures++
+	throttle.LastFailureAt = now
+	s.throttles[key] = throttle
+	return throttle, nil
+}
+
+func (s *Store) LockLoginThrottle(ctx context.Context, arg database.LockLoginThrottleParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if throttle, ok := s.throttles[arg.Key]; ok {
+		throttle.LockedUntil = arg.LockedUntil
+		s.throttles[arg.Key] = throttle
+	}
+	return nil
+}
+
+func (s *Store) ClearLoginThrottle(ctx context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.throttles[key]; !ok {
+		return 0, nil
+	}
+	delete(s.throttles, key)
+	return 1, nil
+}
+
+// Audit log
+
+func (s *Store) CreateAuditEntry(ctx context.Context, arg database.CreateAuditEntryParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auditLog = append(s.auditLog, database.AuditLog{
+		ID:         int64(len(s.auditLog) + 1),
+		CreatedAt:  s.Now(),
+		Action:     arg.Action,
+		ActorType:  arg.ActorType,
+		ActorID:    arg.ActorID,
+		TargetType: arg.TargetType,
+		TargetID:   arg.TargetID,
+		Ip:         arg.Ip,
+		RequestID:  arg.RequestID,
+		Details:    arg.Details,
+	})
+	return nil
+}
+
+func (s *Store) GetAuditEntries(ctx context.Context, arg database.GetAuditEntriesParams) ([]database.AuditLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	match := auditFilter{arg.Action, arg.ActorID, arg.TargetID, arg.Since, arg.Until}
+	entries := []database.AuditLog{}
+	for _, entry := range s.auditLog {
+		if len(entries) == int(arg.MaxEntries) {
+			break
+		}
+		if entry.ID > arg.AfterID && match.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (s *Store) GetRecentAuditEntries(ctx context.Context, arg database.GetRecentAuditEntriesParams) ([]database.AuditLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	match := auditFilter{arg.Action, arg.ActorID, arg.TargetID, arg.Since, arg.Until}
+	entries := []database.AuditLog{}
+	for i := len(s.auditLog) - 1; i >= 0; i-- {
+		if len(entries) == int(arg.MaxEntries) {
+			break
+		}
+		entry := s.auditLog[i]
+		if (!arg.BeforeID.Valid || entry.ID < arg.BeforeID.Int64) && match.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// auditFilter holds the filters the audit log queries share. Unset filters match every entry.
+type auditFilter struct {
+	action   sql.NullString
+	actorID  uuid.NullUUID
+	targetID sql.NullString
+	since    sql.NullTime
+	until    sql.NullTime
+}
+
+func (f auditFilter) matches(entry database.AuditLog) bool {
+	switch {
+	case f.action.Valid && entry.Action != f.action.String,
+		f.actorID.Valid && !sameID(entry.ActorID, f.actorID),
+		f.targetID.Valid && entry.TargetID != f.targetID.String,
+		f.since.Valid && entry.CreatedAt.Before(f.since.Time),
+		f.until.Valid && !entry.CreatedAt.Before(f.until.Time):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,365 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func newTestStore(t *testing.T) (*Store, *time.Time) {
+	t.Helper()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := New()
+	s.Now = func() time.Time { return now }
+	return s, &now
+}
+
+func mustCreateUser(t *testing.T, s *Store, email string) database.User {
+	t.Helper()
+	user, err := s.CreateUser(context.Background(), database.CreateUserParams{Email: email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func mustCreateChirp(t *testing.T, s *Store, userID uuid.UUID, body string) database.Chirp {
+	t.Helper()
+	chirp, err := s.CreateChirp(context.Background(), database.CreateChirpParams{Body: body, UserID: userID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chirp
+}
+
+func bodies(chirps []database.Chirp) []string {
+	out := make([]string, 0, len(chirps))
+	for _, c := range chirps {
+		out = append(out, c.Body)
+	}
+	return out
+}
+
+func TestUsers(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	alice := mustCreateUser(t, s, "alice@example.com")
+	if alice.Role != "user" {
+		t.Errorf("Expected new users to have the user role, got %q", alice.Role)
+	}
+	_, err := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com"})
+	if err == nil {
+		t.Error("Expected duplicate emails to be rejected")
+	}
+
+	got, err := s.GetUserByEmail(ctx, "alice@example.com")
+	if err != nil || got.ID != alice.ID {
+		t.Errorf("GetUserByEmail() = %v, %v", got.ID, err)
+	}
+	_, err = s.GetUser(ctx, uuid.New())
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows for an unknown user, got %v", err)
+	}
+
+	_, err = s.UpdateUserRole(ctx, database.UpdateUserRoleParams{ID: alice.ID, Role: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	admins, _ := s.CountUsersByRole(ctx, "admin")
+	if admins != 1 {
+		t.Errorf("Expected 1 admin, got %d", admins)
+	}
+	_, err = s.UpdateUserRole(ctx, database.UpdateUserRoleParams{ID: alice.ID, Role: "user"})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected the last admin not to be demoted, got %v", err)
+	}
+}
+
+func TestChirpVisibility(t *testing.T) {
+	ctx := context.Background()
+	s, now := newTestStore(t)
+	alice := mustCreateUser(t, s, "alice@example.com")
+	bob := mustCreateUser(t, s, "bob@example.com")
+	mustCreateChirp(t, s, alice.ID, "first")
+	*now = now.Add(time.Minute)
+	bobs := mustCreateChirp(t, s, bob.ID, "second")
+	*now = now.Add(time.Minute)
+	hidden := mustCreateChirp(t, s, alice.ID, "third")
+	_, err := s.HideChirp(ctx, hidden.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chirps, _ := s.GetChirps(ctx, database.GetChirpsParams{SortOrder: "desc"})
+	if got := bodies(chirps); len(got) != 2 || got[0] != "second" || got[1] != "first" {
+		t.Errorf("Expected visible chirps newest first, got %v", got)
+	}
+
+	_, err = s.ShadowbanUser(ctx, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirps, _ = s.GetChirps(ctx, database.GetChirpsParams{SortOrder: "asc"})
+	if got := bodies(chirps); len(got) != 1 || got[0] != "first" {
+		t.Errorf("Expected a shadowbanned author's chirps to be hidden, got %v", got)
+	}
+	_, err = s.GetVisibleChirp(ctx, database.GetVisibleChirpParams{ID: bobs.ID, ViewerID: uuid.NullUUID{UUID: bob.ID, Valid: true}})
+	if err != nil {
+		t.Errorf("Expected a shadowbanned author to see their own chirp, got %v", err)
+	}
+
+	until := now.Add(time.Hour)
+	_, err = s.SuspendUser(ctx, database.SuspendUserParams{ID: alice.ID, SuspendedUntil: sql.NullTime{Time: until, Valid: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirps, _ = s.GetChirpsByAuthorId(ctx, database.GetChirpsByAuthorIdParams{UserID: alice.ID, ViewerID: uuid.NullUUID{UUID: alice.ID, Valid: true}})
+	if len(chirps) != 0 {
+		t.Errorf("Expected a suspended author's chirps to be hidden, got %v", bodies(chirps))
+	}
+	*now = until
+	chirps, _ = s.GetChirpsByAuthorId(ctx, database.GetChirpsByAuthorIdParams{UserID: alice.ID})
+	if got := bodies(chirps); len(got) != 1 || got[0] != "first" {
+		t.Errorf("Expected chirps to reappear when the suspension ends, got %v", got)
+	}
+}
+
+func TestChirpWrites(t *testing.T) {
+	ctx := context.Background()
+	s, now := newTestStore(t)
+	alice := mustCreateUser(t, s, "alice@example.com")
+	_, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "orphan", UserID: uuid.New()})
+	if err == nil {
+		t.Error("Expected a chirp by an unknown user to be rejected")
+	}
+
+	chirp := mustCreateChirp(t, s, alice.ID, "hello")
+	*now = now.Add(time.Minute)
+	chirp, err = s.UpdateChirpBody(ctx, database.UpdateChirpBodyParams{ID: chirp.ID, Body: "hello, world"})
+	if err != nil || chirp.Body != "hello, world" || !chirp.UpdatedAt.Equal(*now) {
+		t.Errorf("UpdateChirpBody() = %+v, %v", chirp, err)
+	}
+	_, err = s.PinChirp(ctx, chirp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned, _ := s.CountPinnedChirpsByUser(ctx, alice.ID)
+	recent, _ := s.CountChirpsByUserSince(ctx, database.CountChirpsByUserSinceParams{UserID: alice.ID, CreatedAt: now.Add(-2 * time.Minute)})
+	if pinned != 1 || recent != 1 {
+		t.Errorf("Expected 1 pinned and 1 recent chirp, got %d and %d", pinned, recent)
+	}
+	_, err = s.PinChirp(ctx, uuid.New())
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows pinning an unknown chirp, got %v", err)
+	}
+
+	mustCreateChirp(t, s, alice.ID, "again")
+	deleted, _ := s.DeleteChirpsByUser(ctx, alice.ID)
+	if len(deleted) != 2 {
+		t.Errorf("Expected 2 chirps deleted, got %v", bodies(deleted))
+	}
+	chirps, _ := s.GetChirps(ctx, database.GetChirpsParams{})
+	if len(chirps) != 0 {
+		t.Errorf("Expected no chirps left, got %v", bodies(chirps))
+	}
+}
+
+func TestRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	s, now := newTestStore(t)
+	alice := mustCreateUser(t, s, "alice@example.com")
+	client := uuid.NullUUID{UUID: uuid.New(), Valid: true}
+
+	_, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "session", UserID: alice.ID, ExpiresAt: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.CreateOAuthRefreshToken(ctx, database.CreateOAuthRefreshTokenParams{Token: "oauth", UserID: alice.ID, ExpiresAt: now.Add(time.Hour), ClientID: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := s.GetUserFromRefreshToken(ctx, "session")
+	if err != nil || user.ID != alice.ID {
+		t.Errorf("GetUserFromRefreshToken() = %v, %v", user.ID, err)
+	}
+	_, err = s.GetUserFromRefreshToken(ctx, "oauth")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected OAuth tokens not to start login sessions, got %v", err)
+	}
+	_, err = s.GetActiveOAuthRefreshToken(ctx, database.GetActiveOAuthRefreshTokenParams{Token: "oauth", ClientID: uuid.NullUUID{UUID: uuid.New(), Valid: true}})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected another client's token to be rejected, got %v", err)
+	}
+
+	_, err = s.ConsumeOAuthRefreshToken(ctx, database.ConsumeOAuthRefreshTokenParams{Token: "oauth", ClientID: client})
+	if err != nil {
+		t.Errorf("ConsumeOAuthRefreshToken() = %v", err)
+	}
+	_, err = s.ConsumeOAuthRefreshToken(ctx, database.ConsumeOAuthRefreshTokenParams{Token: "oauth", ClientID: client})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected a consumed token not to be consumed again, got %v", err)
+	}
+
+	*now = now.Add(2 * time.Hour)
+	_, err = s.GetUserFromRefreshToken(ctx, "session")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected an expired token to be rejected, got %v", err)
+	}
+
+	err = s.RevokeUserRefreshTokens(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{"session", "oauth"} {
+		rt, err := s.GetRefreshToken(ctx, token)
+		if err != nil || !rt.RevokedAt.Valid {
+			t.Errorf("Expected %s to be revoked, got %+v, %v", token, rt, err)
+		}
+	}
+
+	err = s.DeleteAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.GetRefreshToken(ctx, "session")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected DeleteAll to delete refresh tokens, got %v", err)
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	s, now := newTestStore(t)
+	alice := mustCreateUser(t, s, "alice@example.com")
+
+	_, err := s.GetActiveSubscription(ctx, alice.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected no active subscription, got %v", err)
+	}
+	sub, err := s.CreateSubscription(ctx, database.CreateSubscriptionParams{
+		UserID:             alice.ID,
+		Plan:               "monthly",
+		CurrentPeriodStart: *now,
+		CurrentPeriodEnd:   now.Add(time.Hour),
+		GracePeriodEnd:     now.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.MarkSubscriptionPastDue(ctx, database.MarkSubscriptionPastDueParams{ID: sub.ID, GracePeriodEnd: now.Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*now = now.Add(90 * time.Minute)
+	active, err := s.GetActiveSubscription(ctx, alice.ID)
+	if err != nil || active.Status != "past_due" {
+		t.Errorf("Expected a past due subscription in its grace period, got %+v, %v", active, err)
+	}
+	*now = now.Add(time.Hour)
+	_, err = s.GetActiveSubscription(ctx, alice.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected the grace period to end, got %v", err)
+	}
+
+	canceled, err := s.CancelSubscription(ctx, sub.ID)
+	if err != nil || canceled.Status != "canceled" || !canceled.CanceledAt.Valid {
+		t.Errorf("CancelSubscription() = %+v, %v", canceled, err)
+	}
+	subs, _ := s.GetSubscriptionsByUser(ctx, alice.ID)
+	if len(subs) != 1 {
+		t.Errorf("Expected 1 subscription, got %d", len(subs))
+	}
+
+	err = s.DeleteAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs, _ = s.GetSubscriptionsByUser(ctx, alice.ID)
+	if len(subs) != 0 {
+		t.Errorf("Expected DeleteAll to delete subscriptions, got %d", len(subs))
+	}
+}
+
+func TestLoginThrottles(t *testing.T) {
+	ctx := context.Background()
+	s, now := newTestStore(t)
+
+	for range 3 {
+		_, err := s.RecordLoginFailure(ctx, "email:alice@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := s.LockLoginThrottle(ctx, database.LockLoginThrottleParams{
+		Key:         "email:alice@example.com",
+		LockedUntil: sql.NullTime{Time: now.Add(time.Minute), Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	throttle, err := s.GetLoginThrottle(ctx, "email:alice@example.com")
+	if err != nil || throttle.Failures != 3 || !throttle.LockedUntil.Valid {
+		t.Errorf("GetLoginThrottle() = %+v, %v", throttle, err)
+	}
+
+	*now = now.Add(25 * time.Hour)
+	throttle, _ = s.RecordLoginFailure(ctx, "email:alice@example.com")
+	if throttle.Failures != 1 {
+		t.Errorf("Expected failures to start over after a day, got %d", throttle.Failures)
+	}
+
+	cleared, _ := s.ClearLoginThrottle(ctx, "email:alice@example.com")
+	if cleared != 1 {
+		t.Errorf("Expected 1 throttle cleared, got %d", cleared)
+	}
+	cleared, _ = s.ClearLoginThrottle(ctx, "email:alice@example.com")
+	if cleared != 0 {
+		t.Errorf("Expected no throttle left to clear, got %d", cleared)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	actor := uuid.NullUUID{UUID: uuid.New(), Valid: true}
+
+	for _, action := range []string{"user.login", "user.login", "token.revoke"} {
+		err := s.CreateAuditEntry(ctx, database.CreateAuditEntryParams{Action: action, ActorType: "user", ActorID: actor})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, _ := s.GetAuditEntries(ctx, database.GetAuditEntriesParams{
+		Action:     sql.NullString{String: "user.login", Valid: true},
+		ActorID:    actor,
+		MaxEntries: 10,
+	})
+	if len(entries) != 2 || entries[0].ID != 1 || entries[1].ID != 2 {
+		t.Errorf("Expected the two logins, got %+v", entries)
+	}
+	entries, _ = s.GetAuditEntries(ctx, database.GetAuditEntriesParams{AfterID: 1, MaxEntries: 1})
+	if len(entries) != 1 || entries[0].ID != 2 {
+		t.Errorf("Expected to page from entry 2, got %+v", entries)
+	}
+
+	entries, _ = s.GetRecentAuditEntries(ctx, database.GetRecentAuditEntriesParams{MaxEntries: 2})
+	if len(entries) != 2 || entries[0].ID != 3 || entries[1].ID != 2 {
+		t.Errorf("Expected the two newest entries, got %+v", entries)
+	}
+	entries, _ = s.GetRecentAuditEntries(ctx, database.GetRecentAuditEntriesParams{
+		BeforeID:   sql.NullInt64{Int64: 3, Valid: true},
+		Action:     sql.NullString{String: "user.login", Valid: true},
+		MaxEntries: 10,
+	})
+	if len(entries) != 2 || entries[0].ID != 2 || entries[1].ID != 1 {
+		t.Errorf("Expected the logins before entry 3, newest first, got %+v", entries)
+	}
+}
@@ -0,0 +1,224 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Reports
+
+func (s *Store) CreateReport(ctx context.Context, arg database.CreateReportParams) (database.Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.Report{}, fmt.Errorf("memory: user %s does not exist", arg.UserID)
+	}
+	now := s.Now()
+	report := database.Report{
+		ID:         uuid.New(),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		ReporterID: arg.ReporterID,
+		UserID:     arg.UserID,
+		ChirpID:    arg.ChirpID,
+		ChirpBody:  arg.ChirpBody,
+		Reason:     arg.Reason,
+		Details:    arg.Details,
+		Status:     "open",
+	}
+	s.reports = append(s.reports, report)
+	return report, nil
+}
+
+func (s *Store) GetReport(ctx context.Context, id uuid.UUID) (database.Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.reports, func(report database.Report) bool { return report.ID == id })
+	if i < 0 {
+		return database.Report{}, sql.ErrNoRows
+	}
+	return s.reports[i], nil
+}
+
+func (s *Store) GetReportsByStatus(ctx context.Context, arg database.GetReportsByStatusParams) ([]database.Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reports := filter(s.reports, func(report database.Report) bool { return report.Status == arg.Status })
+	slices.SortStableFunc(reports, func(a, b database.Report) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return limit(reports, arg.Limit), nil
+}
+
+func (s *Store) GetOpenReportsForChirp(ctx context.Context, chirpID uuid.NullUUID) ([]database.Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return filter(s.reports, func(report database.Report) bool {
+		return sameID(report.ChirpID, chirpID) && report.Status == "open"
+	}), nil
+}
+
+func (s *Store) GetOpenReportsForUser(ctx context.Context, userID uuid.UUID) ([]database.Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return filter(s.reports, func(report database.Report) bool {
+		return report.UserID == userID && !report.ChirpID.Valid && report.Status == "open"
+	}), nil
+}
+
+func (s *Store) ResolveReport(ctx context.Context, arg database.ResolveReportParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.reports {
+		if s.reports[i].ID == arg.ID {
+			now := s.Now()
+			s.reports[i].UpdatedAt = now
+			s.reports[i].Status = arg.Status
+			s.reports[i].ResolvedAt = nullNow(now)
+		}
+	}
+	return nil
+}
+
+// Moderation actions
+
+func (s *Store) CreateModerationAction(ctx context.Context, arg database.CreateModerationActionParams) (database.ModerationAction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.ModerationAction{}, fmt.Errorf("memory: user %s does not exist", arg.UserID)
+	}
+	action := database.ModerationAction{
+		ID:            uuid.New(),
+		CreatedAt:     s.Now(),
+		ReportID:      arg.ReportID,
+		ModeratorID:   arg.ModeratorID,
+		Action:        arg.Action,
+		UserID:        arg.UserID,
+		ChirpID:       arg.ChirpID,
+		Note:          arg.Note,
+		ModeratorType: arg.ModeratorType,
+	}
+	s.moderationActions = append(s.moderationActions, action)
+	return action, nil
+}
+
+func (s *Store) GetModerationActionsByUser(ctx context.Context, userID uuid.UUID) ([]database.ModerationAction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	actions := filter(s.moderationActions, func(action database.ModerationAction) bool { return action.UserID == userID })
+	slices.SortStableFunc(actions, func(a, b database.ModerationAction) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return actions, nil
+}
+
+func (s *Store) GetModerationActionsByReport(ctx context.Context, reportID uuid.NullUUID) ([]database.ModerationAction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	actions := filter(s.moderationActions, func(action database.ModerationAction) bool {
+		return sameID(action.ReportID, reportID)
+	})
+	slices.SortStableFunc(actions, func(a, b database.ModerationAction) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return actions, nil
+}
+
+// Notifications
+
+func (s *Store) CreateNotification(ctx context.Context, arg database.CreateNotificationParams) (database.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.Notification{}, fmt.Errorf("memory: user %s does not exist", arg.UserID)
+	}
+	notification := database.Notification{
+		ID:        uuid.New(),
+		CreatedAt: s.Now(),
+		UserID:    arg.UserID,
+		Message:   arg.Message,
+	}
+	s.notifications = append(s.notifications, notification)
+	return notification, nil
+}
+
+func (s *Store) GetNotificationsByUser(ctx context.Context, arg database.GetNotificationsByUserParams) ([]database.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	notifications := filter(s.notifications, func(notification database.Notification) bool {
+		return notification.UserID == arg.UserID
+	})
+	slices.SortStableFunc(notifications, func(a, b database.Notification) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return limit(notifications, arg.Limit), nil
+}
+
+func (s *Store) MarkNotificationRead(ctx context.Context, arg database.MarkNotificationReadParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, notification := range s.notifications {
+		if notification.ID == arg.ID && notification.UserID == arg.UserID && !notification.ReadAt.Valid {
+			s.notifications[i].ReadAt = nullNow(s.Now())
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// Moderation words
+
+func (s *Store) CreateModerationWord(ctx context.Context, arg database.CreateModerationWordParams) (database.ModerationWord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.ContainsFunc(s.moderationWords, func(word database.ModerationWord) bool { return word.Word == arg.Word }) {
+		return database.ModerationWord{}, fmt.Errorf("memory: moderation word %q already exists", arg.Word)
+	}
+	now := s.Now()
+	word := database.ModerationWord{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Word:      arg.Word,
+		Severity:  arg.Severity,
+	}
+	s.moderationWords = append(s.moderationWords, word)
+	return word, nil
+}
+
+func (s *Store) GetModerationWords(ctx context.Context) ([]database.ModerationWord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	words := slices.Clone(s.moderationWords)
+	slices.SortFunc(words, func(a, b database.ModerationWord) int {
+		return strings.Compare(a.Word, b.Word)
+	})
+	return words, nil
+}
+
+func (s *Store) UpdateModerationWordSeverity(ctx context.Context, arg database.UpdateModerationWordSeverityParams) (database.ModerationWord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.moderationWords, func(word database.ModerationWord) bool { return word.ID == arg.ID })
+	if i < 0 {
+		return database.ModerationWord{}, sql.ErrNoRows
+	}
+	s.moderationWords[i].UpdatedAt = s.Now()
+	s.moderationWords[i].Severity = arg.Severity
+	return s.moderationWords[i], nil
+}
+
+func (s *Store) DeleteModerationWord(ctx context.Context, id uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := len(s.moderationWords)
+	s.moderationWords = slices.DeleteFunc(s.moderationWords, func(word database.ModerationWord) bool { return word.ID == id })
+	return int64(before - len(s.moderationWords)), nil
+}
@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestReports(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	alice := mustCreateUser(t, s, "alice@example.com")
+	chirp := mustCreateChirp(t, s, alice.ID, "hello")
+	chirpID := uuid.NullUUID{UUID: chirp.ID, Valid: true}
+
+	report, err := s.CreateReport(ctx, database.CreateReportParams{UserID: alice.ID, ChirpID: chirpID, ChirpBody: chirp.Body, Reason: "spam"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.CreateReport(ctx, database.CreateReportParams{UserID: alice.ID, Reason: "spam"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.DeleteChirp(ctx, chirp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	open, _ := s.GetOpenReportsForChirp(ctx, chirpID)
+	if len(open) != 1 || open[0].ChirpBody != "hello" {
+		t.Errorf("Expected the report to outlive the chirp, got %+v", open)
+	}
+	open, _ = s.GetOpenReportsForUser(ctx, alice.ID)
+	if len(open) != 1 {
+		t.Errorf("Expected 1 open report of the user, got %d", len(open))
+	}
+
+	err = s.ResolveReport(ctx, database.ResolveReportParams{ID: report.ID, Status: "actioned"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.CreateModerationAction(ctx, database.CreateModerationActionParams{
+		ReportID: uuid.NullUUID{UUID: report.ID, Valid: true},
+		Action:   "delete_chirp",
+		UserID:   alice.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports, _ := s.GetReportsByStatus(ctx, database.GetReportsByStatusParams{Status: "open", Limit: 10})
+	if len(reports) != 1 {
+		t.Errorf("Expected 1 open report, got %d", len(reports))
+	}
+	actions, _ := s.GetModerationActionsByReport(ctx, uuid.NullUUID{UUID: report.ID, Valid: true})
+	if len(actions) != 1 {
+		t.Errorf("Expected 1 action on the report, got %d", len(actions))
+	}
+
+	err = s.DeleteAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions, _ = s.GetModerationActionsByUser(ctx, alice.ID)
+	if len(actions) != 0 {
+		t.Errorf("Expected DeleteAll to delete moderation actions, got %d", len(actions))
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	alice := mustCreateUser(t, s, "alice@example.com")
+
+	notification, err := s.CreateNotification(ctx, database.CreateNotificationParams{UserID: alice.ID, Message: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, _ := s.MarkNotificationRead(ctx, database.MarkNotificationReadParams{ID: notification.ID, UserID: uuid.New()})
+	if read != 0 {
+		t.Error("Expected another user's notification not to be marked as read")
+	}
+	read, _ = s.MarkNotificationRead(ctx, database.MarkNotificationReadParams{ID: notification.ID, UserID: alice.ID})
+	if read != 1 {
+		t.Errorf("Expected 1 notification marked as read, got %d", read)
+	}
+	read, _ = s.MarkNotificationRead(ctx, database.MarkNotificationReadParams{ID: notification.ID, UserID: alice.ID})
+	if read != 0 {
+		t.Errorf("Expected a read notification not to be marked again, got %d", read)
+	}
+}
+
+func TestModerationWords(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+
+	for _, word := range []string{"kerfuffle", "fornax"} {
+		_, err := s.CreateModerationWord(ctx, database.CreateModerationWordParams{Word: word, Severity: "block"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := s.CreateModerationWord(ctx, database.CreateModerationWordParams{Word: "fornax", Severity: "flag"})
+	if err == nil {
+		t.Error("Expected duplicate words to be rejected")
+	}
+	words, _ := s.GetModerationWords(ctx)
+	if len(words) != 2 || words[0].Word != "fornax" {
+		t.Errorf("Expected words in alphabetical order, got %+v", words)
+	}
+	deleted, _ := s.DeleteModerationWord(ctx, words[0].ID)
+	if deleted != 1 {
+		t.Errorf("Expected 1 word deleted, got %d", deleted)
+	}
+}
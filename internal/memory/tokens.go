@@ -0,0 +1,246 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Personal access tokens
+
+func (s *Store) CreatePersonalAccessToken(ctx context.Context, arg database.CreatePersonalAccessTokenParams) (database.PersonalAccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.PersonalAccessToken{}, fmt.Errorf("memory: user %s does not exist", arg.UserID)
+	}
+	if slices.ContainsFunc(s.pats, func(pat database.PersonalAccessToken) bool { return pat.TokenHash == arg.TokenHash }) {
+		return database.PersonalAccessToken{}, errors.New("memory: personal access token already exists")
+	}
+	now := s.Now()
+	pat := database.PersonalAccessToken{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		TokenHash: arg.TokenHash,
+		Scopes:    arg.Scopes,
+		ExpiresAt: arg.ExpiresAt,
+	}
+	s.pats = append(s.pats, pat)
+	return pat, nil
+}
+
+func (s *Store) GetPersonalAccessTokenByHash(ctx context.Context, tokenHash string) (database.PersonalAccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.Now()
+	for _, pat := range s.pats {
+		if pat.TokenHash == tokenHash && !pat.RevokedAt.Valid && (!pat.ExpiresAt.Valid || pat.ExpiresAt.Time.After(now)) {
+			return pat, nil
+		}
+	}
+	return database.PersonalAccessToken{}, sql.ErrNoRows
+}
+
+func (s *Store) GetPersonalAccessTokensByUser(ctx context.Context, userID uuid.UUID) ([]database.PersonalAccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pats := filter(s.pats, func(pat database.PersonalAccessToken) bool { return pat.UserID == userID })
+	slices.SortStableFunc(pats, func(a, b database.PersonalAccessToken) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return pats, nil
+}
+
+func (s *Store) TouchPersonalAccessToken(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.pats {
+		if s.pats[i].ID == id {
+			s.pats[i].LastUsedAt = nullNow(s.Now())
+		}
+	}
+	return nil
+}
+
+func (s *Store) RevokePersonalAccessToken(ctx context.Context, arg database.RevokePersonalAccessTokenParams) (database.PersonalAccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, pat := range s.pats {
+		if pat.ID == arg.ID && pat.UserID == arg.UserID && !pat.RevokedAt.Valid {
+			s.revokePAT(i)
+			return s.pats[i], nil
+		}
+	}
+	return database.PersonalAccessToken{}, sql.ErrNoRows
+}
+
+func (s *Store) RevokeUserPersonalAccessTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var revoked int64
+	for i, pat := range s.pats {
+		if pat.UserID == userID && !pat.RevokedAt.Valid {
+			s.revokePAT(i)
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (s *Store) revokePAT(i int) {
+	now := s.Now()
+	s.pats[i].RevokedAt = nullNow(now)
+	s.pats[i].UpdatedAt = now
+}
+
+// OAuth clients
+
+func (s *Store) CreateOAuthClient(ctx context.Context, arg database.CreateOAuthClientParams) (database.OauthClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.OwnerID]; !ok {
+		return database.OauthClient{}, fmt.Errorf("memory: user %s does not exist", arg.OwnerID)
+	}
+	now := s.Now()
+	client := database.OauthClient{
+		ID:           uuid.New(),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		OwnerID:      arg.OwnerID,
+		Name:         arg.Name,
+		SecretHash:   arg.SecretHash,
+		RedirectUris: arg.RedirectUris,
+		Scopes:       arg.Scopes,
+	}
+	s.oauthClients = append(s.oauthClients, client)
+	return client, nil
+}
+
+func (s *Store) GetOAuthClient(ctx context.Context, id uuid.UUID) (database.OauthClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.oauthClients, func(client database.OauthClient) bool { return client.ID == id })
+	if i < 0 {
+		return database.OauthClient{}, sql.ErrNoRows
+	}
+	return s.oauthClients[i], nil
+}
+
+func (s *Store) GetOAuthClientsByOwner(ctx context.Context, ownerID uuid.UUID) ([]database.OauthClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clients := filter(s.oauthClients, func(client database.OauthClient) bool { return client.OwnerID == ownerID })
+	slices.SortStableFunc(clients, func(a, b database.OauthClient) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return clients, nil
+}
+
+// DeleteOAuthClient deletes a client and, like the foreign keys in Postgres, its authorization
+// codes and refresh tokens.
+func (s *Store) DeleteOAuthClient(ctx context.Context, arg database.DeleteOAuthClientParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := len(s.oauthClients)
+	s.oauthClients = slices.DeleteFunc(s.oauthClients, func(client database.OauthClient) bool {
+		return client.ID == arg.ID && client.OwnerID == arg.OwnerID
+	})
+	deleted := int64(before - len(s.oauthClients))
+	if deleted > 0 {
+		s.deleteClientData(func(clientID uuid.UUID) bool { return clientID == arg.ID })
+	}
+	return deleted, nil
+}
+
+// deleteClientData deletes the authorization codes and refresh tokens of the clients matching
+// deleted.
+func (s *Store) deleteClientData(deleted func(clientID uuid.UUID) bool) {
+	s.oauthCodes = slices.DeleteFunc(s.oauthCodes, func(code database.OauthAuthorizationCode) bool {
+		return deleted(code.ClientID)
+	})
+	for token, rt := range s.refreshTokens {
+		if rt.ClientID.Valid && deleted(rt.ClientID.UUID) {
+			delete(s.refreshTokens, token)
+		}
+	}
+}
+
+func (s *Store) CreateOAuthAuthorizationCode(ctx context.Context, arg database.CreateOAuthAuthorizationCodeParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.ContainsFunc(s.oauthClients, func(client database.OauthClient) bool { return client.ID == arg.ClientID }) {
+		return fmt.Errorf("memory: OAuth client %s does not exist", arg.ClientID)
+	}
+	if slices.ContainsFunc(s.oauthCodes, func(code database.OauthAuthorizationCode) bool { return code.CodeHash == arg.CodeHash }) {
+		return errors.New("memory: authorization code already exists")
+	}
+	s.oauthCodes = append(s.oauthCodes, database.OauthAuthorizationCode{
+		CodeHash:            arg.CodeHash,
+		CreatedAt:           s.Now(),
+		ClientID:            arg.ClientID,
+		UserID:              arg.UserID,
+		RedirectUri:         arg.RedirectUri,
+		Scopes:              arg.Scopes,
+		CodeChallenge:       arg.CodeChallenge,
+		CodeChallengeMethod: arg.CodeChallengeMethod,
+		ExpiresAt:           arg.ExpiresAt,
+	})
+	return nil
+}
+
+func (s *Store) ConsumeOAuthAuthorizationCode(ctx context.Context, codeHash string) (database.OauthAuthorizationCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.Now()
+	for i, code := range s.oauthCodes {
+		if code.CodeHash == codeHash && !code.UsedAt.Valid && code.ExpiresAt.After(now) {
+			s.oauthCodes[i].UsedAt = nullNow(now)
+			return s.oauthCodes[i], nil
+		}
+	}
+	return database.OauthAuthorizationCode{}, sql.ErrNoRows
+}
+
+// OIDC identities
+
+func (s *Store) GetUserByIdentity(ctx context.Context, arg database.GetUserByIdentityParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, identity := range s.identities {
+		if identity.Issuer == arg.Issuer && identity.Subject == arg.Subject {
+			if user, ok := s.users[identity.UserID]; ok {
+				return user, nil
+			}
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (s *Store) CreateUserIdentity(ctx context.Context, arg database.CreateUserIdentityParams) (database.UserIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.UserIdentity{}, fmt.Errorf("memory: user %s does not exist", arg.UserID)
+	}
+	if slices.ContainsFunc(s.identities, func(identity database.UserIdentity) bool {
+		return identity.Issuer == arg.Issuer && identity.Subject == arg.Subject
+	}) {
+		return database.UserIdentity{}, errors.New("memory: identity is already linked")
+	}
+	identity := database.UserIdentity{
+		Issuer:    arg.Issuer,
+		Subject:   arg.Subject,
+		CreatedAt: s.Now(),
+		UserID:    arg.UserID,
+	}
+	s.identities = append(s.identities, identity)
+	return identity, nil
+}
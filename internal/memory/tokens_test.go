@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestPersonalAccessTokens(t *testing.T) {
+	ctx := context.Background()
+	s, now := newTestStore(t)
+	alice := mustCreateUser(t, s, "alice@example.com")
+
+	pat, err := s.CreatePersonalAccessToken(ctx, database.CreatePersonalAccessTokenParams{
+		UserID:    alice.ID,
+		Name:      "ci",
+		TokenHash: "hash",
+		Scopes:    "chirps:read",
+		ExpiresAt: sql.NullTime{Time: now.Add(time.Hour), Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.CreatePersonalAccessToken(ctx, database.CreatePersonalAccessTokenParams{UserID: alice.ID, TokenHash: "hash"})
+	if err == nil {
+		t.Error("Expected duplicate token hashes to be rejected")
+	}
+
+	got, err := s.GetPersonalAccessTokenByHash(ctx, "hash")
+	if err != nil || got.ID != pat.ID {
+		t.Errorf("GetPersonalAccessTokenByHash() = %v, %v", got.ID, err)
+	}
+	*now = now.Add(2 * time.Hour)
+	_, err = s.GetPersonalAccessTokenByHash(ctx, "hash")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected an expired token to be rejected, got %v", err)
+	}
+
+	revoked, _ := s.RevokeUserPersonalAccessTokens(ctx, alice.ID)
+	if revoked != 1 {
+		t.Errorf("Expected 1 token revoked, got %d", revoked)
+	}
+	_, err = s.RevokePersonalAccessToken(ctx, database.RevokePersonalAccessTokenParams{ID: pat.ID, UserID: alice.ID})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected a revoked token not to be revoked again, got %v", err)
+	}
+}
+
+func TestOAuth(t *testing.T) {
+	ctx := context.Background()
+	s, now := newTestStore(t)
+	alice := mustCreateUser(t, s, "alice@example.com")
+
+	client, err := s.CreateOAuthClient(ctx, database.CreateOAuthClientParams{OwnerID: alice.ID, Name: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.CreateOAuthAuthorizationCode(ctx, database.CreateOAuthAuthorizationCodeParams{
+		CodeHash:  "code",
+		ClientID:  client.ID,
+		UserID:    alice.ID,
+		ExpiresAt: now.Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := s.ConsumeOAuthAuthorizationCode(ctx, "code")
+	if err != nil || !code.UsedAt.Valid {
+		t.Errorf("ConsumeOAuthAuthorizationCode() = %+v, %v", code, err)
+	}
+	_, err = s.ConsumeOAuthAuthorizationCode(ctx, "code")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected a used code not to be consumed again, got %v", err)
+	}
+
+	_, err = s.CreateOAuthRefreshToken(ctx, database.CreateOAuthRefreshTokenParams{
+		Token:     "oauth",
+		UserID:    alice.ID,
+		ExpiresAt: now.Add(time.Hour),
+		ClientID:  uuid.NullUUID{UUID: client.ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted, _ := s.DeleteOAuthClient(ctx, database.DeleteOAuthClientParams{ID: client.ID, OwnerID: alice.ID})
+	if deleted != 1 {
+		t.Errorf("Expected 1 client deleted, got %d", deleted)
+	}
+	_, err = s.GetRefreshToken(ctx, "oauth")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected the client's refresh tokens to be deleted with it, got %v", err)
+	}
+}
+
+func TestIdentities(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	alice := mustCreateUser(t, s, "alice@example.com")
+
+	_, err := s.CreateUserIdentity(ctx, database.CreateUserIdentityParams{Issuer: "https://idp", Subject: "1", UserID: alice.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.CreateUserIdentity(ctx, database.CreateUserIdentityParams{Issuer: "https://idp", Subject: "1", UserID: alice.ID})
+	if err == nil {
+		t.Error("Expected an identity to be linked only once")
+	}
+	user, err := s.GetUserByIdentity(ctx, database.GetUserByIdentityParams{Issuer: "https://idp", Subject: "1"})
+	if err != nil || user.ID != alice.ID {
+		t.Errorf("GetUserByIdentity() = %v, %v", user.ID, err)
+	}
+
+	err = s.DeleteAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.GetUserByIdentity(ctx, database.GetUserByIdentityParams{Issuer: "https://idp", Subject: "1"})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected DeleteAll to delete identities, got %v", err)
+	}
+}
@@ -0,0 +1,340 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Outgoing webhook endpoints
+
+func (s *Store) CreateWebhookEndpoint(ctx context.Context, arg database.CreateWebhookEndpointParams) (database.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[arg.UserID.UUID]; arg.UserID.Valid && !ok {
+		return database.WebhookEndpoint{}, fmt.Errorf("memory: user %s does not exist", arg.UserID.UUID)
+	}
+	now := s.Now()
+	endpoint := database.WebhookEndpoint{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    arg.UserID,
+		Url:       arg.Url,
+		Secret:    arg.Secret,
+		Events:    arg.Events,
+	}
+	s.webhookEndpoints = append(s.webhookEndpoints, endpoint)
+	return endpoint, nil
+}
+
+func (s *Store) GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (database.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.webhookEndpoints, func(endpoint database.WebhookEndpoint) bool { return endpoint.ID == id })
+	if i < 0 {
+		return database.WebhookEndpoint{}, sql.ErrNoRows
+	}
+	return s.webhookEndpoints[i], nil
+}
+
+// listWebhookEndpoints returns the endpoints matching keep, newest first.
+func (s *Store) listWebhookEndpoints(keep func(endpoint database.WebhookEndpoint) bool) []database.WebhookEndpoint {
+	endpoints := filter(s.webhookEndpoints, keep)
+	slices.SortStableFunc(endpoints, func(a, b database.WebhookEndpoint) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return endpoints
+}
+
+func (s *Store) GetWebhookEndpointsByUser(ctx context.Context, userID uuid.NullUUID) ([]database.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listWebhookEndpoints(func(endpoint database.WebhookEndpoint) bool {
+		return sameID(endpoint.UserID, userID)
+	}), nil
+}
+
+func (s *Store) GetAdminWebhookEndpoints(ctx context.Context) ([]database.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listWebhookEndpoints(func(endpoint database.WebhookEndpoint) bool {
+		return !endpoint.UserID.Valid
+	}), nil
+}
+
+func (s *Store) GetWebhookEndpointsForOwner(ctx context.Context, userID uuid.NullUUID) ([]database.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return filter(s.webhookEndpoints, func(endpoint database.WebhookEndpoint) bool {
+		return !endpoint.UserID.Valid || sameID(endpoint.UserID, userID)
+	}), nil
+}
+
+// DeleteWebhookEndpoint deletes an endpoint and, like the foreign key in Postgres, its deliveries.
+func (s *Store) DeleteWebhookEndpoint(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteWebhookEndpoints(func(endpoint database.WebhookEndpoint) bool { return endpoint.ID == id })
+	return nil
+}
+
+func (s *Store) deleteWebhookEndpoints(deleted func(endpoint database.WebhookEndpoint) bool) {
+	s.webhookEndpoints = slices.DeleteFunc(s.webhookEndpoints, deleted)
+	s.webhookDeliveries = slices.DeleteFunc(s.webhookDeliveries, func(delivery database.WebhookDelivery) bool {
+		return !slices.ContainsFunc(s.webhookEndpoints, func(endpoint database.WebhookEndpoint) bool {
+			return endpoint.ID == delivery.EndpointID
+		})
+	})
+}
+
+// Outgoing webhook deliveries
+
+func (s *Store) CreateWebhookDelivery(ctx context.Context, arg database.CreateWebhookDeliveryParams) (database.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.ContainsFunc(s.webhookEndpoints, func(endpoint database.WebhookEndpoint) bool { return endpoint.ID == arg.EndpointID }) {
+		return database.WebhookDelivery{}, fmt.Errorf("memory: webhook endpoint %s does not exist", arg.EndpointID)
+	}
+	now := s.Now()
+	delivery := database.WebhookDelivery{
+		ID:            uuid.New(),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		EndpointID:    arg.EndpointID,
+		EventID:       arg.EventID,
+		EventType:     arg.EventType,
+		Payload:       arg.Payload,
+		Status:        "pending",
+		NextAttemptAt: now,
+	}
+	s.webhookDeliveries = append(s.webhookDeliveries, delivery)
+	return delivery, nil
+}
+
+func (s *Store) GetWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.webhookDeliveryIndex(id)
+	if i < 0 {
+		return database.WebhookDelivery{}, sql.ErrNoRows
+	}
+	return s.webhookDeliveries[i], nil
+}
+
+func (s *Store) webhookDeliveryIndex(id uuid.UUID) int {
+	return slices.IndexFunc(s.webhookDeliveries, func(delivery database.WebhookDelivery) bool { return delivery.ID == id })
+}
+
+func (s *Store) GetWebhookDeliveriesByEndpoint(ctx context.Context, arg database.GetWebhookDeliveriesByEndpointParams) ([]database.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deliveries := filter(s.webhookDeliveries, func(delivery database.WebhookDelivery) bool {
+		return delivery.EndpointID == arg.EndpointID
+	})
+	slices.SortStableFunc(deliveries, func(a, b database.WebhookDelivery) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return limit(deliveries, arg.Limit), nil
+}
+
+func (s *Store) ClaimDueWebhookDeliveries(ctx context.Context, arg database.ClaimDueWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.Now()
+	due := filter(s.webhookDeliveries, func(delivery database.WebhookDelivery) bool {
+		return delivery.Status == "pending" && !delivery.NextAttemptAt.After(now)
+	})
+	slices.SortStableFunc(due, func(a, b database.WebhookDelivery) int {
+		return a.NextAttemptAt.Compare(b.NextAttemptAt)
+	})
+	claimed := limit(due, arg.Limit)
+	for i := range claimed {
+		claimed[i].UpdatedAt = now
+		claimed[i].Attempts++
+		claimed[i].NextAttemptAt = arg.NextAttemptAt
+		s.webhookDeliveries[s.webhookDeliveryIndex(claimed[i].ID)] = claimed[i]
+	}
+	return claimed, nil
+}
+
+// updateWebhookDelivery applies update to a delivery, like an UPDATE without RETURNING.
+func (s *Store) updateWebhookDelivery(id uuid.UUID, update func(delivery *database.WebhookDelivery, now time.Time)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.webhookDeliveryIndex(id); i >= 0 {
+		now := s.Now()
+		update(&s.webhookDeliveries[i], now)
+		s.webhookDeliveries[i].UpdatedAt = now
+	}
+}
+
+func (s *Store) MarkWebhookDeliveryDelivered(ctx context.Context, arg database.MarkWebhookDeliveryDeliveredParams) error {
+	s.updateWebhookDelivery(arg.ID, func(delivery *database.WebhookDelivery, now time.Time) {
+		delivery.Status = "delivered"
+		delivery.DeliveredAt = nullNow(now)
+		delivery.LastStatusCode = arg.LastStatusCode
+		delivery.LastError = sql.NullString{}
+	})
+	return nil
+}
+
+func (s *Store) MarkWebhookDeliveryFailed(ctx context.Context, arg database.MarkWebhookDeliveryFailedParams) error {
+	s.updateWebhookDelivery(arg.ID, func(delivery *database.WebhookDelivery, now time.Time) {
+		delivery.Status = arg.Status
+		delivery.NextAttemptAt = arg.NextAttemptAt
+		delivery.LastStatusCode = arg.LastStatusCode
+		delivery.LastError = arg.LastError
+	})
+	return nil
+}
+
+func (s *Store) RetryWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.webhookDeliveryIndex(id)
+	if i < 0 || s.webhookDeliveries[i].Status != "dead" {
+		return database.WebhookDelivery{}, sql.ErrNoRows
+	}
+	now := s.Now()
+	delivery := &s.webhookDeliveries[i]
+	delivery.UpdatedAt = now
+	delivery.Status = "pending"
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	return *delivery, nil
+}
+
+// Incoming webhook events
+
+func (s *Store) RecordWebhookSignature(ctx context.Context, signature string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.webhookSignatures[signature]; ok {
+		return 0, nil
+	}
+	s.webhookSignatures[signature] = s.Now()
+	return 1, nil
+}
+
+func (s *Store) DeleteWebhookSignaturesBefore(ctx context.Context, receivedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for signature, at := range s.webhookSignatures {
+		if at.Before(receivedAt) {
+			delete(s.webhookSignatures, signature)
+		}
+	}
+	return nil
+}
+
+func (s *Store) CreateWebhookEvent(ctx context.Context, arg database.CreateWebhookEventParams) (database.WebhookEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.ContainsFunc(s.webhookEvents, func(event database.WebhookEvent) bool {
+		return event.Provider == arg.Provider && event.EventID == arg.EventID
+	}) {
+		return database.WebhookEvent{}, sql.ErrNoRows
+	}
+	event := database.WebhookEvent{
+		ID:         uuid.New(),
+		Provider:   arg.Provider,
+		EventID:    arg.EventID,
+		EventType:  arg.EventType,
+		Payload:    arg.Payload,
+		Status:     "received",
+		ReceivedAt: s.Now(),
+	}
+	s.webhookEvents = append(s.webhookEvents, event)
+	return event, nil
+}
+
+func (s *Store) GetWebhookEvent(ctx context.Context, id uuid.UUID) (database.WebhookEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.webhookEventIndex(id)
+	if i < 0 {
+		return database.WebhookEvent{}, sql.ErrNoRows
+	}
+	return s.webhookEvents[i], nil
+}
+
+func (s *Store) webhookEventIndex(id uuid.UUID) int {
+	return slices.IndexFunc(s.webhookEvents, func(event database.WebhookEvent) bool { return event.ID == id })
+}
+
+func (s *Store) GetWebhookEventByProviderID(ctx context.Context, arg database.GetWebhookEventByProviderIDParams) (database.WebhookEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, event := range s.webhookEvents {
+		if event.Provider == arg.Provider && event.EventID == arg.EventID {
+			return event, nil
+		}
+	}
+	return database.WebhookEvent{}, sql.ErrNoRows
+}
+
+func (s *Store) ListWebhookEvents(ctx context.Context, arg database.ListWebhookEventsParams) ([]database.WebhookEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := filter(s.webhookEvents, func(event database.WebhookEvent) bool {
+		return arg.Status == "" || event.Status == arg.Status
+	})
+	slices.SortStableFunc(events, func(a, b database.WebhookEvent) int {
+		return b.ReceivedAt.Compare(a.ReceivedAt)
+	})
+	return limit(events, arg.MaxResults), nil
+}
+
+// claimWebhookEvent marks an event as processing if claimable says it may be claimed.
+func (s *Store) claimWebhookEvent(id uuid.UUID, claimable func(event database.WebhookEvent) bool) (database.WebhookEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.webhookEventIndex(id)
+	if i < 0 || !claimable(s.webhookEvents[i]) {
+		return database.WebhookEvent{}, sql.ErrNoRows
+	}
+	event := &s.webhookEvents[i]
+	event.Status = "processing"
+	event.Attempts++
+	event.ClaimedAt = nullNow(s.Now())
+	return *event, nil
+}
+
+// staleClaim reports whether an event was claimed before staleBefore, like claimed_at < $2,
+// which is false for a NULL claimed_at.
+func staleClaim(event database.WebhookEvent, staleBefore time.Time) bool {
+	return event.ClaimedAt.Valid && event.ClaimedAt.Time.Before(staleBefore)
+}
+
+func (s *Store) ClaimWebhookEvent(ctx context.Context, arg database.ClaimWebhookEventParams) (database.WebhookEvent, error) {
+	return s.claimWebhookEvent(arg.ID, func(event database.WebhookEvent) bool {
+		return event.Status == "received" || event.Status == "failed" ||
+			(event.Status == "processing" && staleClaim(event, arg.StaleBefore))
+	})
+}
+
+func (s *Store) ClaimWebhookEventForReplay(ctx context.Context, arg database.ClaimWebhookEventForReplayParams) (database.WebhookEvent, error) {
+	return s.claimWebhookEvent(arg.ID, func(event database.WebhookEvent) bool {
+		return event.Status != "processing" || staleClaim(event, arg.StaleBefore)
+	})
+}
+
+func (s *Store) FinishWebhookEvent(ctx context.Context, arg database.FinishWebhookEventParams) (database.WebhookEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.webhookEventIndex(arg.ID)
+	if i < 0 {
+		return database.WebhookEvent{}, sql.ErrNoRows
+	}
+	event := &s.webhookEvents[i]
+	event.Status = arg.Status
+	event.Error = arg.Error
+	event.ProcessedAt = nullNow(s.Now())
+	return *event, nil
+}
@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestWebhookDeliveries(t *testing.T) {
+	ctx := context.Background()
+	s, now := newTestStore(t)
+	alice := mustCreateUser(t, s, "alice@example.com")
+
+	admin, err := s.CreateWebhookEndpoint(ctx, database.CreateWebhookEndpointParams{Url: "https://admin.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := s.CreateWebhookEndpoint(ctx, database.CreateWebhookEndpointParams{
+		UserID: uuid.NullUUID{UUID: alice.ID, Valid: true},
+		Url:    "https://alice.example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoints, _ := s.GetWebhookEndpointsForOwner(ctx, uuid.NullUUID{UUID: alice.ID, Valid: true})
+	if len(endpoints) != 2 {
+		t.Errorf("Expected alice's and the admin endpoint, got %d", len(endpoints))
+	}
+
+	for _, endpoint := range []database.WebhookEndpoint{admin, user} {
+		_, err := s.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{EndpointID: endpoint.ID, EventID: uuid.New()})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	claimed, _ := s.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{NextAttemptAt: now.Add(time.Minute), Limit: 1})
+	if len(claimed) != 1 || claimed[0].Attempts != 1 {
+		t.Fatalf("ClaimDueWebhookDeliveries() = %+v", claimed)
+	}
+	claimed, _ = s.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{NextAttemptAt: now.Add(time.Minute), Limit: 10})
+	if len(claimed) != 1 {
+		t.Errorf("Expected leased deliveries to be skipped, got %d claimed", len(claimed))
+	}
+
+	_, err = s.RetryWebhookDelivery(ctx, claimed[0].ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected only dead deliveries to be retried, got %v", err)
+	}
+	err = s.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{ID: claimed[0].ID, Status: "dead", NextAttemptAt: *now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	retried, err := s.RetryWebhookDelivery(ctx, claimed[0].ID)
+	if err != nil || retried.Status != "pending" || retried.Attempts != 0 {
+		t.Errorf("RetryWebhookDelivery() = %+v, %v", retried, err)
+	}
+
+	err = s.DeleteAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.GetWebhookEndpoint(ctx, user.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected DeleteAll to delete user endpoints, got %v", err)
+	}
+	deliveries, _ := s.GetWebhookDeliveriesByEndpoint(ctx, database.GetWebhookDeliveriesByEndpointParams{EndpointID: admin.ID, Limit: 10})
+	if len(deliveries) != 1 {
+		t.Errorf("Expected the admin endpoint to keep its delivery, got %d", len(deliveries))
+	}
+}
+
+func TestWebhookEvents(t *testing.T) {
+	ctx := context.Background()
+	s, now := newTestStore(t)
+
+	event, err := s.CreateWebhookEvent(ctx, database.CreateWebhookEventParams{Provider: "polka", EventID: "evt_1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.CreateWebhookEvent(ctx, database.CreateWebhookEventParams{Provider: "polka", EventID: "evt_1"})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected a duplicate event to be skipped, got %v", err)
+	}
+
+	claimed, err := s.ClaimWebhookEvent(ctx, database.ClaimWebhookEventParams{ID: event.ID, StaleBefore: now.Add(-time.Minute)})
+	if err != nil || claimed.Status != "processing" || claimed.Attempts != 1 {
+		t.Errorf("ClaimWebhookEvent() = %+v, %v", claimed, err)
+	}
+	_, err = s.ClaimWebhookEvent(ctx, database.ClaimWebhookEventParams{ID: event.ID, StaleBefore: now.Add(-time.Minute)})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected a claimed event not to be claimed again, got %v", err)
+	}
+	*now = now.Add(time.Hour)
+	_, err = s.ClaimWebhookEvent(ctx, database.ClaimWebhookEventParams{ID: event.ID, StaleBefore: now.Add(-time.Minute)})
+	if err != nil {
+		t.Errorf("Expected a stale claim to be claimed again, got %v", err)
+	}
+
+	_, err = s.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{ID: event.ID, Status: "processed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, _ := s.ListWebhookEvents(ctx, database.ListWebhookEventsParams{Status: "processed", MaxResults: 10})
+	if len(events) != 1 {
+		t.Errorf("Expected 1 processed event, got %d", len(events))
+	}
+
+	for _, want := range []int64{1, 0} {
+		recorded, _ := s.RecordWebhookSignature(ctx, "sig")
+		if recorded != want {
+			t.Errorf("RecordWebhookSignature() = %d, want %d", recorded, want)
+		}
+	}
+}
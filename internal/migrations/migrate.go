@@ -0,0 +1,112 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// Dialect is the SQL dialect of the database being migrated.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	// SQLite databases belong to a single node, so they are migrated without a lock.
+	SQLite Dialect = "sqlite"
+)
+
+// Migration is a migration file that was applied or rolled back.
+type Migration struct {
+	Version int64
+	Name    string
+}
+
+// Status is whether a migration has been applied.
+type Status struct {
+	Migration
+	// AppliedAt is zero for a pending migration.
+	AppliedAt time.Time
+}
+
+// NewProvider returns a goose provider for the migrations in fsys. On Postgres it holds goose's
+// advisory lock while migrating, so replicas starting together, or a manual goose run, wait for
+// each other instead of racing.
+func NewProvider(db *sql.DB, dialect Dialect, fsys fs.FS) (*goose.Provider, error) {
+	opts := []goose.ProviderOption{goose.WithDisableGlobalRegistry(true)}
+	switch dialect {
+	case Postgres:
+		locker, err := lock.NewPostgresSessionLocker()
+		if err != nil {
+			return nil, err
+		}
+		return goose.NewProvider(goose.DialectPostgres, db, fsys, append(opts, goose.WithSessionLocker(locker))...)
+	case SQLite:
+		return goose.NewProvider(goose.DialectSQLite3, db, fsys, opts...)
+	default:
+		return nil, fmt.Errorf("unknown dialect %q", dialect)
+	}
+}
+
+// Up applies every pending migration in fsys and returns the ones it applied, including the
+// ones applied before a migration failed.
+func Up(ctx context.Context, db *sql.DB, dialect Dialect, fsys fs.FS) ([]Migration, error) {
+	provider, err := NewProvider(db, dialect, fsys)
+	if err != nil {
+		return nil, err
+	}
+	results, err := provider.Up(ctx)
+	var partial *goose.PartialError
+	if errors.As(err, &partial) {
+		results = partial.Applied
+	}
+	applied := make([]Migration, 0, len(results))
+	for _, r := range results {
+		applied = append(applied, migration(r.Source))
+	}
+	return applied, err
+}
+
+// Down rolls back the most recently applied migration and returns it. It returns false if
+// no migration in fsys is applied.
+func Down(ctx context.Context, db *sql.DB, dialect Dialect, fsys fs.FS) (Migration, bool, error) {
+	provider, err := NewProvider(db, dialect, fsys)
+	if err != nil {
+		return Migration{}, false, err
+	}
+	result, err := provider.Down(ctx)
+	if errors.Is(err, goose.ErrNoNextVersion) {
+		return Migration{}, false, nil
+	}
+	if err != nil {
+		return Migration{}, false, err
+	}
+	return migration(result.Source), true, nil
+}
+
+// Statuses reports which migrations in fsys have been applied.
+func Statuses(ctx context.Context, db *sql.DB, dialect Dialect, fsys fs.FS) ([]Status, error) {
+	provider, err := NewProvider(db, dialect, fsys)
+	if err != nil {
+		return nil, err
+	}
+	results, err := provider.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(results))
+	for _, s := range results {
+		statuses = append(statuses, Status{Migration: migration(s.Source), AppliedAt: s.AppliedAt})
+	}
+	return statuses, nil
+}
+
+func migration(source *goose.Source) Migration {
+	return Migration{Version: source.Version, Name: path.Base(source.Path)}
+}
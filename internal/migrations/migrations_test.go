@@ -0,0 +1,158 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    int64
+		wantErr bool
+	}{
+		{name: "001_users.sql", want: 1},
+		{name: "019_audit_log.sql", want: 19},
+		{name: "users.sql", wantErr: true},
+		{name: "v2_users.sql", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Version(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Version() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Version() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"001_users.sql":   {},
+		"010_reports.sql": {},
+		"002_chirps.sql":  {},
+		"README.md":       {},
+	}
+	got, err := Latest(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 10 {
+		t.Errorf("Latest() = %d, want 10", got)
+	}
+
+	_, err = Latest(fstest.MapFS{})
+	if err == nil {
+		t.Error("Expected an error without migrations")
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", "file::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	fsys := fstest.MapFS{
+		"001_users.sql": {Data: []byte(`-- +goose Up
+CREATE TABLE users(id INTEGER PRIMARY KEY, email TEXT);
+-- +goose StatementBegin
+CREATE TRIGGER users_email AFTER INSERT ON users BEGIN
+   UPDATE users SET email = lower(email) WHERE id = NEW.id;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE users;
+`)},
+		"002_indexes.sql": {Data: []byte(`-- +goose NO TRANSACTION
+-- +goose Up
+CREATE INDEX users_email_idx ON users (email);
+CREATE UNIQUE INDEX users_id_idx ON users (id);
+
+-- +goose Down
+DROP INDEX users_id_idx;
+DROP INDEX users_email_idx;
+`)},
+	}
+
+	applied, err := Up(ctx, db, SQLite, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 || applied[0].Name != "001_users.sql" || applied[1].Version != 2 {
+		t.Errorf("Unexpected migrations applied: %+v", applied)
+	}
+	statuses, err := Statuses(ctx, db, SQLite, fsys)
+	if err != nil || len(statuses) != 2 || statuses[1].AppliedAt.IsZero() {
+		t.Errorf("Unexpected statuses: %+v, %v", statuses, err)
+	}
+	current, err := Current(ctx, db)
+	if err != nil || current != 2 {
+		t.Errorf("Current() = %d, %v, want 2", current, err)
+	}
+
+	for _, want := range []string{"002_indexes.sql", "001_users.sql"} {
+		m, found, err := Down(ctx, db, SQLite, fsys)
+		if err != nil || !found || m.Name != want {
+			t.Errorf("Down() = %+v, %v, %v, want %s", m, found, err, want)
+		}
+	}
+	_, found, err := Down(ctx, db, SQLite, fsys)
+	if err != nil || found {
+		t.Errorf("Down() with nothing applied = %v, %v", found, err)
+	}
+
+	fsys["003_broken.sql"] = &fstest.MapFile{Data: []byte("-- +goose Up\nSELECT * FROM missing;\n")}
+	applied, err = Up(ctx, db, SQLite, fsys)
+	if err == nil || len(applied) != 2 {
+		t.Errorf("Expected the migrations before the broken one to be reported, got %+v, %v", applied, err)
+	}
+}
+
+func TestSchemaCanBeRolledBack(t *testing.T) {
+	for _, dir := range []string{"../../sql/schema", "../../sql/sqlite/schema"} {
+		names, err := fs.Glob(os.DirFS(dir), "*.sql")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range names {
+			data, err := os.ReadFile(dir + "/" + name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, down, ok := strings.Cut(string(data), "-- +goose Down")
+			if !ok || strings.TrimSpace(down) == "" {
+				t.Errorf("Migration %s can't be rolled back", name)
+			}
+		}
+	}
+}
+
+// The readiness check compares the database with the latest migration, so a SQLite database
+// must end up at the same version as a Postgres one.
+func TestSQLiteSchemaVersion(t *testing.T) {
+	postgres, err := Latest(os.DirFS("../../sql/schema"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := Latest(os.DirFS("../../sql/sqlite/schema"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sqlite != postgres {
+		t.Errorf("SQLite schema is at version %d, Postgres schema at %d", sqlite, postgres)
+	}
+}
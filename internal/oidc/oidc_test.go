@@ -0,0 +1,141 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// stubIdP is a minimal OpenID Connect provider that issues an ID token for any code.
+type stubIdP struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+	nonce    string
+	verifier string
+}
+
+func newStubIdP(t *testing.T, clientID string) *stubIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	idp := &stubIdP{key: key, clientID: clientID}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "test-key",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		idp.verifier = r.PostFormValue("code_verifier")
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.idToken(t, idp.nonce, clientID)})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *stubIdP) idToken(t *testing.T, nonce, audience string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, IDTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    idp.server.URL,
+			Subject:   "subject-1",
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Nonce:         nonce,
+		Email:         "walt@breakingbad.com",
+		EmailVerified: true,
+	})
+	token.Header["kid"] = "test-key"
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		t.Fatalf("Failed to sign ID token: %v", err)
+	}
+	return signed
+}
+
+func TestProviderLoginFlow(t *testing.T) {
+	idp := newStubIdP(t, "chirpy")
+	idp.nonce = "nonce-1"
+	provider := NewProvider(idp.server.URL, "chirpy", "secret", "http://localhost:8080/api/login/oidc/callback")
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state-1", "nonce-1", "challenge")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("Invalid auth URL: %v", err)
+	}
+	if parsed.Query().Get("state") != "state-1" || parsed.Query().Get("code_challenge") != "challenge" {
+		t.Errorf("Unexpected auth URL %s", authURL)
+	}
+
+	claims, err := provider.Exchange(context.Background(), "code", "verifier", "nonce-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if claims.Subject != "subject-1" || claims.Email != "walt@breakingbad.com" || !claims.EmailVerified {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+	if idp.verifier != "verifier" {
+		t.Errorf("Expected code verifier to be sent, got %q", idp.verifier)
+	}
+}
+
+func TestVerifyIDTokenRejectsInvalidTokens(t *testing.T) {
+	idp := newStubIdP(t, "chirpy")
+	provider := NewProvider(idp.server.URL, "chirpy", "secret", "")
+
+	_, err := provider.VerifyIDToken(context.Background(), idp.idToken(t, "other-nonce", "chirpy"), "nonce-1")
+	if err == nil {
+		t.Error("Expected error for nonce mismatch, got none")
+	}
+	_, err = provider.VerifyIDToken(context.Background(), idp.idToken(t, "nonce-1", "someone-else"), "nonce-1")
+	if err == nil {
+		t.Error("Expected error for wrong audience, got none")
+	}
+}
+
+func TestSealState(t *testing.T) {
+	state := LoginState{State: "s", Nonce: "n", CodeVerifier: "v"}
+	sealed, err := SealState(state, "secret", time.Minute)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	opened, err := OpenState(sealed, "secret")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if opened != state {
+		t.Errorf("Expected %+v, got %+v", state, opened)
+	}
+	_, err = OpenState(sealed, "other-secret")
+	if err == nil {
+		t.Error("Expected error for wrong secret, got none")
+	}
+}
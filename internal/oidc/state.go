@@ -0,0 +1,53 @@
+package oidc
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"time"
+)
+
+const stateAudience = "chirpy-oidc-login"
+
+// LoginState is what Chirpy must remember between redirecting the user to the provider and the callback.
+// It is kept client-side in a signed, short-lived cookie so no server-side session storage is needed.
+type LoginState struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+type stateClaims struct {
+	jwt.RegisteredClaims
+	LoginState
+}
+
+// SealState signs the login state so it can be handed to the browser.
+func SealState(state LoginState, secret string, expiresIn time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, stateClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{stateAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+		},
+		LoginState: state,
+	})
+	return token.SignedString([]byte(secret))
+}
+
+// OpenState verifies a sealed login state.
+func OpenState(sealed, secret string) (LoginState, error) {
+	claims := &stateClaims{}
+	_, err := jwt.ParseWithClaims(sealed, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithAudience(stateAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return LoginState{}, err
+	}
+	if claims.State == "" || claims.Nonce == "" || claims.CodeVerifier == "" {
+		return LoginState{}, errors.New("incomplete login state")
+	}
+	return claims.LoginState, nil
+}
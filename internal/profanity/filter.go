@@ -0,0 +1,178 @@
+package profanity
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Severity decides what happens to a chirp containing a word.
+type Severity string
+
+const (
+	// SeverityMask replaces the word with asterisks.
+	SeverityMask Severity = "mask"
+	// SeverityFlag lets the chirp through unchanged but flags it for review.
+	SeverityFlag Severity = "flag"
+	// SeverityReject refuses the chirp.
+	SeverityReject Severity = "reject"
+)
+
+const mask = "****"
+
+// ParseSeverity validates a severity name.
+func ParseSeverity(s string) (Severity, error) {
+	switch Severity(s) {
+	case SeverityMask, SeverityFlag, SeverityReject:
+		return Severity(s), nil
+	default:
+		return "", fmt.Errorf("unknown severity %q", s)
+	}
+}
+
+// Word is an entry of the moderation word list.
+type Word struct {
+	Word     string
+	Severity Severity
+}
+
+// Filter checks chirps against a word list. It is immutable, so it can be shared between requests
+// and swapped out wholesale when the list changes.
+type Filter struct {
+	// severities maps each normalized list word to its severities. Words that normalize the
+	// same, such as "Fornax" and "f0rnax", can have different ones.
+	severities map[string][]Severity
+}
+
+// NewFilter normalizes the words once so checks only need to normalize the chirp.
+func NewFilter(words []Word) *Filter {
+	f := &Filter{severities: map[string][]Severity{}}
+	for _, w := range words {
+		normalized := Normalize(w.Word)
+		if normalized == "" {
+			continue
+		}
+		f.severities[normalized] = append(f.severities[normalized], w.Severity)
+	}
+	return f
+}
+
+// Result is the outcome of checking a chirp.
+type Result struct {
+	// Body is the chirp with masked words replaced.
+	Body     string
+	Rejected bool
+	Flagged  bool
+	// Matches are the normalized list words found in the chirp.
+	Matches []string
+}
+
+// Check finds list words in the body. A token matches only when its normalized form is a list
+// word, so "ass" doesn't match "class" or "password"; inflections such as plurals have to be
+// listed as words of their own.
+func (f *Filter) Check(body string) Result {
+	result := Result{}
+	var out strings.Builder
+	for _, token := range tokenize(body) {
+		if !token.word {
+			out.WriteString(token.text)
+			continue
+		}
+		normalized := Normalize(token.text)
+		masked := false
+		for _, severity := range f.severities[normalized] {
+			result.Matches = append(result.Matches, normalized)
+			switch severity {
+			case SeverityReject:
+				result.Rejected = true
+			case SeverityFlag:
+				result.Flagged = true
+			default:
+				masked = true
+			}
+		}
+		if masked {
+			out.WriteString(mask)
+		} else {
+			out.WriteString(token.text)
+		}
+	}
+	result.Body = out.String()
+	return result
+}
+
+type token struct {
+	text string
+	word bool
+}
+
+// tokenize splits the body into alternating word and separator tokens, so that joining the
+// tokens gives back the body. Any non-letter, including punctuation and all kinds of whitespace,
+// separates words, except for the symbols used in leetspeak.
+func tokenize(body string) []token {
+	var tokens []token
+	start := 0
+	inWord := false
+	for i, r := range body {
+		isWord := isWordRune(r)
+		if i > 0 && isWord != inWord {
+			tokens = append(tokens, token{text: body[start:i], word: inWord})
+			start = i
+		}
+		inWord = isWord
+	}
+	if start < len(body) {
+		tokens = append(tokens, token{text: body[start:], word: inWord})
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) {
+		return true
+	}
+	_, leet := leetspeak[r]
+	return leet
+}
+
+var leetspeak = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'@': 'a',
+	'$': 's',
+}
+
+// homoglyphs maps letters of other scripts that look like Latin letters to the Latin letter.
+var homoglyphs = map[rune]rune{
+	'а': 'a', 'е': 'e', 'о': 'o', 'р': 'p', 'с': 'c', 'у': 'y', 'х': 'x', 'і': 'i', 'ј': 'j', 'ѕ': 's', 'к': 'k', 'м': 'm', 'т': 't', 'в': 'b', 'н': 'h',
+	'α': 'a', 'ε': 'e', 'ο': 'o', 'ρ': 'p', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'τ': 't', 'χ': 'x', 'υ': 'u',
+}
+
+// Normalize folds the text to the form words are compared in: compatibility characters such as
+// fullwidth letters are decomposed, accents are stripped, the text is lower-cased, and
+// lookalike letters and leetspeak are mapped to Latin letters.
+func Normalize(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		r = unicode.ToLower(r)
+		if mapped, ok := homoglyphs[r]; ok {
+			r = mapped
+		} else if mapped, ok := leetspeak[r]; ok {
+			r = mapped
+		}
+		if !isWordRune(r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
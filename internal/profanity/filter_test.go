@@ -0,0 +1,98 @@
+package profanity
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Fornax", "fornax"},
+		{"F0RN@X", "fornax"},
+		{"ｆｏｒｎａｘ", "fornax"},
+		{"fórnäx", "fornax"},
+		{"fоrnах", "fornax"}, // Cyrillic о, а and х
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := Normalize(tt.input); got != tt.expected {
+				t.Errorf("Normalize(%q) = %q, expected %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	filter := NewFilter([]Word{
+		{Word: "kerfuffle", Severity: SeverityMask},
+		{Word: "kerfuffles", Severity: SeverityMask},
+		{Word: "fornax", Severity: SeverityMask},
+		{Word: "sharbert", Severity: SeverityFlag},
+		{Word: "ругательство", Severity: SeverityReject},
+	})
+
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+		rejected bool
+		flagged  bool
+	}{
+		{"clean", "I had something interesting for breakfast", "I had something interesting for breakfast", false, false},
+		{"masked", "This is a kerfuffle opinion", "This is a **** opinion", false, false},
+		{"punctuation", "What a kerfuffle! Fornax.", "What a ****! ****.", false, false},
+		{"tabs and newlines", "a\tkerfuffle\nhere", "a\t****\nhere", false, false},
+		{"leetspeak", "such k3rfuffl3", "such ****", false, false},
+		{"listed plural", "two kerfuffles", "two ****", false, false},
+		{"unlisted inflection", "kerfuffled", "kerfuffled", false, false},
+		{"inside a word", "superkerfuffle", "superkerfuffle", false, false},
+		{"flagged", "Sharbert!", "Sharbert!", false, true},
+		{"rejected", "это Ругательство", "это Ругательство", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := filter.Check(tt.body)
+			if result.Body != tt.expected {
+				t.Errorf("Expected body %q, got %q", tt.expected, result.Body)
+			}
+			if result.Rejected != tt.rejected || result.Flagged != tt.flagged {
+				t.Errorf("Expected rejected=%v flagged=%v, got %+v", tt.rejected, tt.flagged, result)
+			}
+		})
+	}
+}
+
+func TestCheckInnocentWords(t *testing.T) {
+	filter := NewFilter([]Word{
+		{Word: "ass", Severity: SeverityReject},
+		{Word: "hell", Severity: SeverityMask},
+	})
+	for _, body := range []string{
+		"The class password is in the assessment",
+		"Hello from Sussex, I'm an assassin at chess",
+		"cl@ss p@ssw0rd",
+		"Shell scripts are swell",
+	} {
+		result := filter.Check(body)
+		if result.Body != body || result.Rejected || len(result.Matches) > 0 {
+			t.Errorf("Expected %q to pass unchanged, got %+v", body, result)
+		}
+	}
+	result := filter.Check("what the hell, you @ss")
+	if result.Body != "what the ****, you @ss" || !result.Rejected {
+		t.Errorf("Expected the listed words to match on their own, got %+v", result)
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	_, err := ParseSeverity("mask")
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	_, err = ParseSeverity("ban")
+	if err == nil {
+		t.Error("Expected error for unknown severity, got none")
+	}
+}
@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrInternalAddress is returned for webhooks aimed at loopback, link-local, private and other
+// addresses that aren't reachable from the internet, which would let endpoint owners probe
+// services on Chirpy's network.
+var ErrInternalAddress = errors.New("webhooks can't be sent to internal addresses")
+
+// sharedAddressSpace is the carrier-grade NAT range, which net/netip doesn't count as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// IsPublicAddress reports whether webhooks may be sent to addr.
+func IsPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// NewTransport returns an HTTP transport that only connects to public addresses. The address is
+// checked when dialing, after DNS resolution, so a host that resolved to a public address when
+// the endpoint was registered can't be pointed at an internal one later. It doesn't use a proxy,
+// since the address dialed would then be the proxy's.
+func NewTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   dialControl,
+	}
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+func dialControl(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("webhook: unexpected dial address %q: %w", address, err)
+	}
+	if !IsPublicAddress(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrInternalAddress, addrPort.Addr())
+	}
+	return nil
+}
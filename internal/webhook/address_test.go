@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestIsPublicAddress(t *testing.T) {
+	cases := []struct {
+		addr     string
+		expected bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"0.0.0.0", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"224.0.0.1", false},
+	}
+	for _, c := range cases {
+		if got := IsPublicAddress(netip.MustParseAddr(c.addr)); got != c.expected {
+			t.Errorf("IsPublicAddress(%s) = %v, expected %v", c.addr, got, c.expected)
+		}
+	}
+}
+
+func TestNewTransportRefusesInternalAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	sender := Sender{Client: &http.Client{Transport: NewTransport()}}
+	_, err := sender.Send(context.Background(), Message{URL: server.URL, Body: []byte("{}")}, time.Unix(1700000000, 0))
+	if !errors.Is(err, ErrInternalAddress) {
+		t.Errorf("Expected ErrInternalAddress, got %v", err)
+	}
+	if called {
+		t.Error("Expected the request not to reach the server")
+	}
+}
@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers of webhooks Chirpy sends to third parties. They are signed the same way Polka signs
+// its webhooks: an HMAC-SHA256 of "<timestamp>.<body>" with the endpoint's secret.
+const (
+	OutgoingTimestampHeader = "X-Chirpy-Timestamp"
+	OutgoingSignatureHeader = "X-Chirpy-Signature"
+	EventHeader             = "X-Chirpy-Event"
+	DeliveryHeader          = "X-Chirpy-Delivery"
+)
+
+const (
+	// MaxAttempts is how often a delivery is tried before it is dead-lettered.
+	MaxAttempts  = 12
+	retryBase    = 30 * time.Second
+	retryMaxWait = 6 * time.Hour
+)
+
+// RetryDelay returns how long to wait before the next attempt after the given number of failed
+// attempts. The delay doubles each time: 30s, 1m, 2m, ... capped at 6h.
+func RetryDelay(attempts int) time.Duration {
+	delay := retryBase
+	for i := 1; i < attempts && delay < retryMaxWait; i++ {
+		delay *= 2
+	}
+	return min(delay, retryMaxWait)
+}
+
+// Message is a single webhook to send.
+type Message struct {
+	URL        string
+	Secret     string
+	Event      string
+	DeliveryID string
+	Body       []byte
+}
+
+// Sender posts signed webhooks.
+type Sender struct {
+	Client *http.Client
+}
+
+// Send posts the message and returns the response status code. Any status other than 2xx is an error.
+func (s Sender) Send(ctx context.Context, msg Message, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.URL, bytes.NewReader(msg.Body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(OutgoingTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(OutgoingSignatureHeader, SignatureHeaderValue(Sign(msg.Secret, timestamp, msg.Body)))
+	req.Header.Set(EventHeader, msg.Event)
+	req.Header.Set(DeliveryHeader, msg.DeliveryID)
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
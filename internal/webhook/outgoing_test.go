@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	cases := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour},
+		{100, 6 * time.Hour},
+	}
+	for _, c := range cases {
+		if got := RetryDelay(c.attempts); got != c.expected {
+			t.Errorf("RetryDelay(%d) = %v, expected %v", c.attempts, got, c.expected)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	status := http.StatusOK
+	var received http.Header
+	var receivedBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	msg := Message{URL: server.URL, Secret: "secret", Event: "chirp.created", DeliveryID: "delivery-1", Body: []byte(`{"type":"chirp.created"}`)}
+	code, err := Sender{}.Send(context.Background(), msg, now)
+	if err != nil || code != http.StatusOK {
+		t.Fatalf("Expected 200 and no error, got %d and %v", code, err)
+	}
+	if string(receivedBody) != string(msg.Body) {
+		t.Errorf("Expected body %s, got %s", msg.Body, receivedBody)
+	}
+	if received.Get(EventHeader) != "chirp.created" || received.Get(DeliveryHeader) != "delivery-1" {
+		t.Errorf("Unexpected headers %v", received)
+	}
+	if received.Get(OutgoingSignatureHeader) != SignatureHeaderValue(Sign("secret", now.Unix(), msg.Body)) {
+		t.Errorf("Unexpected signature %s", received.Get(OutgoingSignatureHeader))
+	}
+
+	status = http.StatusServiceUnavailable
+	code, err = Sender{}.Send(context.Background(), msg, now)
+	if err == nil || code != http.StatusServiceUnavailable {
+		t.Errorf("Expected an error with status 503, got %d and %v", code, err)
+	}
+}
@@ -0,0 +1,97 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TimestampHeader = "X-Polka-Timestamp"
+	SignatureHeader = "X-Polka-Signature"
+	signaturePrefix = "v1="
+)
+
+var (
+	ErrMissingSignature = errors.New("missing webhook signature")
+	ErrInvalidTimestamp = errors.New("invalid webhook timestamp")
+	ErrStaleTimestamp   = errors.New("webhook timestamp outside of the tolerance window")
+	ErrInvalidSignature = errors.New("webhook signature does not match")
+)
+
+// Sign computes the hex-encoded HMAC-SHA256 of "<timestamp>.<body>". Binding the timestamp into
+// the signature prevents an attacker from replaying an old body with a fresh timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureHeaderValue formats a signature for the signature header.
+func SignatureHeaderValue(signature string) string {
+	return signaturePrefix + signature
+}
+
+// Verifier checks signed webhook requests. It accepts signatures made with any of its secrets
+// so the sender can rotate secrets without downtime.
+type Verifier struct {
+	Secrets   []string
+	Tolerance time.Duration
+}
+
+// Verify checks the timestamp and signature headers against the raw body and returns a
+// replay-protection key for the signed message. The key only depends on the timestamp and body,
+// so resending a request with the signature in another case or with fewer signatures doesn't
+// change it.
+func (v Verifier) Verify(header http.Header, body []byte, now time.Time) (string, error) {
+	timestampHeader := header.Get(TimestampHeader)
+	signatureHeader := header.Get(SignatureHeader)
+	if timestampHeader == "" || signatureHeader == "" {
+		return "", ErrMissingSignature
+	}
+	timestamp, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return "", ErrInvalidTimestamp
+	}
+	age := now.Sub(time.Unix(timestamp, 0))
+	if age > v.Tolerance || age < -v.Tolerance {
+		return "", ErrStaleTimestamp
+	}
+
+	// The header may carry several signatures, e.g. one per secret during a rotation.
+	for _, candidate := range strings.Split(signatureHeader, ",") {
+		candidate, found := strings.CutPrefix(strings.TrimSpace(candidate), signaturePrefix)
+		if !found {
+			continue
+		}
+		given, err := hex.DecodeString(candidate)
+		if err != nil {
+			continue
+		}
+		for _, secret := range v.Secrets {
+			if secret == "" {
+				continue
+			}
+			expected, _ := hex.DecodeString(Sign(secret, timestamp, body))
+			if hmac.Equal(given, expected) {
+				return replayKey(timestamp, body), nil
+			}
+		}
+	}
+	return "", ErrInvalidSignature
+}
+
+func replayKey(timestamp int64, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	hash.Write([]byte("."))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
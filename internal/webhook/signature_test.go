@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedHeader(secret string, timestamp int64, body []byte) http.Header {
+	header := http.Header{}
+	header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	header.Set(SignatureHeader, SignatureHeaderValue(Sign(secret, timestamp, body)))
+	return header
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+	now := time.Unix(1700000000, 0)
+	verifier := Verifier{Secrets: []string{"new-secret", "old-secret"}, Tolerance: 5 * time.Minute}
+
+	cases := []struct {
+		name     string
+		header   http.Header
+		body     []byte
+		expected error
+	}{
+		{"current secret", signedHeader("new-secret", now.Unix(), body), body, nil},
+		{"previous secret", signedHeader("old-secret", now.Unix(), body), body, nil},
+		{"unknown secret", signedHeader("other-secret", now.Unix(), body), body, ErrInvalidSignature},
+		{"tampered body", signedHeader("new-secret", now.Unix(), body), []byte(`{}`), ErrInvalidSignature},
+		{"too old", signedHeader("new-secret", now.Add(-6*time.Minute).Unix(), body), body, ErrStaleTimestamp},
+		{"too far in the future", signedHeader("new-secret", now.Add(6*time.Minute).Unix(), body), body, ErrStaleTimestamp},
+		{"missing headers", http.Header{}, body, ErrMissingSignature},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := verifier.Verify(c.header, c.body, now)
+			if !errors.Is(err, c.expected) {
+				t.Errorf("Expected %v, got %v", c.expected, err)
+			}
+		})
+	}
+}
+
+func TestVerifyMultipleSignatures(t *testing.T) {
+	body := []byte(`{}`)
+	now := time.Unix(1700000000, 0)
+	header := http.Header{}
+	header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	header.Set(SignatureHeader, SignatureHeaderValue(Sign("unknown", now.Unix(), body))+", "+SignatureHeaderValue(Sign("secret", now.Unix(), body)))
+
+	verifier := Verifier{Secrets: []string{"secret"}, Tolerance: time.Minute}
+	key, err := verifier.Verify(header, body, now)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	single, err := verifier.Verify(signedHeader("secret", now.Unix(), body), body, now)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if key != single {
+		t.Errorf("Expected dropping a signature not to change the replay key, got %s and %s", key, single)
+	}
+}
+
+func TestVerifyReplayKeyIgnoresSignatureCase(t *testing.T) {
+	body := []byte(`{"event":"user.upgraded"}`)
+	now := time.Unix(1700000000, 0)
+	verifier := Verifier{Secrets: []string{"secret"}, Tolerance: time.Minute}
+	key, err := verifier.Verify(signedHeader("secret", now.Unix(), body), body, now)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	header := http.Header{}
+	header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	header.Set(SignatureHeader, SignatureHeaderValue(strings.ToUpper(Sign("secret", now.Unix(), body))))
+	replayed, err := verifier.Verify(header, body, now)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if replayed != key {
+		t.Errorf("Expected an uppercased signature to have the same replay key, got %s and %s", replayed, key)
+	}
+}
@@ -2,16 +2,24 @@ package main
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
+// errorRecorder is implemented by response writers that log the error a request failed with.
+type errorRecorder interface {
+	setError(err error)
+}
+
+// respondWithError answers with msg. The underlying err is logged with the request's access
+// log line rather than sent to the client.
 func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
 	if err != nil {
-		log.Println(err)
-	}
-	if code > 499 {
-		log.Printf("Responding with 5XX error: %s", err)
+		if rec, ok := w.(errorRecorder); ok {
+			rec.setError(err)
+		} else {
+			slog.Error("Request failed", "status", code, "err", err)
+		}
 	}
 	type errorResponse struct {
 		Error string `json:"error"`
@@ -25,7 +33,7 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	dat, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
+		slog.Error("Couldn't marshal JSON", "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// shutdownConfig controls how the server drains when it is asked to stop.
+type shutdownConfig struct {
+	// Delay is how long readiness reports unhealthy before the listener closes, giving load
+	// balancers time to stop sending new requests.
+	Delay time.Duration
+	// Timeout bounds how long in-flight requests may take to finish.
+	Timeout time.Duration
+}
+
+// startWorkers runs the background workers until ctx is canceled. The returned WaitGroup is
+// done once they have all returned.
+func (cfg *apiConfig) startWorkers(ctx context.Context) *sync.WaitGroup {
+	workers := &sync.WaitGroup{}
+	for _, run := range []func(context.Context){
+		func(ctx context.Context) { cfg.runWebhookDeliveries(ctx, 5*time.Second) },
+		func(ctx context.Context) { cfg.runProfanityFilterReload(ctx, time.Minute) },
+	} {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(ctx)
+		}()
+	}
+	return workers
+}
+
+// serve runs the server until ctx is canceled, then flips readiness and drains in-flight
+// requests, closing the connections of those that outlast the shutdown timeout. It returns
+// early if the server fails to start.
+func (cfg *apiConfig) serve(ctx context.Context, server *http.Server, shutdown shutdownConfig) error {
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Starting server", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down, draining requests")
+	cfg.draining.Store(true)
+	time.Sleep(shutdown.Delay)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdown.Timeout)
+	defer cancel()
+	err := server.Shutdown(shutdownCtx)
+	if err != nil {
+		// Requests still running past the timeout have their connections closed, so they
+		// don't go on using the workers and the database after those are stopped.
+		slog.Warn("Requests didn't finish in time, closing their connections", "err", err)
+		return errors.Join(err, server.Close())
+	}
+	err = <-serverErr
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// redactedKeys are attribute keys whose values never make it into the logs.
+var redactedKeys = []string{"password", "token", "secret", "authorization", "api_key", "apikey", "cookie", "code_verifier"}
+
+// newLogger returns the JSON logger, or a text logger for local use, with sensitive values redacted.
+func newLogger(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+	switch format {
+	case "", "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, redacted := range redactedKeys {
+		if strings.Contains(key, redacted) {
+			return slog.String(a.Key, "[REDACTED]")
+		}
+	}
+	return a
+}
+
+type loggerContextKey struct{}
+
+// loggerFromContext returns the request's logger, which carries its request ID, or the
+// default logger outside of requests.
+func loggerFromContext(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(loggerContextKey{}).(*slog.Logger)
+	if !ok {
+		return slog.Default()
+	}
+	return logger
+}
+
+// setRequestUser records the authenticated user for the access log.
+func setRequestUser(ctx context.Context, userID uuid.UUID) {
+	info := requestInfoFromContext(ctx)
+	if info.user != nil {
+		*info.user = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+}
+
+// middlewareAccessLog logs one line per request once it has been served. The path is logged
+// without its query string, which can carry OAuth codes and state.
+func middlewareAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		info := requestInfoFromContext(r.Context())
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", info.Route()),
+			slog.Int("status", rec.status),
+			slog.Int64("bytes", rec.bytes),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("ip", info.IP),
+			slog.String("user_agent", r.UserAgent()),
+		}
+		if info.user != nil && info.user.Valid {
+			attrs = append(attrs, slog.String("user_id", info.user.UUID.String()))
+		}
+		level := slog.LevelInfo
+		if rec.err != nil {
+			attrs = append(attrs, slog.String("error", rec.err.Error()))
+		}
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		loggerFromContext(r.Context()).LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
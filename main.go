@@ -1,86 +1,378 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/acramatte/Chirpy/internal/auth"
+	"github.com/acramatte/Chirpy/internal/config"
 	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/acramatte/Chirpy/internal/entitlements"
+	"github.com/acramatte/Chirpy/internal/health"
+	"github.com/acramatte/Chirpy/internal/memory"
+	"github.com/acramatte/Chirpy/internal/migrations"
+	"github.com/acramatte/Chirpy/internal/oidc"
+	"github.com/acramatte/Chirpy/internal/profanity"
+	"github.com/acramatte/Chirpy/internal/webhook"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
+	"os/signal"
 	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 type apiConfig struct {
-	fileserverHits  atomic.Int32
-	db              *database.Queries
-	platform        string
-	jwtSecret       string
-	polkaWebhookKey string
+	fileserverHits atomic.Int32
+	// The repositories are backed by the database, or by the in-memory store in tests and with
+	// DB_URL=memory:, see useRepositories.
+	users                database.UserRepository
+	chirps               database.ChirpRepository
+	refreshTokens        database.RefreshTokenRepository
+	subscriptions        database.SubscriptionRepository
+	loginThrottles       database.LoginThrottleRepository
+	auditLog             database.AuditLogRepository
+	personalAccessTokens database.PersonalAccessTokenRepository
+	oauth                database.OAuthRepository
+	identities           database.IdentityRepository
+	webhookEndpoints     database.WebhookEndpointRepository
+	webhookEvents        database.WebhookEventRepository
+	reports              database.ReportRepository
+	notifications        database.NotificationRepository
+	moderationWords      database.ModerationWordRepository
+
+	config         config.Config
+	polkaVerifier  webhook.Verifier
+	oidcProvider   *oidc.Provider
+	passwordPolicy auth.PasswordPolicy
+	trustedProxies []netip.Prefix
+	entitlements   entitlements.Table
+	webhookSender  webhook.Sender
+	// profanityFilter is swapped out whenever the moderation word list changes.
+	profanityFilter atomic.Pointer[profanity.Filter]
+	// draining is set once shutdown starts, failing readiness checks.
+	draining atomic.Bool
+	// health holds the readiness checks; subsystems register their own.
+	health  *health.Registry
+	metrics *serverMetrics
 }
 
 func main() {
+	// A .env file is optional; deployments usually set the environment or use a config file.
 	err := godotenv.Load()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatal(err)
+	}
+	conf, opts, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%s", err)
+	}
+	if opts.PrintConfig {
+		err = conf.WriteRedacted(os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	args := opts.Args
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+	// Commands other than serve keep stdout for their own output.
+	logOutput := os.Stdout
+	if args[0] != "serve" {
+		logOutput = os.Stderr
+	}
+	logger, err := newLogger(logOutput, conf.LogFormat, conf.SlogLevel())
 	if err != nil {
 		log.Fatal(err)
 	}
-	dbURL := MustEnv("DB_URL")
-	platform := MustEnv("PLATFORM")
-	jwtSecret := MustEnv("JWT_SECRET")
-	polkaWebhookKey := MustEnv("POLKA_KEY")
+	// Also routes the standard library's log package, used for fatal startup errors, through slog.
+	slog.SetDefault(logger)
 
-	db, err := sql.Open("postgres", dbURL)
+	err = runCommand(conf, args, os.Stdin, os.Stdout)
+	if err != nil && args[0] == "serve" {
+		log.Fatal(err)
+	}
 	if err != nil {
-		log.Fatalf("Error opening database: %s", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	dbQueries := database.New(db)
+}
 
-	fs := http.FileServer(http.Dir("."))
-	apiCfg := apiConfig{
-		fileserverHits:  atomic.Int32{},
-		db:              dbQueries,
-		platform:        platform,
-		jwtSecret:       jwtSecret,
-		polkaWebhookKey: polkaWebhookKey,
+// newAPIConfig sets up everything handlers and commands share. db is the database behind repos,
+// or nil if they are kept in memory.
+func newAPIConfig(conf config.Config, repos repositories, db *sql.DB) (*apiConfig, error) {
+	// POLKA_KEY_PREVIOUS keeps the old secret valid while Polka rotates to a new POLKA_KEY.
+	polkaVerifier := webhook.Verifier{
+		Secrets:   []string{conf.PolkaKey, conf.PolkaKeyPrevious},
+		Tolerance: conf.PolkaTolerance,
+	}
+
+	// OIDC login is optional and only enabled when an issuer is configured.
+	var oidcProvider *oidc.Provider
+	if conf.OIDCIssuer != "" {
+		oidcProvider = oidc.NewProvider(conf.OIDCIssuer, conf.OIDCClientID, conf.OIDCClientSecret, conf.OIDCRedirectURL)
+	}
+
+	err := auth.SetPasswordParams(auth.Argon2Params{
+		Memory:      uint32(conf.Argon2MemoryKiB),
+		Iterations:  uint32(conf.Argon2Iterations),
+		Parallelism: uint8(conf.Argon2Parallelism),
+		SaltLength:  auth.DefaultArgon2Params.SaltLength,
+		KeyLength:   auth.DefaultArgon2Params.KeyLength,
+	})
+	if err != nil {
+		return nil, err
+	}
+	passwordPolicy := auth.PasswordPolicy{
+		MinLength: conf.PasswordMinLength,
+		MaxLength: 256,
+	}
+	if conf.BreachedPasswordsFile != "" {
+		err = passwordPolicy.LoadBreachedPasswords(conf.BreachedPasswordsFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// ENTITLEMENTS_FILE lets the product team change tier perks without a code change.
+	entitlementTable := entitlements.Default
+	if conf.EntitlementsFile != "" {
+		entitlementTable, err = entitlements.Load(conf.EntitlementsFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Webhooks can only reach internal addresses in local development.
+	var webhookTransport http.RoundTripper = webhook.NewTransport()
+	if conf.Platform == "dev" {
+		webhookTransport = http.DefaultTransport
+	}
+
+	cfg := &apiConfig{
+		config:         conf,
+		polkaVerifier:  polkaVerifier,
+		oidcProvider:   oidcProvider,
+		passwordPolicy: passwordPolicy,
+		trustedProxies: conf.TrustedProxyPrefixes(),
+		entitlements:   entitlementTable,
+		webhookSender:  webhook.Sender{Client: &http.Client{Timeout: conf.WebhookTimeout, Transport: otelhttp.NewTransport(webhookTransport)}},
+		health:         &health.Registry{Timeout: 2 * time.Second},
+		metrics:        newServerMetrics(db),
+	}
+	cfg.useRepositories(repos)
+	return cfg, nil
+}
+
+// useRepositories backs every repository with repos.
+func (cfg *apiConfig) useRepositories(repos repositories) {
+	cfg.users = repos
+	cfg.chirps = repos
+	cfg.refreshTokens = repos
+	cfg.subscriptions = repos
+	cfg.loginThrottles = repos
+	cfg.auditLog = repos
+	cfg.personalAccessTokens = repos
+	cfg.oauth = repos
+	cfg.identities = repos
+	cfg.webhookEndpoints = repos
+	cfg.webhookEvents = repos
+	cfg.reports = repos
+	cfg.notifications = repos
+	cfg.moderationWords = repos
+}
+
+// runServer runs the HTTP server until it receives SIGINT or SIGTERM.
+func runServer(conf config.Config) error {
+	shutdownTracing, err := setupTracing(context.Background(), conf.OTELTracesExporter)
+	if err != nil {
+		return fmt.Errorf("couldn't set up tracing: %w", err)
 	}
-	apiCfg.fileserverHits.Store(0)
 
+	var db *sql.DB
+	var repos repositories
+	var schemaVersion int64
+	if conf.DBURL == memoryDBURL {
+		slog.Warn("Keeping all data in memory; it is lost when the server stops")
+		repos = memory.New()
+	} else {
+		var dialect migrations.Dialect
+		db, dialect, err = openDatabase(conf.DBURL)
+		if err != nil {
+			return fmt.Errorf("couldn't open database: %w", err)
+		}
+		// AUTO_MIGRATE is opt-in so that deployments that migrate in a separate step aren't surprised.
+		if conf.AutoMigrate {
+			err = autoMigrate(context.Background(), db, dialect)
+			if err != nil {
+				return fmt.Errorf("couldn't migrate database: %w", err)
+			}
+		}
+		repos, err = newRepositories(db, dialect)
+		if err != nil {
+			return err
+		}
+		schemaVersion, err = migrations.Latest(schemaMigrations(dialect))
+		if err != nil {
+			return fmt.Errorf("couldn't read embedded migrations: %w", err)
+		}
+	}
+	apiCfg, err := newAPIConfig(conf, repos, db)
+	if err != nil {
+		return err
+	}
+	apiCfg.registerHealthChecks(db, schemaVersion)
+	if conf.BootstrapAdminEmail != "" {
+		err = apiCfg.bootstrapAdmin(context.Background(), conf.BootstrapAdminEmail)
+		if err != nil {
+			return fmt.Errorf("couldn't bootstrap admin: %w", err)
+		}
+	}
+	err = apiCfg.reloadProfanityFilter(context.Background())
+	if err != nil {
+		return fmt.Errorf("couldn't load moderation words: %w", err)
+	}
+
+	fs := http.FileServer(http.Dir("."))
 	serveMux := http.NewServeMux()
 	fsHandler := apiCfg.middlewareMetricsInc(http.StripPrefix("/app", fs))
 	serveMux.Handle("/app/", fsHandler)
 
-	serveMux.HandleFunc("GET /api/healthz", handlerReadiness)
+	serveMux.Handle("GET /metrics", apiCfg.metrics.handler())
+	serveMux.HandleFunc("GET /api/livez", handlerLiveness)
+	serveMux.HandleFunc("GET /api/readyz", apiCfg.handlerReadiness)
+	// healthz predates the split and is kept as a liveness probe for existing deployments.
+	serveMux.HandleFunc("GET /api/healthz", handlerLiveness)
 
 	serveMux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
+	serveMux.HandleFunc("GET /api/login/oidc", apiCfg.handlerLoginOIDC)
+	serveMux.HandleFunc("GET /api/login/oidc/callback", apiCfg.handlerLoginOIDCCallback)
 	serveMux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
 	serveMux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
 
 	serveMux.HandleFunc("GET /api/chirps", apiCfg.handlerChirpsRetrieve)
 	serveMux.HandleFunc("POST /api/chirps", apiCfg.handlerChirpsCreate)
 	serveMux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerChirpGet)
+	serveMux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.handlerChirpUpdate)
 	serveMux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerChirpDelete)
+	serveMux.HandleFunc("POST /api/chirps/{chirpID}/pin", apiCfg.handlerChirpPin)
+	serveMux.HandleFunc("DELETE /api/chirps/{chirpID}/pin", apiCfg.handlerChirpUnpin)
+	serveMux.HandleFunc("POST /api/chirps/{chirpID}/report", apiCfg.handlerChirpReport)
 
 	serveMux.HandleFunc("POST /api/users", apiCfg.handlerUsersCreation)
 	serveMux.HandleFunc("PUT /api/users", apiCfg.handlerUsersUpdate)
+	serveMux.HandleFunc("POST /api/users/{userID}/report", apiCfg.handlerUserReport)
+
+	serveMux.HandleFunc("GET /api/notifications", apiCfg.handlerNotificationsList)
+	serveMux.HandleFunc("POST /api/notifications/{notificationID}/read", apiCfg.handlerNotificationRead)
+
+	serveMux.HandleFunc("GET /api/subscriptions", apiCfg.handlerSubscriptionsList)
+
+	serveMux.HandleFunc("POST /api/tokens", apiCfg.handlerTokensCreate)
+	serveMux.HandleFunc("GET /api/tokens", apiCfg.handlerTokensList)
+	serveMux.HandleFunc("DELETE /api/tokens/{tokenID}", apiCfg.handlerTokenRevoke)
+
+	serveMux.HandleFunc("POST /api/oauth/clients", apiCfg.handlerOAuthClientsCreate)
+	serveMux.HandleFunc("GET /api/oauth/clients", apiCfg.handlerOAuthClientsList)
+	serveMux.HandleFunc("DELETE /api/oauth/clients/{clientID}", apiCfg.handlerOAuthClientDelete)
+
+	serveMux.HandleFunc("GET /oauth/authorize", apiCfg.handlerOAuthAuthorizeInfo)
+	serveMux.HandleFunc("POST /oauth/authorize", apiCfg.handlerOAuthAuthorize)
+	serveMux.HandleFunc("POST /oauth/token", apiCfg.handlerOAuthToken)
+	serveMux.HandleFunc("POST /oauth/introspect", apiCfg.handlerOAuthIntrospect)
+	serveMux.HandleFunc("POST /oauth/revoke", apiCfg.handlerOAuthRevoke)
 
 	serveMux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerUpgradeRed)
 
-	serveMux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
-	serveMux.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics)
+	serveMux.Handle("POST /api/webhooks/endpoints", apiCfg.userWebhooks(apiCfg.handlerWebhookEndpointsCreate))
+	serveMux.Handle("GET /api/webhooks/endpoints", apiCfg.userWebhooks(apiCfg.handlerWebhookEndpointsList))
+	serveMux.Handle("DELETE /api/webhooks/endpoints/{endpointID}", apiCfg.userWebhooks(apiCfg.handlerWebhookEndpointDelete))
+	serveMux.Handle("GET /api/webhooks/endpoints/{endpointID}/deliveries", apiCfg.userWebhooks(apiCfg.handlerWebhookDeliveriesList))
+	serveMux.Handle("POST /api/webhooks/endpoints/{endpointID}/deliveries/{deliveryID}/retry", apiCfg.userWebhooks(apiCfg.handlerWebhookDeliveryRetry))
+
+	// Every /admin/ route requires at least a moderator; routes that change the system require an admin.
+	adminMux := http.NewServeMux()
+	admin := func(handler http.HandlerFunc) http.Handler {
+		return apiCfg.middlewareRole(auth.RoleAdmin, handler)
+	}
+	adminMux.Handle("POST /admin/reset", admin(apiCfg.handlerReset))
+	adminMux.Handle("GET /admin/metrics", admin(apiCfg.handlerMetrics))
+	adminMux.Handle("PUT /admin/users/{userID}/role", admin(apiCfg.handlerAdminUserRole))
+	adminMux.Handle("GET /admin/audit", admin(apiCfg.handlerAdminAuditList))
+	adminMux.Handle("GET /admin/audit/export", admin(apiCfg.handlerAdminAuditExport))
+	adminMux.Handle("POST /admin/login/unlock", admin(apiCfg.handlerAdminUnlock))
+	adminMux.Handle("GET /admin/webhooks/events", admin(apiCfg.handlerAdminWebhookEventsList))
+	adminMux.Handle("GET /admin/webhooks/events/{eventID}", admin(apiCfg.handlerAdminWebhookEventGet))
+	adminMux.Handle("POST /admin/webhooks/events/{eventID}/replay", admin(apiCfg.handlerAdminWebhookEventReplay))
+	adminMux.Handle("POST /admin/webhooks/endpoints", apiCfg.adminWebhooks(apiCfg.handlerWebhookEndpointsCreate))
+	adminMux.Handle("GET /admin/webhooks/endpoints", apiCfg.adminWebhooks(apiCfg.handlerWebhookEndpointsList))
+	adminMux.Handle("DELETE /admin/webhooks/endpoints/{endpointID}", apiCfg.adminWebhooks(apiCfg.handlerWebhookEndpointDelete))
+	adminMux.Handle("GET /admin/webhooks/endpoints/{endpointID}/deliveries", apiCfg.adminWebhooks(apiCfg.handlerWebhookDeliveriesList))
+	adminMux.Handle("POST /admin/webhooks/endpoints/{endpointID}/deliveries/{deliveryID}/retry", apiCfg.adminWebhooks(apiCfg.handlerWebhookDeliveryRetry))
+	adminMux.Handle("GET /admin/moderation/words", admin(apiCfg.handlerModerationWordsList))
+	adminMux.Handle("POST /admin/moderation/words", admin(apiCfg.handlerModerationWordsCreate))
+	adminMux.Handle("PUT /admin/moderation/words/{wordID}", admin(apiCfg.handlerModerationWordUpdate))
+	adminMux.Handle("DELETE /admin/moderation/words/{wordID}", admin(apiCfg.handlerModerationWordDelete))
+	adminMux.HandleFunc("GET /admin/moderation/flagged", apiCfg.handlerFlaggedChirpsList)
+	adminMux.HandleFunc("GET /admin/moderation/reports", apiCfg.handlerModerationReportsList)
+	adminMux.HandleFunc("GET /admin/moderation/reports/{reportID}", apiCfg.handlerModerationReportGet)
+	adminMux.HandleFunc("POST /admin/moderation/reports/{reportID}/actions", apiCfg.handlerModerationReportAction)
+	adminMux.HandleFunc("GET /admin/moderation/users/{userID}/actions", apiCfg.handlerModerationUserActions)
+	adminMux.HandleFunc("POST /admin/moderation/users/{userID}/suspend", apiCfg.handlerModerationUserState(moderationActionSuspend))
+	adminMux.HandleFunc("DELETE /admin/moderation/users/{userID}/suspend", apiCfg.handlerModerationUserState(moderationActionUnsuspend))
+	adminMux.HandleFunc("POST /admin/moderation/users/{userID}/shadowban", apiCfg.handlerModerationUserState(moderationActionShadowban))
+	adminMux.HandleFunc("DELETE /admin/moderation/users/{userID}/shadowban", apiCfg.handlerModerationUserState(moderationActionUnshadowban))
+	serveMux.Handle("/admin/", apiCfg.middlewareRole(auth.RoleModerator, recordRoute(adminMux)))
+
+	// The first SIGINT or SIGTERM starts a graceful shutdown; a second one kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	workers := apiCfg.startWorkers(workerCtx)
 
 	server := &http.Server{
-		Addr:    ":8080",
-		Handler: serveMux,
+		Addr:         conf.HTTPAddr,
+		Handler:      otelhttp.NewHandler(apiCfg.middlewareRequestInfo(middlewareSpanRoute(middlewareAccessLog(apiCfg.metrics.middlewareHTTPMetrics(recordRoute(serveMux))))), "http.server"),
+		ErrorLog:     slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+		ReadTimeout:  conf.HTTPReadTimeout,
+		WriteTimeout: conf.HTTPWriteTimeout,
+		IdleTimeout:  conf.HTTPIdleTimeout,
+	}
+	err = apiCfg.serve(ctx, server, shutdownConfig{
+		Delay:   conf.ShutdownDelay,
+		Timeout: conf.ShutdownTimeout,
+	})
+	if err != nil {
+		slog.Error("Server stopped uncleanly", "err", err)
 	}
-	log.Println("Starting server on :8080")
-	log.Fatal(server.ListenAndServe())
-}
 
-// MustEnv reads an environment variable and terminates immediately if it is missing
-func MustEnv(key string) string {
-	val := os.Getenv(key)
-	if val == "" {
-		log.Fatalf("Environment variable %s must be set", key)
+	// A delivery interrupted here is retried by another instance once its lease expires.
+	stopWorkers()
+	workers.Wait()
+	if db != nil {
+		err = db.Close()
+		if err != nil {
+			slog.Error("Couldn't close the database", "err", err)
+		}
+	}
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	err = shutdownTracing(flushCtx)
+	if err != nil {
+		slog.Error("Couldn't flush traces", "err", err)
 	}
-	return val
+	slog.Info("Server stopped")
+	return nil
 }
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
-	"log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func (cfg *apiConfig) handlerMetrics(w http.ResponseWriter, r *http.Request) {
@@ -20,7 +26,7 @@ func (cfg *apiConfig) handlerMetrics(w http.ResponseWriter, r *http.Request) {
 	_, err := w.Write([]byte(fmt.Sprintf(html, cfg.fileserverHits.Load())))
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Println("Fail to write metrics response:", err)
+		loggerFromContext(r.Context()).Error("Couldn't write metrics response", "err", err)
 	}
 }
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -29,3 +35,136 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// serverMetrics are exported in the Prometheus format at /metrics.
+type serverMetrics struct {
+	registry *prometheus.Registry
+
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	requestsInFlight prometheus.Gauge
+
+	chirpsCreated     prometheus.Counter
+	logins            *prometheus.CounterVec
+	webhookEvents     *prometheus.CounterVec
+	webhookDeliveries *prometheus.CounterVec
+}
+
+// newServerMetrics registers the server's metrics, and the connection pool stats of db unless it is
+// nil.
+func newServerMetrics(db *sql.DB) *serverMetrics {
+	m := &serverMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_http_requests_total",
+			Help: "HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_request_duration_seconds",
+			Help:    "HTTP request latency by route and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		requestsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "chirpy_http_requests_in_flight",
+			Help: "HTTP requests currently being served.",
+		}),
+		chirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_chirps_created_total",
+			Help: "Chirps created.",
+		}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_logins_total",
+			Help: "Login attempts by method and result.",
+		}, []string{"method", "result"}),
+		webhookEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_webhook_events_total",
+			Help: "Incoming webhook events by provider, event type and outcome.",
+		}, []string{"provider", "event", "status"}),
+		webhookDeliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_webhook_deliveries_total",
+			Help: "Outgoing webhook delivery attempts by outcome.",
+		}, []string{"status"}),
+	}
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.requestsInFlight,
+		m.chirpsCreated,
+		m.logins,
+		m.webhookEvents,
+		m.webhookDeliveries,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, "chirpy"))
+	}
+	return m
+}
+
+func (m *serverMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// middlewareHTTPMetrics records every request by the route pattern it matched rather than by
+// its path, which would give every chirp its own time series.
+func (m *serverMetrics) middlewareHTTPMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.requestsInFlight.Inc()
+		defer m.requestsInFlight.Dec()
+		start := time.Now()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		route := requestInfoFromContext(r.Context()).Route()
+		m.requests.WithLabelValues(route, r.Method, strconv.Itoa(rec.status)).Inc()
+		m.requestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusRecorder remembers the status code and size of the response written through it, and
+// the error respondWithError answered with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	bytes       int64
+	err         error
+}
+
+// setError is called by respondWithError. It is passed on to recorders further out, so that
+// every middleware sees it.
+func (rec *statusRecorder) setError(err error) {
+	rec.err = err
+	if inner, ok := rec.ResponseWriter.(errorRecorder); ok {
+		inner.setError(err)
+	}
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status = code
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+func (rec *statusRecorder) Flush() {
+	err := http.NewResponseController(rec.ResponseWriter).Flush()
+	if err != nil {
+		slog.Error("Couldn't flush response", "err", err)
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection, e.g. to change deadlines.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
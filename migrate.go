@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/acramatte/Chirpy/internal/migrations"
+	"io"
+	"log/slog"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = "usage: chirpy [flags] migrate up|down|status"
+
+// runMigrate runs the migrate subcommand against the embedded migrations.
+func runMigrate(ctx context.Context, db *sql.DB, dialect migrations.Dialect, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+	switch args[0] {
+	case "up":
+		applied, err := migrations.Up(ctx, db, dialect, schemaMigrations(dialect))
+		for _, m := range applied {
+			fmt.Fprintf(w, "Applied %s\n", m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(w, "Database schema is up to date")
+		}
+		return nil
+	case "down":
+		m, found, err := migrations.Down(ctx, db, dialect, schemaMigrations(dialect))
+		if err != nil {
+			return err
+		}
+		if !found {
+			fmt.Fprintln(w, "No migration to roll back")
+			return nil
+		}
+		fmt.Fprintf(w, "Rolled back %s\n", m.Name)
+		return nil
+	case "status":
+		statuses, err := migrations.Statuses(ctx, db, dialect, schemaMigrations(dialect))
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tMIGRATION\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if !s.AppliedAt.IsZero() {
+				appliedAt = s.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return tw.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+// autoMigrate applies pending migrations on boot. On Postgres, the advisory lock makes replicas
+// that start together wait for the first one to finish instead of racing it.
+func autoMigrate(ctx context.Context, db *sql.DB, dialect migrations.Dialect) error {
+	applied, err := migrations.Up(ctx, db, dialect, schemaMigrations(dialect))
+	for _, m := range applied {
+		slog.Info("Applied migration", "migration", m.Name)
+	}
+	return err
+}
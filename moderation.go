@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/acramatte/Chirpy/internal/profanity"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// reloadProfanityFilter rebuilds the filter from the moderation word list. Chirps being checked
+// keep using the previous filter, so reloading never blocks requests.
+func (cfg *apiConfig) reloadProfanityFilter(ctx context.Context) error {
+	dbWords, err := cfg.moderationWords.GetModerationWords(ctx)
+	if err != nil {
+		return err
+	}
+	words := make([]profanity.Word, 0, len(dbWords))
+	for _, w := range dbWords {
+		words = append(words, profanity.Word{Word: w.Word, Severity: profanity.Severity(w.Severity)})
+	}
+	cfg.profanityFilter.Store(profanity.NewFilter(words))
+	return nil
+}
+
+// runProfanityFilterReload picks up word list changes made by other instances until ctx is canceled.
+func (cfg *apiConfig) runProfanityFilterReload(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := cfg.reloadProfanityFilter(ctx)
+			if err != nil {
+				slog.Error("Couldn't reload the profanity filter", "err", err)
+			}
+		}
+	}
+}
+
+// moderateChirp checks a chirp body against the word list. It responds with an error and returns
+// false when the chirp is rejected.
+func (cfg *apiConfig) moderateChirp(w http.ResponseWriter, body string) (profanity.Result, bool) {
+	result := cfg.profanityFilter.Load().Check(body)
+	if result.Rejected {
+		respondWithError(w, http.StatusBadRequest, "Chirp contains prohibited words", nil)
+		return result, false
+	}
+	return result, true
+}
+
+// suspendUser keeps the user from signing in and hides their chirps until the given time, or
+// permanently when until is NULL, and ends their sessions.
+func (cfg *apiConfig) suspendUser(ctx context.Context, userID uuid.UUID, until sql.NullTime) error {
+	_, err := cfg.users.SuspendUser(ctx, database.SuspendUserParams{
+		ID:             userID,
+		SuspendedUntil: until,
+	})
+	if err != nil {
+		return err
+	}
+	return cfg.refreshTokens.RevokeUserRefreshTokens(ctx, userID)
+}
+
+// isSuspended reports whether the user is currently suspended.
+func isSuspended(user database.User, now time.Time) bool {
+	return user.SuspendedAt.Valid && (!user.SuspendedUntil.Valid || user.SuspendedUntil.Time.After(now))
+}
+
+// suspensionMessage tells a suspended user until when they are suspended.
+func suspensionMessage(user database.User) string {
+	if !user.SuspendedUntil.Valid {
+		return "Account suspended permanently"
+	}
+	return "Account suspended until " + user.SuspendedUntil.Time.Format(time.RFC3339)
+}
+
+var errAccountSuspended = errors.New("account is suspended")
+
+// checkAccountActive returns an error when the user may not use or get tokens.
+func (cfg *apiConfig) checkAccountActive(ctx context.Context, userID uuid.UUID) error {
+	user, err := cfg.users.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if isSuspended(user, time.Now().UTC()) {
+		return fmt.Errorf("%w: %s", errAccountSuspended, strings.ToLower(suspensionMessage(user)))
+	}
+	return nil
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/acramatte/Chirpy/internal/auth"
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/acramatte/Chirpy/internal/webhook"
+	"github.com/google/uuid"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Events that webhook endpoints can subscribe to.
+const (
+	eventChirpCreated   = "chirp.created"
+	eventChirpUpdated   = "chirp.updated"
+	eventChirpDeleted   = "chirp.deleted"
+	eventUserUpgraded   = "user.upgraded"
+	eventUserDowngraded = "user.downgraded"
+)
+
+var webhookEventTypes = []string{eventChirpCreated, eventChirpUpdated, eventChirpDeleted, eventUserUpgraded, eventUserDowngraded}
+
+const (
+	deliveryStatusPending   = "pending"
+	deliveryStatusDelivered = "delivered"
+	deliveryStatusDead      = "dead"
+
+	deliveryBatchSize = 20
+	// A claimed delivery is leased for WEBHOOK_TIMEOUT plus deliveryLeaseMargin. The lease must
+	// outlast the attempt, otherwise another worker could send it again.
+	deliveryLeaseMargin = time.Minute
+)
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+}
+
+func webhookEndpointFromDB(endpoint database.WebhookEndpoint) WebhookEndpoint {
+	return WebhookEndpoint{
+		ID:        endpoint.ID,
+		CreatedAt: endpoint.CreatedAt,
+		URL:       endpoint.Url,
+		Events:    strings.Fields(endpoint.Events),
+	}
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastStatusCode *int32          `json:"last_status_code"`
+	LastError      *string         `json:"last_error"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+func webhookDeliveryFromDB(delivery database.WebhookDelivery) WebhookDelivery {
+	resp := WebhookDelivery{
+		ID:          delivery.ID,
+		CreatedAt:   delivery.CreatedAt,
+		EventID:     delivery.EventID,
+		EventType:   delivery.EventType,
+		Payload:     json.RawMessage(delivery.Payload),
+		Status:      delivery.Status,
+		Attempts:    delivery.Attempts,
+		DeliveredAt: nullTimePtr(delivery.DeliveredAt),
+	}
+	if delivery.Status == deliveryStatusPending {
+		resp.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.LastStatusCode.Valid {
+		resp.LastStatusCode = &delivery.LastStatusCode.Int32
+	}
+	if delivery.LastError.Valid {
+		resp.LastError = &delivery.LastError.String
+	}
+	return resp
+}
+
+// emitEvent queues an event for every endpoint subscribed to it. Endpoints registered by a user
+// only receive events about that user, endpoints registered by admins receive all of them.
+// Failing to queue an event never fails the request that caused it.
+func (cfg *apiConfig) emitEvent(ctx context.Context, eventType string, ownerID uuid.UUID, data interface{}) {
+	err := cfg.queueEvent(ctx, eventType, ownerID, data)
+	if err != nil {
+		loggerFromContext(ctx).Error("Couldn't queue webhooks", "event", eventType, "err", err)
+	}
+}
+
+func (cfg *apiConfig) queueEvent(ctx context.Context, eventType string, ownerID uuid.UUID, data interface{}) error {
+	endpoints, err := cfg.webhookEndpoints.GetWebhookEndpointsForOwner(ctx, uuid.NullUUID{UUID: ownerID, Valid: true})
+	if err != nil {
+		return err
+	}
+	eventID := uuid.New()
+	payload, err := json.Marshal(struct {
+		ID        uuid.UUID   `json:"id"`
+		Type      string      `json:"type"`
+		CreatedAt time.Time   `json:"created_at"`
+		Data      interface{} `json:"data"`
+	}{ID: eventID, Type: eventType, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return err
+	}
+	for _, endpoint := range endpoints {
+		if !slices.Contains(strings.Fields(endpoint.Events), eventType) {
+			continue
+		}
+		_, err = cfg.webhookEndpoints.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			EndpointID: endpoint.ID,
+			EventID:    eventID,
+			EventType:  eventType,
+			Payload:    string(payload),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chirpEventData is the chirp as sent in chirp.* events.
+func chirpEventData(chirp database.Chirp) interface{} {
+	return struct {
+		ID        uuid.UUID `json:"id"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+		Body      string    `json:"body"`
+		UserID    uuid.UUID `json:"user_id"`
+	}{ID: chirp.ID, CreatedAt: chirp.CreatedAt, UpdatedAt: chirp.UpdatedAt, Body: chirp.Body, UserID: chirp.UserID}
+}
+
+// runWebhookDeliveries sends due deliveries until ctx is canceled.
+func (cfg *apiConfig) runWebhookDeliveries(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := cfg.deliverDueWebhooks(ctx)
+			if err != nil {
+				slog.Error("Couldn't deliver webhooks", "err", err)
+			}
+		}
+	}
+}
+
+// deliverDueWebhooks sends up to deliveryBatchSize due deliveries. They are claimed one at a time
+// so that each lease only has to cover a single attempt rather than the whole batch.
+func (cfg *apiConfig) deliverDueWebhooks(ctx context.Context) error {
+	for range deliveryBatchSize {
+		deliveries, err := cfg.webhookEndpoints.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+			NextAttemptAt: time.Now().UTC().Add(cfg.config.WebhookTimeout + deliveryLeaseMargin),
+			Limit:         1,
+		})
+		if err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+		err = cfg.deliverWebhook(ctx, deliveries[0])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliverWebhook makes one attempt and records its outcome. Deliveries that keep failing are
+// dead-lettered and only sent again when retried through the API.
+func (cfg *apiConfig) deliverWebhook(ctx context.Context, delivery database.WebhookDelivery) error {
+	endpoint, err := cfg.webhookEndpoints.GetWebhookEndpoint(ctx, delivery.EndpointID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	code, sendErr := cfg.webhookSender.Send(ctx, webhook.Message{
+		URL:        endpoint.Url,
+		Secret:     endpoint.Secret,
+		Event:      delivery.EventType,
+		DeliveryID: delivery.ID.String(),
+		Body:       []byte(delivery.Payload),
+	}, time.Now())
+	statusCode := sql.NullInt32{Int32: int32(code), Valid: code != 0}
+	if sendErr == nil {
+		cfg.metrics.webhookDeliveries.WithLabelValues(deliveryStatusDelivered).Inc()
+		return cfg.webhookEndpoints.MarkWebhookDeliveryDelivered(ctx, database.MarkWebhookDeliveryDeliveredParams{
+			ID:             delivery.ID,
+			LastStatusCode: statusCode,
+		})
+	}
+
+	status := deliveryStatusPending
+	if int(delivery.Attempts) >= webhook.MaxAttempts {
+		status = deliveryStatusDead
+	}
+	cfg.metrics.webhookDeliveries.WithLabelValues(status).Inc()
+	return cfg.webhookEndpoints.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+		ID:             delivery.ID,
+		Status:         status,
+		NextAttemptAt:  time.Now().UTC().Add(webhook.RetryDelay(int(delivery.Attempts))),
+		LastStatusCode: statusCode,
+		LastError:      sql.NullString{String: sendErr.Error(), Valid: true},
+	})
+}
+
+// webhookOwnerHandler serves a webhook endpoint route on behalf of an owner: a user, or no one
+// for endpoints managed by admins.
+type webhookOwnerHandler func(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID)
+
+func (cfg *apiConfig) userWebhooks(next webhookOwnerHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := cfg.sessionUserID(r)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+			return
+		}
+		next(w, r, uuid.NullUUID{UUID: userID, Valid: true})
+	})
+}
+
+func (cfg *apiConfig) adminWebhooks(next webhookOwnerHandler) http.Handler {
+	return cfg.middlewareRole(auth.RoleAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next(w, r, uuid.NullUUID{})
+	}))
+}
+
+func (cfg *apiConfig) validateWebhookURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return errors.New("a valid absolute URL is required")
+	}
+	// Plain HTTP and local receivers are only allowed for local development.
+	if cfg.config.Platform == "dev" {
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return errors.New("webhook URLs must use http or https")
+		}
+		return nil
+	}
+	if u.Scheme != "https" {
+		return errors.New("webhook URLs must use https")
+	}
+	// This gives an early error; the sender's transport checks the address again when it
+	// connects, since the host may resolve differently by then.
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("couldn't resolve %s", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !webhook.IsPublicAddress(addr) {
+			return errors.New("webhook URLs must not point to internal addresses")
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerWebhookEndpointsCreate(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	type parameters struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	err = cfg.validateWebhookURL(r.Context(), params.URL)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if len(params.Events) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one event is required", nil)
+		return
+	}
+	for _, event := range params.Events {
+		if !slices.Contains(webhookEventTypes, event) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown event %q", event), nil)
+			return
+		}
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't generate a signing secret", err)
+		return
+	}
+	endpoint, err := cfg.webhookEndpoints.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID: owner,
+		Url:    params.URL,
+		Secret: secret,
+		Events: strings.Join(params.Events, " "),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create webhook endpoint", err)
+		return
+	}
+
+	// The signing secret is only ever returned here.
+	resp := webhookEndpointFromDB(endpoint)
+	resp.Secret = secret
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *apiConfig) handlerWebhookEndpointsList(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	var dbEndpoints []database.WebhookEndpoint
+	var err error
+	if owner.Valid {
+		dbEndpoints, err = cfg.webhookEndpoints.GetWebhookEndpointsByUser(r.Context(), owner)
+	} else {
+		dbEndpoints, err = cfg.webhookEndpoints.GetAdminWebhookEndpoints(r.Context())
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve webhook endpoints", err)
+		return
+	}
+	endpoints := []WebhookEndpoint{}
+	for _, endpoint := range dbEndpoints {
+		endpoints = append(endpoints, webhookEndpointFromDB(endpoint))
+	}
+	respondWithJSON(w, http.StatusOK, endpoints)
+}
+
+// ownedWebhookEndpoint loads the endpoint from the path. Endpoints of other owners are reported as
+// not found. It responds with an error and returns false otherwise.
+func (cfg *apiConfig) ownedWebhookEndpoint(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) (database.WebhookEndpoint, bool) {
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid endpoint ID", err)
+		return database.WebhookEndpoint{}, false
+	}
+	endpoint, err := cfg.webhookEndpoints.GetWebhookEndpoint(r.Context(), endpointID)
+	if err != nil || endpoint.UserID != owner {
+		respondWithError(w, http.StatusNotFound, "Webhook endpoint not found", err)
+		return database.WebhookEndpoint{}, false
+	}
+	return endpoint, true
+}
+
+func (cfg *apiConfig) handlerWebhookEndpointDelete(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	endpoint, ok := cfg.ownedWebhookEndpoint(w, r, owner)
+	if !ok {
+		return
+	}
+	err := cfg.webhookEndpoints.DeleteWebhookEndpoint(r.Context(), endpoint.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete webhook endpoint", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerWebhookDeliveriesList(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	endpoint, ok := cfg.ownedWebhookEndpoint(w, r, owner)
+	if !ok {
+		return
+	}
+	limit := 50
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		limit = min(n, 500)
+	}
+	dbDeliveries, err := cfg.webhookEndpoints.GetWebhookDeliveriesByEndpoint(r.Context(), database.GetWebhookDeliveriesByEndpointParams{
+		EndpointID: endpoint.ID,
+		Limit:      int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve webhook deliveries", err)
+		return
+	}
+	deliveries := []WebhookDelivery{}
+	for _, delivery := range dbDeliveries {
+		deliveries = append(deliveries, webhookDeliveryFromDB(delivery))
+	}
+	respondWithJSON(w, http.StatusOK, deliveries)
+}
+
+// handlerWebhookDeliveryRetry puts a dead-lettered delivery back in the queue.
+func (cfg *apiConfig) handlerWebhookDeliveryRetry(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	endpoint, ok := cfg.ownedWebhookEndpoint(w, r, owner)
+	if !ok {
+		return
+	}
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid delivery ID", err)
+		return
+	}
+	delivery, err := cfg.webhookEndpoints.GetWebhookDelivery(r.Context(), deliveryID)
+	if err != nil || delivery.EndpointID != endpoint.ID {
+		respondWithError(w, http.StatusNotFound, "Webhook delivery not found", err)
+		return
+	}
+	delivery, err = cfg.webhookEndpoints.RetryWebhookDelivery(r.Context(), delivery.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "Only dead-lettered deliveries can be retried", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retry webhook delivery", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, webhookDeliveryFromDB(delivery))
+}
@@ -1,16 +1,61 @@
 package main
 
 import (
-	"log"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/acramatte/Chirpy/internal/health"
+	"github.com/acramatte/Chirpy/internal/migrations"
 	"net/http"
 )
 
-func handlerReadiness(w http.ResponseWriter, r *http.Request) {
+// handlerLiveness only reports that the process is serving requests. It deliberately ignores
+// dependencies, so an outage of Postgres doesn't get every instance restarted.
+func handlerLiveness(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	_, err := w.Write([]byte("OK"))
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Println("Fail to write healthz response:", err)
+		loggerFromContext(r.Context()).Error("Couldn't write livez response", "err", err)
 	}
 }
+
+// handlerReadiness reports whether this instance should receive traffic, with the status of
+// each registered check.
+func (cfg *apiConfig) handlerReadiness(w http.ResponseWriter, r *http.Request) {
+	report := cfg.health.Run(r.Context())
+	code := http.StatusOK
+	if !report.OK() {
+		code = http.StatusServiceUnavailable
+	}
+	respondWithJSON(w, code, report)
+}
+
+// registerHealthChecks registers the checks for the dependencies every instance has.
+func (cfg *apiConfig) registerHealthChecks(db *sql.DB, expectedSchemaVersion int64) {
+	// Load balancers stop routing new requests to us once we report unhealthy while draining.
+	cfg.health.Register("shutdown", health.CheckerFunc(func(ctx context.Context) error {
+		if cfg.draining.Load() {
+			return errors.New("server is shutting down")
+		}
+		return nil
+	}))
+	// Without a database there is nothing to ping or migrate.
+	if db == nil {
+		return
+	}
+	cfg.health.Register("database", health.CheckerFunc(db.PingContext))
+	cfg.health.Register("migrations", health.CheckerFunc(func(ctx context.Context) error {
+		current, err := migrations.Current(ctx, db)
+		if err != nil {
+			return err
+		}
+		// A newer schema is fine: it is what a rolling deploy of the next version looks like.
+		if current < expectedSchemaVersion {
+			return fmt.Errorf("database schema is at version %d, expected %d", current, expectedSchemaVersion)
+		}
+		return nil
+	}))
+}
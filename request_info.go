@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// requestInfo identifies the request an action was taken in, for the audit log, metrics and traces.
+type requestInfo struct {
+	ID string
+	IP string
+	// route is filled in with the matched pattern once the request has been routed.
+	route *string
+	// user is filled in once the caller has been authenticated.
+	user *uuid.NullUUID
+}
+
+type requestInfoContextKey struct{}
+
+func requestInfoFromContext(ctx context.Context) requestInfo {
+	info, _ := ctx.Value(requestInfoContextKey{}).(requestInfo)
+	return info
+}
+
+// middlewareRequestInfo gives every request an ID and a logger carrying it, reusing the one set by a proxy in front of
+// us when it looks sane, and echoes it in the response so that clients can quote it.
+func (cfg *apiConfig) middlewareRequestInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, id)
+		info := requestInfo{ID: id, IP: cfg.clientIP(r), route: new(string), user: &uuid.NullUUID{}}
+		ctx := context.WithValue(r.Context(), requestInfoContextKey{}, info)
+		logger := slog.Default().With(slog.String("request_id", id))
+		if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+			logger = logger.With(slog.String("trace_id", span.TraceID().String()))
+		}
+		ctx = context.WithValue(ctx, loggerContextKey{}, logger)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Route returns the pattern the request matched without its method, such as
+// /api/chirps/{chirpID}, or "unmatched" when no route matched.
+func (info requestInfo) Route() string {
+	if info.route == nil || *info.route == "" {
+		return "unmatched"
+	}
+	_, path, ok := strings.Cut(*info.route, " ")
+	if !ok {
+		return *info.route
+	}
+	return path
+}
+
+// recordRoute remembers the pattern the wrapped mux matched. Nested muxes are wrapped too,
+// and the innermost one wins as it is the most specific.
+func recordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		route := requestInfoFromContext(r.Context()).route
+		if route != nil && *route == "" {
+			*route = r.Pattern
+		}
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
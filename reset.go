@@ -1,25 +1,26 @@
 package main
 
 import (
-	"log"
 	"net/http"
 )
 
 func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
-	if cfg.platform != "dev" {
+	if cfg.config.Platform != "dev" {
 		http.Error(w, "Reset is only allowed in dev environment.", http.StatusForbidden)
 		return
 	}
-	err := cfg.db.DeleteAll(r.Context())
+	err := cfg.users.DeleteAll(r.Context())
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't delete users", err)
 		return
 	}
 	cfg.fileserverHits.Store(0)
+	actorType, actorID := staffActor(r.Context())
+	cfg.audit(r.Context(), auditEntry{Action: auditReset, ActorType: actorType, ActorID: actorID})
 	w.WriteHeader(http.StatusOK)
 	_, err = w.Write([]byte("Hits reset to 0"))
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Println("Fail to reset:", err)
+		loggerFromContext(r.Context()).Error("Couldn't write reset response", "err", err)
 	}
 }
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"embed"
+	"github.com/acramatte/Chirpy/internal/migrations"
+	"io/fs"
+)
+
+//go:embed sql/schema/*.sql sql/sqlite/schema/*.sql sql/sqlite/queries/*.sql
+var embeddedSQL embed.FS
+
+// schemaMigrations are the goose migrations the binary was built with for the given dialect.
+func schemaMigrations(dialect migrations.Dialect) fs.FS {
+	dir := "sql/schema"
+	if dialect == migrations.SQLite {
+		dir = "sql/sqlite/schema"
+	}
+	return subSQL(dir)
+}
+
+// sqliteQueries are the SQLite versions of the queries in sql/queries.
+func sqliteQueries() fs.FS {
+	return subSQL("sql/sqlite/queries")
+}
+
+func subSQL(dir string) fs.FS {
+	sub, err := fs.Sub(embeddedSQL, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
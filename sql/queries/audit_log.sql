@@ -0,0 +1,39 @@
+-- name: CreateAuditEntry :exec
+INSERT INTO audit_log (created_at, action, actor_type, actor_id, target_type, target_id, ip, request_id, details)
+VALUES (
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+       );
+
+-- name: GetAuditEntries :many
+-- Entries are returned oldest first after the given ID so that callers can page through
+-- the whole log without missing entries that are appended meanwhile.
+SELECT * FROM audit_log
+WHERE id > sqlc.arg(after_id)
+AND (sqlc.narg(action)::text IS NULL OR action = sqlc.narg(action))
+AND (sqlc.narg(actor_id)::uuid IS NULL OR actor_id = sqlc.narg(actor_id))
+AND (sqlc.narg(target_id)::text IS NULL OR target_id = sqlc.narg(target_id))
+AND (sqlc.narg(since)::timestamp IS NULL OR created_at >= sqlc.narg(since))
+AND (sqlc.narg(until)::timestamp IS NULL OR created_at < sqlc.narg(until))
+ORDER BY id
+LIMIT sqlc.arg(max_entries);
+
+-- name: GetRecentAuditEntries :many
+-- Entries are returned newest first, before the given ID if there is one, for browsing the log
+-- a page at a time from its end.
+SELECT * FROM audit_log
+WHERE (sqlc.narg(before_id)::bigint IS NULL OR id < sqlc.narg(before_id))
+AND (sqlc.narg(action)::text IS NULL OR action = sqlc.narg(action))
+AND (sqlc.narg(actor_id)::uuid IS NULL OR actor_id = sqlc.narg(actor_id))
+AND (sqlc.narg(target_id)::text IS NULL OR target_id = sqlc.narg(target_id))
+AND (sqlc.narg(since)::timestamp IS NULL OR created_at >= sqlc.narg(since))
+AND (sqlc.narg(until)::timestamp IS NULL OR created_at < sqlc.narg(until))
+ORDER BY id DESC
+LIMIT sqlc.arg(max_entries);
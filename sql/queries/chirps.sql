@@ -10,29 +10,88 @@ VALUES (
 RETURNING *;
 
 -- name: GetChirps :many
-SELECT * FROM chirps
+-- Chirps of suspended authors are hidden from everyone, those of shadowbanned authors from everyone
+-- but the author.
+SELECT c.* FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE c.hidden_at IS NULL
+AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+AND (u.shadowbanned_at IS NULL OR c.user_id = sqlc.narg(viewer_id))
 ORDER BY
     CASE
-        WHEN $1 = 'desc' THEN created_at
+        WHEN sqlc.arg(sort_order)::text = 'desc' THEN c.created_at
         ELSE NULL -- Use NULL for invalid cases
         END DESC,
     CASE
-        WHEN $1 != 'desc' THEN created_at
+        WHEN sqlc.arg(sort_order)::text != 'desc' THEN c.created_at
         END ASC;
 
 -- name: GetChirpsByAuthorId :many
-SELECT * FROM chirps WHERE user_id = $1
+SELECT c.* FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE c.user_id = sqlc.arg(user_id)
+AND c.hidden_at IS NULL
+AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+AND (u.shadowbanned_at IS NULL OR c.user_id = sqlc.narg(viewer_id))
 ORDER BY
     CASE
-        WHEN $2 = 'desc' THEN created_at
+        WHEN sqlc.arg(sort_order)::text = 'desc' THEN c.created_at
         ELSE NULL -- Use NULL for invalid cases
         END DESC,
     CASE
-        WHEN $2 != 'desc' THEN created_at
+        WHEN sqlc.arg(sort_order)::text != 'desc' THEN c.created_at
         END ASC;
 
+-- name: GetVisibleChirp :one
+SELECT c.* FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE c.id = sqlc.arg(id)
+AND c.hidden_at IS NULL
+AND (u.suspended_at IS NULL OR u.suspended_until <= NOW())
+AND (u.shadowbanned_at IS NULL OR c.user_id = sqlc.narg(viewer_id));
+
 -- name: GetChirp :one
 SELECT * FROM chirps WHERE id = $1;
 
 -- name: DeleteChirp :exec
 DELETE FROM chirps where id = $1;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: PinChirp :one
+UPDATE chirps SET pinned_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UnpinChirp :one
+UPDATE chirps SET pinned_at = NULL
+WHERE id = $1
+RETURNING *;
+
+-- name: CountPinnedChirpsByUser :one
+SELECT COUNT(*) FROM chirps WHERE user_id = $1 AND pinned_at IS NOT NULL;
+
+-- name: CountChirpsByUserSince :one
+SELECT COUNT(*) FROM chirps WHERE user_id = $1 AND created_at > $2;
+
+-- name: FlagChirp :one
+UPDATE chirps SET flagged_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetFlaggedChirps :many
+SELECT * FROM chirps
+WHERE flagged_at IS NOT NULL
+ORDER BY flagged_at;
+
+-- name: HideChirp :one
+UPDATE chirps SET hidden_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteChirpsByUser :many
+DELETE FROM chirps WHERE user_id = $1
+RETURNING *;
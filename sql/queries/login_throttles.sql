@@ -0,0 +1,19 @@
+-- name: GetLoginThrottle :one
+SELECT * FROM login_throttles WHERE key = $1;
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_throttles (key, failures, last_failure_at, locked_until)
+VALUES ($1, 1, NOW(), NULL)
+ON CONFLICT (key) DO UPDATE SET
+    failures = CASE
+        WHEN login_throttles.last_failure_at < NOW() - INTERVAL '1 day' THEN 1
+        ELSE login_throttles.failures + 1
+        END,
+    last_failure_at = NOW()
+RETURNING *;
+
+-- name: LockLoginThrottle :exec
+UPDATE login_throttles SET locked_until = $2 WHERE key = $1;
+
+-- name: ClearLoginThrottle :execrows
+DELETE FROM login_throttles WHERE key = $1;
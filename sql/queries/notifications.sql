@@ -0,0 +1,19 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (id, created_at, user_id, message)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2
+       )
+RETURNING *;
+
+-- name: GetNotificationsByUser :many
+SELECT * FROM notifications
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications SET read_at = NOW()
+WHERE id = $1 AND user_id = $2 AND read_at IS NULL;
@@ -15,8 +15,46 @@ SELECT * FROM refresh_tokens WHERE token = $1;
 SELECT u.* FROM users u
 JOIN refresh_tokens rt ON u.id = rt.user_id
 WHERE rt.token = $1
+AND rt.client_id IS NULL
 AND revoked_at IS NULL
 AND expires_at > NOW();
 
 -- name: RevokeToken :exec
 UPDATE refresh_tokens SET updated_at = NOW(), revoked_at = NOW() WHERE token = $1;
+
+-- name: CreateOAuthRefreshToken :one
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, client_id, scopes)
+values ($1,
+        NOW(),
+        NOW(),
+        $2,
+        $3,
+        $4,
+        $5
+       )
+RETURNING *;
+
+-- name: GetActiveOAuthRefreshToken :one
+SELECT * FROM refresh_tokens
+WHERE token = $1
+AND client_id = $2
+AND revoked_at IS NULL
+AND expires_at > NOW();
+
+-- name: ConsumeOAuthRefreshToken :one
+-- Revoking the token in the same statement that checks it means a token can only be rotated once,
+-- even by concurrent requests.
+UPDATE refresh_tokens SET updated_at = NOW(), revoked_at = NOW()
+WHERE token = $1
+AND client_id = $2
+AND revoked_at IS NULL
+AND expires_at > NOW()
+RETURNING *;
+
+-- name: RevokeOAuthRefreshToken :exec
+UPDATE refresh_tokens SET updated_at = NOW(), revoked_at = NOW()
+WHERE token = $1 AND client_id = $2;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens SET updated_at = NOW(), revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
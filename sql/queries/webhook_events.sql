@@ -0,0 +1,44 @@
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, provider, event_id, event_type, payload, status, attempts, received_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    'received',
+    0,
+    NOW()
+       )
+ON CONFLICT (provider, event_id) DO NOTHING
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events WHERE id = $1;
+
+-- name: GetWebhookEventByProviderID :one
+SELECT * FROM webhook_events WHERE provider = $1 AND event_id = $2;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (sqlc.arg(status)::text = '' OR status = sqlc.arg(status)::text)
+ORDER BY received_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: ClaimWebhookEvent :one
+-- Events claimed before stale_before are claimed again, since whoever claimed them gave up.
+UPDATE webhook_events SET status = 'processing', attempts = attempts + 1, claimed_at = NOW()
+WHERE id = sqlc.arg(id)
+AND (status IN ('received', 'failed') OR (status = 'processing' AND claimed_at < sqlc.arg(stale_before)::timestamp))
+RETURNING *;
+
+-- name: ClaimWebhookEventForReplay :one
+UPDATE webhook_events SET status = 'processing', attempts = attempts + 1, claimed_at = NOW()
+WHERE id = sqlc.arg(id)
+AND (status <> 'processing' OR claimed_at < sqlc.arg(stale_before)::timestamp)
+RETURNING *;
+
+-- name: FinishWebhookEvent :one
+UPDATE webhook_events SET status = $2, error = $3, processed_at = NOW()
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE personal_access_tokens(
+   id UUID PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   name TEXT NOT NULL,
+   token_hash TEXT NOT NULL UNIQUE,
+   scopes TEXT NOT NULL,
+   expires_at TIMESTAMP,
+   last_used_at TIMESTAMP,
+   revoked_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE personal_access_tokens;
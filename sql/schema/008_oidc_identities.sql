@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE users ALTER COLUMN hashed_password DROP NOT NULL;
+ALTER TABLE users ALTER COLUMN hashed_password DROP DEFAULT;
+UPDATE users SET hashed_password = NULL WHERE hashed_password = 'unset';
+
+CREATE TABLE user_identities(
+   issuer TEXT NOT NULL,
+   subject TEXT NOT NULL,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   PRIMARY KEY (issuer, subject)
+);
+
+-- +goose Down
+DROP TABLE user_identities;
+UPDATE users SET hashed_password = 'unset' WHERE hashed_password IS NULL;
+ALTER TABLE users ALTER COLUMN hashed_password SET DEFAULT 'unset';
+ALTER TABLE users ALTER COLUMN hashed_password SET NOT NULL;
@@ -0,0 +1,8 @@
+-- +goose Up
+CREATE TABLE webhook_signatures(
+   signature TEXT PRIMARY KEY,
+   received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE webhook_signatures;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE webhook_events(
+   id UUID PRIMARY KEY,
+   provider TEXT NOT NULL,
+   event_id TEXT NOT NULL,
+   event_type TEXT NOT NULL,
+   payload TEXT NOT NULL,
+   status TEXT NOT NULL,
+   error TEXT,
+   attempts INTEGER NOT NULL DEFAULT 0,
+   received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   processed_at TIMESTAMP,
+   UNIQUE (provider, event_id)
+);
+
+CREATE INDEX webhook_events_status_idx ON webhook_events (status, received_at);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE subscriptions(
+   id UUID PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   plan TEXT NOT NULL,
+   status TEXT NOT NULL,
+   current_period_start TIMESTAMP NOT NULL,
+   current_period_end TIMESTAMP NOT NULL,
+   grace_period_end TIMESTAMP NOT NULL,
+   canceled_at TIMESTAMP
+);
+
+CREATE INDEX subscriptions_user_idx ON subscriptions (user_id, current_period_end);
+
+-- Existing Chirpy Red members get a fresh monthly period, since their real billing period is unknown.
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end, grace_period_end)
+SELECT gen_random_uuid(), NOW(), NOW(), id, 'chirpy_red_monthly', 'active', NOW(), NOW() + INTERVAL '1 month', NOW() + INTERVAL '1 month 7 days'
+FROM users WHERE is_chirpy_red;
+
+ALTER TABLE users DROP COLUMN is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users ADD COLUMN is_chirpy_red BOOL NOT NULL default false;
+UPDATE users SET is_chirpy_red = true
+WHERE id IN (SELECT user_id FROM subscriptions WHERE status IN ('active', 'past_due') AND grace_period_end > NOW());
+DROP TABLE subscriptions;
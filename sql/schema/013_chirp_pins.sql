@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN pinned_at TIMESTAMP;
+
+CREATE INDEX chirps_user_created_idx ON chirps (user_id, created_at);
+
+-- +goose Down
+DROP INDEX chirps_user_created_idx;
+ALTER TABLE chirps DROP COLUMN pinned_at;
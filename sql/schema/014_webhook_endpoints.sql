@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE webhook_endpoints(
+   id UUID PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+   url TEXT NOT NULL,
+   secret TEXT NOT NULL,
+   events TEXT NOT NULL
+);
+
+CREATE TABLE webhook_deliveries(
+   id UUID PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   endpoint_id UUID NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+   event_id UUID NOT NULL,
+   event_type TEXT NOT NULL,
+   payload TEXT NOT NULL,
+   status TEXT NOT NULL,
+   attempts INTEGER NOT NULL DEFAULT 0,
+   next_attempt_at TIMESTAMP NOT NULL,
+   last_status_code INTEGER,
+   last_error TEXT,
+   delivered_at TIMESTAMP
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX webhook_deliveries_endpoint_idx ON webhook_deliveries (endpoint_id, created_at);
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_endpoints;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE moderation_words(
+   id UUID PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   word TEXT NOT NULL UNIQUE,
+   severity TEXT NOT NULL
+);
+
+-- The words that used to be hard-coded in the chirp handler.
+INSERT INTO moderation_words (id, word, severity) VALUES
+    (gen_random_uuid(), 'kerfuffle', 'mask'),
+    (gen_random_uuid(), 'sharbert', 'mask'),
+    (gen_random_uuid(), 'fornax', 'mask');
+
+ALTER TABLE chirps ADD COLUMN flagged_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE chirps DROP COLUMN flagged_at;
+DROP TABLE moderation_words;
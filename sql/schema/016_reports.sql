@@ -0,0 +1,46 @@
+-- +goose Up
+CREATE TABLE reports(
+   id UUID PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   reporter_id UUID REFERENCES users(id) ON DELETE SET NULL,
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+   reason TEXT NOT NULL,
+   details TEXT NOT NULL DEFAULT '',
+   status TEXT NOT NULL,
+   resolved_at TIMESTAMP
+);
+
+CREATE INDEX reports_status_idx ON reports (status, created_at);
+
+CREATE TABLE moderation_actions(
+   id UUID PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   report_id UUID REFERENCES reports(id) ON DELETE SET NULL,
+   moderator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+   action TEXT NOT NULL,
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   chirp_id UUID,
+   note TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE notifications(
+   id UUID PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   message TEXT NOT NULL,
+   read_at TIMESTAMP
+);
+
+CREATE INDEX notifications_user_idx ON notifications (user_id, created_at);
+
+ALTER TABLE chirps ADD COLUMN hidden_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN suspended_until TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN suspended_until;
+ALTER TABLE chirps DROP COLUMN hidden_at;
+DROP TABLE notifications;
+DROP TABLE moderation_actions;
+DROP TABLE reports;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- A suspension starts at suspended_at and ends at suspended_until, or never when that is NULL.
+ALTER TABLE users ADD COLUMN suspended_at TIMESTAMP;
+UPDATE users SET suspended_at = updated_at WHERE suspended_until IS NOT NULL;
+ALTER TABLE users ADD COLUMN shadowbanned_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN shadowbanned_at;
+UPDATE users SET suspended_until = NULL WHERE suspended_at IS NULL;
+ALTER TABLE users DROP COLUMN suspended_at;
@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE audit_log(
+   id BIGSERIAL PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   action TEXT NOT NULL,
+   actor_type TEXT NOT NULL,
+   actor_id UUID,
+   target_type TEXT NOT NULL DEFAULT '',
+   target_id TEXT NOT NULL DEFAULT '',
+   ip TEXT NOT NULL DEFAULT '',
+   request_id TEXT NOT NULL DEFAULT '',
+   details TEXT NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
+CREATE INDEX audit_log_actor_idx ON audit_log (actor_id, id);
+CREATE INDEX audit_log_target_idx ON audit_log (target_id, id);
+
+-- The log is append-only: entries can't be changed or removed, not even by the application.
+-- +goose StatementBegin
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+   RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE OR DELETE ON audit_log
+   FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+   FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+-- +goose Down
+DROP TABLE audit_log;
+DROP FUNCTION audit_log_append_only;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- A report keeps pointing at the reported chirp after the chirp is deleted, and keeps the body
+-- that was reported, instead of turning into a report of the chirp's author.
+ALTER TABLE reports DROP CONSTRAINT reports_chirp_id_fkey;
+ALTER TABLE reports ADD COLUMN chirp_body TEXT NOT NULL DEFAULT '';
+UPDATE reports SET chirp_body = chirps.body FROM chirps WHERE chirps.id = reports.chirp_id;
+
+-- +goose Down
+ALTER TABLE reports DROP COLUMN chirp_body;
+UPDATE reports SET chirp_id = NULL
+WHERE chirp_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM chirps WHERE chirps.id = reports.chirp_id);
+ALTER TABLE reports ADD CONSTRAINT reports_chirp_id_fkey FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE SET NULL;
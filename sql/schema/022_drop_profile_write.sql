@@ -0,0 +1,7 @@
+-- +goose Up
+-- profile:write never granted anything and is no longer a scope. OAuth clients registered with
+-- it would otherwise fail to authorize with their default scopes.
+UPDATE oauth_clients SET scopes = TRIM(REPLACE(' ' || scopes || ' ', ' profile:write ', ' '));
+
+-- +goose Down
+-- The scope granted nothing, so there is nothing to restore.
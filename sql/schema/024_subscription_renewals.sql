@@ -0,0 +1,11 @@
+-- +goose Up
+-- Records which webhook event renewed a subscription, so a redelivered or replayed renewal
+-- doesn't extend the subscription a second time.
+CREATE TABLE subscription_renewals(
+   webhook_event_id UUID PRIMARY KEY REFERENCES webhook_events(id) ON DELETE CASCADE,
+   subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE subscription_renewals;
@@ -0,0 +1,39 @@
+-- name: CreateAuditEntry :exec
+INSERT INTO audit_log (created_at, action, actor_type, actor_id, target_type, target_id, ip, request_id, details)
+VALUES (
+    NOW(),
+    ?1,
+    ?2,
+    ?3,
+    ?4,
+    ?5,
+    ?6,
+    ?7,
+    ?8
+       );
+
+-- name: GetAuditEntries :many
+-- Entries are returned oldest first after the given ID so that callers can page through
+-- the whole log without missing entries that are appended meanwhile.
+SELECT * FROM audit_log
+WHERE id > ?1
+AND (?2 IS NULL OR action = ?2)
+AND (?3 IS NULL OR actor_id = ?3)
+AND (?4 IS NULL OR target_id = ?4)
+AND (?5 IS NULL OR created_at >= ?5)
+AND (?6 IS NULL OR created_at < ?6)
+ORDER BY id
+LIMIT ?7;
+
+-- name: GetRecentAuditEntries :many
+-- Entries are returned newest first, before the given ID if there is one, for browsing the log
+-- a page at a time from its end.
+SELECT * FROM audit_log
+WHERE (?1 IS NULL OR id < ?1)
+AND (?2 IS NULL OR action = ?2)
+AND (?3 IS NULL OR actor_id = ?3)
+AND (?4 IS NULL OR target_id = ?4)
+AND (?5 IS NULL OR created_at >= ?5)
+AND (?6 IS NULL OR created_at < ?6)
+ORDER BY id DESC
+LIMIT ?7;
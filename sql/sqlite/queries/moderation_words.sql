@@ -0,0 +1,21 @@
+-- name: CreateModerationWord :one
+INSERT INTO moderation_words (id, created_at, updated_at, word, severity)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    ?1,
+    ?2
+       )
+RETURNING *;
+
+-- name: GetModerationWords :many
+SELECT * FROM moderation_words ORDER BY word;
+
+-- name: UpdateModerationWordSeverity :one
+UPDATE moderation_words SET updated_at = NOW(), severity = ?2
+WHERE id = ?1
+RETURNING *;
+
+-- name: DeleteModerationWord :execrows
+DELETE FROM moderation_words WHERE id = ?1;
@@ -0,0 +1,44 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, updated_at, owner_id, name, secret_hash, redirect_uris, scopes)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    ?1,
+    ?2,
+    ?3,
+    ?4,
+    ?5
+       )
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients WHERE id = ?1;
+
+-- name: GetOAuthClientsByOwner :many
+SELECT * FROM oauth_clients WHERE owner_id = ?1
+ORDER BY created_at DESC;
+
+-- name: DeleteOAuthClient :execrows
+DELETE FROM oauth_clients WHERE id = ?1 AND owner_id = ?2;
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, created_at, client_id, user_id, redirect_uri, scopes, code_challenge, code_challenge_method, expires_at)
+VALUES (
+    ?1,
+    NOW(),
+    ?2,
+    ?3,
+    ?4,
+    ?5,
+    ?6,
+    ?7,
+    ?8
+       );
+
+-- name: ConsumeOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes SET used_at = NOW()
+WHERE code_hash = ?1
+AND used_at IS NULL
+AND expires_at > NOW()
+RETURNING *;
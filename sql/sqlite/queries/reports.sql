@@ -0,0 +1,61 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, reporter_id, user_id, chirp_id, chirp_body, reason, details, status)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    ?1,
+    ?2,
+    ?3,
+    ?4,
+    ?5,
+    ?6,
+    'open'
+       )
+RETURNING *;
+
+-- name: GetReport :one
+SELECT * FROM reports WHERE id = ?1;
+
+-- name: GetReportsByStatus :many
+SELECT * FROM reports
+WHERE status = ?1
+ORDER BY created_at
+LIMIT ?2;
+
+-- name: GetOpenReportsForChirp :many
+SELECT * FROM reports
+WHERE chirp_id = ?1 AND status = 'open';
+
+-- name: GetOpenReportsForUser :many
+SELECT * FROM reports
+WHERE user_id = ?1 AND chirp_id IS NULL AND status = 'open';
+
+-- name: ResolveReport :exec
+UPDATE reports SET updated_at = NOW(), status = ?2, resolved_at = NOW()
+WHERE id = ?1;
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, created_at, report_id, moderator_id, action, user_id, chirp_id, note, moderator_type)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    ?1,
+    ?2,
+    ?3,
+    ?4,
+    ?5,
+    ?6,
+    ?7
+       )
+RETURNING *;
+
+-- name: GetModerationActionsByUser :many
+SELECT * FROM moderation_actions
+WHERE user_id = ?1
+ORDER BY created_at DESC;
+
+-- name: GetModerationActionsByReport :many
+SELECT * FROM moderation_actions
+WHERE report_id = ?1
+ORDER BY created_at;
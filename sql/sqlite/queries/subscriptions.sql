@@ -0,0 +1,51 @@
+-- name: CreateSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end, grace_period_end)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    ?1,
+    ?2,
+    'active',
+    ?3,
+    ?4,
+    ?5
+       )
+RETURNING *;
+
+-- name: GetActiveSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = ?1
+AND status IN ('active', 'past_due')
+AND grace_period_end > NOW()
+ORDER BY current_period_end DESC
+LIMIT 1;
+
+-- name: GetSubscriptionsByUser :many
+SELECT * FROM subscriptions
+WHERE user_id = ?1
+ORDER BY created_at DESC;
+
+-- name: RenewSubscription :one
+UPDATE subscriptions SET status = 'active', plan = ?2, current_period_start = ?3, current_period_end = ?4, grace_period_end = ?5, updated_at = NOW()
+WHERE id = ?1
+RETURNING *;
+
+-- name: MarkSubscriptionPastDue :one
+UPDATE subscriptions SET status = 'past_due', grace_period_end = ?2, updated_at = NOW()
+WHERE id = ?1
+RETURNING *;
+
+-- name: CancelSubscription :one
+UPDATE subscriptions SET status = 'canceled', canceled_at = NOW(), updated_at = NOW()
+WHERE id = ?1
+RETURNING *;
+
+-- name: CreateSubscriptionRenewal :one
+INSERT INTO subscription_renewals (webhook_event_id, subscription_id, created_at)
+VALUES (?1, ?2, NOW())
+ON CONFLICT (webhook_event_id) DO NOTHING
+RETURNING *;
+
+-- name: DeleteSubscriptionRenewal :exec
+DELETE FROM subscription_renewals WHERE webhook_event_id = ?1;
@@ -0,0 +1,15 @@
+-- name: GetUserByIdentity :one
+SELECT u.* FROM users u
+JOIN user_identities ui ON u.id = ui.user_id
+WHERE ui.issuer = ?1
+AND ui.subject = ?2;
+
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (issuer, subject, created_at, user_id)
+VALUES (
+    ?1,
+    ?2,
+    NOW(),
+    ?3
+       )
+RETURNING *;
@@ -0,0 +1,71 @@
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+VALUES (
+           gen_random_uuid(),
+            NOW(),
+            NOW(),
+            ?1,
+            ?2
+       )
+RETURNING *;
+
+-- name: CreateUserWithRole :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, role)
+VALUES (
+           gen_random_uuid(),
+            NOW(),
+            NOW(),
+            ?1,
+            ?2,
+            ?3
+       )
+RETURNING *;
+
+-- name: DeleteAll :exec
+DELETE FROM users;
+
+-- name: GetUserByEmail :one
+SELECT * from users WHERE email = ?1;
+
+-- name: UpdateEmailAndPassword :one
+UPDATE users SET email = ?2, hashed_password = ?3, updated_at = NOW()
+where id =?1
+RETURNING *;
+
+-- name: GetUser :one
+SELECT * FROM users WHERE id = ?1;
+
+-- name: UpdatePasswordHash :exec
+UPDATE users SET hashed_password = ?2
+WHERE id = ?1;
+
+-- name: SuspendUser :one
+UPDATE users SET updated_at = NOW(), suspended_at = NOW(), suspended_until = ?2
+WHERE id = ?1
+RETURNING *;
+
+-- name: UnsuspendUser :one
+UPDATE users SET updated_at = NOW(), suspended_at = NULL, suspended_until = NULL
+WHERE id = ?1
+RETURNING *;
+
+-- name: ShadowbanUser :one
+UPDATE users SET updated_at = NOW(), shadowbanned_at = NOW()
+WHERE id = ?1
+RETURNING *;
+
+-- name: UnshadowbanUser :one
+UPDATE users SET updated_at = NOW(), shadowbanned_at = NULL
+WHERE id = ?1
+RETURNING *;
+
+-- name: UpdateUserRole :one
+-- The last admin can't be demoted: no row is returned. SQLite runs one write at a time, so the
+-- count can't change before the update.
+UPDATE users SET updated_at = NOW(), role = ?2
+WHERE id = ?1
+AND (role <> 'admin' OR ?2 = 'admin' OR (SELECT COUNT(*) FROM users WHERE role = 'admin') > 1)
+RETURNING *;
+
+-- name: CountUsersByRole :one
+SELECT COUNT(*) FROM users WHERE role = ?1;
@@ -0,0 +1,83 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, url, secret, events)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    ?1,
+    ?2,
+    ?3,
+    ?4
+       )
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints WHERE id = ?1;
+
+-- name: GetWebhookEndpointsByUser :many
+SELECT * FROM webhook_endpoints
+WHERE user_id = ?1
+ORDER BY created_at DESC;
+
+-- name: GetAdminWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE user_id IS NULL
+ORDER BY created_at DESC;
+
+-- name: GetWebhookEndpointsForOwner :many
+SELECT * FROM webhook_endpoints
+WHERE user_id IS NULL OR user_id = ?1;
+
+-- name: DeleteWebhookEndpoint :exec
+DELETE FROM webhook_endpoints WHERE id = ?1;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event_id, event_type, payload, status, next_attempt_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    ?1,
+    ?2,
+    ?3,
+    ?4,
+    'pending',
+    NOW()
+       )
+RETURNING *;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries WHERE id = ?1;
+
+-- name: GetWebhookDeliveriesByEndpoint :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = ?1
+ORDER BY created_at DESC
+LIMIT ?2;
+
+-- name: ClaimDueWebhookDeliveries :many
+-- Pushing next_attempt_at forward leases the deliveries, so concurrent workers skip them and a
+-- crashed worker's deliveries are picked up again once the lease runs out. SQLite runs one write
+-- at a time, so the claim needs no row locks.
+UPDATE webhook_deliveries SET updated_at = NOW(), attempts = attempts + 1, next_attempt_at = ?1
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT ?2
+)
+RETURNING *;
+
+-- name: MarkWebhookDeliveryDelivered :exec
+UPDATE webhook_deliveries SET updated_at = NOW(), status = 'delivered', delivered_at = NOW(), last_status_code = ?2, last_error = NULL
+WHERE id = ?1;
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries SET updated_at = NOW(), status = ?2, next_attempt_at = ?3, last_status_code = ?4, last_error = ?5
+WHERE id = ?1;
+
+-- name: RetryWebhookDelivery :one
+-- A retried delivery starts over with the full number of attempts and the shortest backoff.
+UPDATE webhook_deliveries SET updated_at = NOW(), status = 'pending', attempts = 0, next_attempt_at = NOW()
+WHERE id = ?1 AND status = 'dead'
+RETURNING *;
@@ -0,0 +1,7 @@
+-- name: RecordWebhookSignature :execrows
+INSERT INTO webhook_signatures (signature, received_at)
+VALUES (?1, NOW())
+ON CONFLICT (signature) DO NOTHING;
+
+-- name: DeleteWebhookSignaturesBefore :exec
+DELETE FROM webhook_signatures WHERE received_at < ?1;
@@ -0,0 +1,261 @@
+-- +goose Up
+-- SQLite databases start from the schema of Postgres migration 019 rather than replaying the
+-- Postgres history, much of which SQLite's ALTER TABLE can't express. Later migrations are added
+-- to both sql/schema and sql/sqlite/schema under the same version.
+--
+-- UUIDs are stored as text, and times as fixed-width UTC text so that they sort chronologically.
+CREATE TABLE users(
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    email TEXT NOT NULL UNIQUE,
+    hashed_password TEXT,
+    suspended_until TIMESTAMP,
+    role TEXT NOT NULL DEFAULT 'user',
+    -- A suspension starts at suspended_at and ends at suspended_until, or never when that is NULL.
+    suspended_at TIMESTAMP,
+    shadowbanned_at TIMESTAMP
+);
+
+CREATE TABLE chirps(
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    body TEXT NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    pinned_at TIMESTAMP,
+    flagged_at TIMESTAMP,
+    hidden_at TIMESTAMP
+);
+
+CREATE INDEX chirps_user_created_idx ON chirps (user_id, created_at);
+
+CREATE TABLE oauth_clients(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   owner_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   name TEXT NOT NULL,
+   secret_hash TEXT,
+   redirect_uris TEXT NOT NULL,
+   scopes TEXT NOT NULL
+);
+
+CREATE TABLE oauth_authorization_codes(
+   code_hash TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   client_id TEXT NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   redirect_uri TEXT NOT NULL,
+   scopes TEXT NOT NULL,
+   code_challenge TEXT NOT NULL,
+   code_challenge_method TEXT NOT NULL,
+   expires_at TIMESTAMP NOT NULL,
+   used_at TIMESTAMP
+);
+
+CREATE TABLE refresh_tokens(
+   token TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   expires_at TIMESTAMP NOT NULL DEFAULT (datetime('now', '+60 days')),
+   revoked_at TIMESTAMP,
+   client_id TEXT REFERENCES oauth_clients(id) ON DELETE CASCADE,
+   scopes TEXT
+);
+
+CREATE TABLE personal_access_tokens(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   name TEXT NOT NULL,
+   token_hash TEXT NOT NULL UNIQUE,
+   scopes TEXT NOT NULL,
+   expires_at TIMESTAMP,
+   last_used_at TIMESTAMP,
+   revoked_at TIMESTAMP
+);
+
+CREATE TABLE user_identities(
+   issuer TEXT NOT NULL,
+   subject TEXT NOT NULL,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   PRIMARY KEY (issuer, subject)
+);
+
+CREATE TABLE login_throttles(
+   key TEXT PRIMARY KEY,
+   failures INTEGER NOT NULL,
+   last_failure_at TIMESTAMP NOT NULL,
+   locked_until TIMESTAMP
+);
+
+CREATE TABLE webhook_signatures(
+   signature TEXT PRIMARY KEY,
+   received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE webhook_events(
+   id TEXT PRIMARY KEY,
+   provider TEXT NOT NULL,
+   event_id TEXT NOT NULL,
+   event_type TEXT NOT NULL,
+   payload TEXT NOT NULL,
+   status TEXT NOT NULL,
+   error TEXT,
+   attempts INTEGER NOT NULL DEFAULT 0,
+   received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   processed_at TIMESTAMP,
+   UNIQUE (provider, event_id)
+);
+
+CREATE INDEX webhook_events_status_idx ON webhook_events (status, received_at);
+
+CREATE TABLE subscriptions(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   plan TEXT NOT NULL,
+   status TEXT NOT NULL,
+   current_period_start TIMESTAMP NOT NULL,
+   current_period_end TIMESTAMP NOT NULL,
+   grace_period_end TIMESTAMP NOT NULL,
+   canceled_at TIMESTAMP
+);
+
+CREATE INDEX subscriptions_user_idx ON subscriptions (user_id, current_period_end);
+
+CREATE TABLE webhook_endpoints(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+   url TEXT NOT NULL,
+   secret TEXT NOT NULL,
+   events TEXT NOT NULL
+);
+
+CREATE TABLE webhook_deliveries(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   endpoint_id TEXT NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+   event_id TEXT NOT NULL,
+   event_type TEXT NOT NULL,
+   payload TEXT NOT NULL,
+   status TEXT NOT NULL,
+   attempts INTEGER NOT NULL DEFAULT 0,
+   next_attempt_at TIMESTAMP NOT NULL,
+   last_status_code INTEGER,
+   last_error TEXT,
+   delivered_at TIMESTAMP
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX webhook_deliveries_endpoint_idx ON webhook_deliveries (endpoint_id, created_at);
+
+CREATE TABLE moderation_words(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   word TEXT NOT NULL UNIQUE,
+   severity TEXT NOT NULL
+);
+
+-- The words that used to be hard-coded in the chirp handler.
+INSERT INTO moderation_words (id, word, severity) VALUES
+    (gen_random_uuid(), 'kerfuffle', 'mask'),
+    (gen_random_uuid(), 'sharbert', 'mask'),
+    (gen_random_uuid(), 'fornax', 'mask');
+
+CREATE TABLE reports(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   reporter_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   chirp_id TEXT REFERENCES chirps(id) ON DELETE SET NULL,
+   reason TEXT NOT NULL,
+   details TEXT NOT NULL DEFAULT '',
+   status TEXT NOT NULL,
+   resolved_at TIMESTAMP
+);
+
+CREATE INDEX reports_status_idx ON reports (status, created_at);
+
+CREATE TABLE moderation_actions(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   report_id TEXT REFERENCES reports(id) ON DELETE SET NULL,
+   moderator_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+   action TEXT NOT NULL,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   chirp_id TEXT,
+   note TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE notifications(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   message TEXT NOT NULL,
+   read_at TIMESTAMP
+);
+
+CREATE INDEX notifications_user_idx ON notifications (user_id, created_at);
+
+CREATE TABLE audit_log(
+   id INTEGER PRIMARY KEY AUTOINCREMENT,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   action TEXT NOT NULL,
+   actor_type TEXT NOT NULL,
+   actor_id TEXT,
+   target_type TEXT NOT NULL DEFAULT '',
+   target_id TEXT NOT NULL DEFAULT '',
+   ip TEXT NOT NULL DEFAULT '',
+   request_id TEXT NOT NULL DEFAULT '',
+   details TEXT NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
+CREATE INDEX audit_log_actor_idx ON audit_log (actor_id, id);
+CREATE INDEX audit_log_target_idx ON audit_log (target_id, id);
+
+-- The log is append-only: entries can't be changed or removed, not even by the application.
+-- +goose StatementBegin
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+   SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+   SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE audit_log;
+DROP TABLE notifications;
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+DROP TABLE moderation_words;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_endpoints;
+DROP TABLE subscriptions;
+DROP TABLE webhook_events;
+DROP TABLE webhook_signatures;
+DROP TABLE login_throttles;
+DROP TABLE user_identities;
+DROP TABLE personal_access_tokens;
+DROP TABLE refresh_tokens;
+DROP TABLE oauth_authorization_codes;
+DROP TABLE oauth_clients;
+DROP TABLE chirps;
+DROP TABLE users;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- A claim on an event expires, so an event whose processing was interrupted, for example by a
+-- crash, can be claimed again instead of staying 'processing' forever.
+ALTER TABLE webhook_events ADD COLUMN claimed_at TIMESTAMP;
+UPDATE webhook_events SET claimed_at = received_at WHERE status = 'processing';
+
+-- +goose Down
+ALTER TABLE webhook_events DROP COLUMN claimed_at;
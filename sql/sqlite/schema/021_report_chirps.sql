@@ -0,0 +1,56 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+-- A report keeps pointing at the reported chirp after the chirp is deleted, and keeps the body
+-- that was reported, instead of turning into a report of the chirp's author. SQLite can't drop
+-- a foreign key, so the table is rebuilt. Foreign keys are off while it is, since dropping the
+-- old table would otherwise unlink its moderation actions, and they can only be switched off
+-- outside a transaction.
+PRAGMA foreign_keys = OFF;
+BEGIN;
+CREATE TABLE reports_new(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   reporter_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   chirp_id TEXT,
+   reason TEXT NOT NULL,
+   details TEXT NOT NULL DEFAULT '',
+   status TEXT NOT NULL,
+   resolved_at TIMESTAMP,
+   chirp_body TEXT NOT NULL DEFAULT ''
+);
+INSERT INTO reports_new (id, created_at, updated_at, reporter_id, user_id, chirp_id, reason, details, status, resolved_at, chirp_body)
+SELECT id, created_at, updated_at, reporter_id, user_id, chirp_id, reason, details, status, resolved_at,
+       COALESCE((SELECT body FROM chirps WHERE chirps.id = reports.chirp_id), '')
+FROM reports;
+DROP TABLE reports;
+ALTER TABLE reports_new RENAME TO reports;
+CREATE INDEX reports_status_idx ON reports (status, created_at);
+COMMIT;
+PRAGMA foreign_keys = ON;
+
+-- +goose Down
+PRAGMA foreign_keys = OFF;
+BEGIN;
+CREATE TABLE reports_old(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   reporter_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   chirp_id TEXT REFERENCES chirps(id) ON DELETE SET NULL,
+   reason TEXT NOT NULL,
+   details TEXT NOT NULL DEFAULT '',
+   status TEXT NOT NULL,
+   resolved_at TIMESTAMP
+);
+INSERT INTO reports_old (id, created_at, updated_at, reporter_id, user_id, chirp_id, reason, details, status, resolved_at)
+SELECT id, created_at, updated_at, reporter_id, user_id,
+       (SELECT chirps.id FROM chirps WHERE chirps.id = reports.chirp_id), reason, details, status, resolved_at
+FROM reports;
+DROP TABLE reports;
+ALTER TABLE reports_old RENAME TO reports;
+CREATE INDEX reports_status_idx ON reports (status, created_at);
+COMMIT;
+PRAGMA foreign_keys = ON;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Records who took a moderation action the same way the audit log does, so actions taken with
+-- the admin API key and from the CLI, which have no moderator, can be told apart. Older actions
+-- without a moderator were taken with the admin API key, unless their moderator was deleted.
+ALTER TABLE moderation_actions ADD COLUMN moderator_type TEXT NOT NULL DEFAULT 'user';
+UPDATE moderation_actions SET moderator_type = 'admin_api_key' WHERE moderator_id IS NULL;
+
+-- +goose Down
+ALTER TABLE moderation_actions DROP COLUMN moderator_type;
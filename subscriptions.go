@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+const (
+	planChirpyRedMonthly = "chirpy_red_monthly"
+	planChirpyRedYearly  = "chirpy_red_yearly"
+
+	subscriptionStatusActive   = "active"
+	subscriptionStatusPastDue  = "past_due"
+	subscriptionStatusCanceled = "canceled"
+
+	// subscriptionGracePeriod keeps perks alive after a period ends, covering late renewals and
+	// giving members time to fix a failed payment.
+	subscriptionGracePeriod = 7 * 24 * time.Hour
+)
+
+type Subscription struct {
+	ID                 uuid.UUID  `json:"id"`
+	Plan               string     `json:"plan"`
+	Status             string     `json:"status"`
+	CurrentPeriodStart time.Time  `json:"current_period_start"`
+	CurrentPeriodEnd   time.Time  `json:"current_period_end"`
+	GracePeriodEnd     time.Time  `json:"grace_period_end"`
+	CanceledAt         *time.Time `json:"canceled_at"`
+	Active             bool       `json:"active"`
+}
+
+func subscriptionFromDB(sub database.Subscription) Subscription {
+	return Subscription{
+		ID:                 sub.ID,
+		Plan:               sub.Plan,
+		Status:             sub.Status,
+		CurrentPeriodStart: sub.CurrentPeriodStart,
+		CurrentPeriodEnd:   sub.CurrentPeriodEnd,
+		GracePeriodEnd:     sub.GracePeriodEnd,
+		CanceledAt:         nullTimePtr(sub.CanceledAt),
+		Active:             subscriptionIsActive(sub, time.Now().UTC()),
+	}
+}
+
+// subscriptionIsActive mirrors the GetActiveSubscription query.
+func subscriptionIsActive(sub database.Subscription, now time.Time) bool {
+	return (sub.Status == subscriptionStatusActive || sub.Status == subscriptionStatusPastDue) && sub.GracePeriodEnd.After(now)
+}
+
+func planPeriodEnd(plan string, start time.Time) (time.Time, error) {
+	switch plan {
+	case planChirpyRedMonthly:
+		return start.AddDate(0, 1, 0), nil
+	case planChirpyRedYearly:
+		return start.AddDate(1, 0, 0), nil
+	default:
+		return time.Time{}, fmt.Errorf("unknown plan %q", plan)
+	}
+}
+
+// isChirpyRed reports whether the user currently has an active Chirpy Red subscription.
+func (cfg *apiConfig) isChirpyRed(ctx context.Context, userID uuid.UUID) (bool, error) {
+	_, err := cfg.subscriptions.GetActiveSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// subscriptionEvent is the data Polka sends with subscription related events.
+type subscriptionEvent struct {
+	UserID           uuid.UUID  `json:"user_id"`
+	Plan             string     `json:"plan"`
+	CurrentPeriodEnd *time.Time `json:"current_period_end"`
+}
+
+// periodFrom computes the billing period starting at start, trusting Polka's period end when it sends one.
+func (e subscriptionEvent) periodFrom(plan string, start time.Time) (time.Time, error) {
+	if e.CurrentPeriodEnd != nil {
+		return e.CurrentPeriodEnd.UTC(), nil
+	}
+	return planPeriodEnd(plan, start)
+}
+
+func (cfg *apiConfig) latestSubscription(ctx context.Context, userID uuid.UUID) (database.Subscription, error) {
+	subs, err := cfg.subscriptions.GetSubscriptionsByUser(ctx, userID)
+	if err != nil {
+		return database.Subscription{}, err
+	}
+	if len(subs) == 0 {
+		return database.Subscription{}, fmt.Errorf("user %s has no subscription", userID)
+	}
+	return subs[0], nil
+}
+
+// handleUserUpgraded starts a subscription, or switches the plan of the running one.
+func (cfg *apiConfig) handleUserUpgraded(ctx context.Context, event subscriptionEvent) error {
+	_, err := cfg.users.GetUser(ctx, event.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("user %s not found", event.UserID)
+	}
+	if err != nil {
+		return err
+	}
+	plan := event.Plan
+	if plan == "" {
+		plan = planChirpyRedMonthly
+	}
+	now := time.Now().UTC()
+	periodEnd, err := event.periodFrom(plan, now)
+	if err != nil {
+		return err
+	}
+
+	active, err := cfg.subscriptions.GetActiveSubscription(ctx, event.UserID)
+	if err == nil {
+		sub, err := cfg.subscriptions.RenewSubscription(ctx, database.RenewSubscriptionParams{
+			ID:                 active.ID,
+			Plan:               plan,
+			CurrentPeriodStart: now,
+			CurrentPeriodEnd:   periodEnd,
+			GracePeriodEnd:     periodEnd.Add(subscriptionGracePeriod),
+		})
+		if err != nil {
+			return err
+		}
+		cfg.emitEvent(ctx, eventUserUpgraded, event.UserID, subscriptionEventData(sub))
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	sub, err := cfg.subscriptions.CreateSubscription(ctx, database.CreateSubscriptionParams{
+		UserID:             event.UserID,
+		Plan:               plan,
+		CurrentPeriodStart: now,
+		CurrentPeriodEnd:   periodEnd,
+		GracePeriodEnd:     periodEnd.Add(subscriptionGracePeriod),
+	})
+	if err != nil {
+		return err
+	}
+	cfg.emitEvent(ctx, eventUserUpgraded, event.UserID, subscriptionEventData(sub))
+	return nil
+}
+
+// handleSubscriptionRenewed starts the next billing period where the current one ends, or now if
+// it has already ended. Each webhook event renews the subscription at most once, however often
+// it is delivered or replayed.
+func (cfg *apiConfig) handleSubscriptionRenewed(ctx context.Context, webhookEventID uuid.UUID, event subscriptionEvent) error {
+	sub, err := cfg.latestSubscription(ctx, event.UserID)
+	if err != nil {
+		return err
+	}
+	if sub.Status == subscriptionStatusCanceled {
+		return fmt.Errorf("subscription %s was canceled", sub.ID)
+	}
+	plan := sub.Plan
+	if event.Plan != "" {
+		plan = event.Plan
+	}
+	start := time.Now().UTC()
+	if sub.CurrentPeriodEnd.After(start) {
+		start = sub.CurrentPeriodEnd
+	}
+	periodEnd, err := event.periodFrom(plan, start)
+	if err != nil {
+		return err
+	}
+	if !periodEnd.After(start) {
+		return fmt.Errorf("renewed period ends at %s, before it starts at %s", periodEnd.Format(time.RFC3339), start.Format(time.RFC3339))
+	}
+
+	_, err = cfg.subscriptions.CreateSubscriptionRenewal(ctx, database.CreateSubscriptionRenewalParams{
+		WebhookEventID: webhookEventID,
+		SubscriptionID: sub.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = cfg.subscriptions.RenewSubscription(ctx, database.RenewSubscriptionParams{
+		ID:                 sub.ID,
+		Plan:               plan,
+		CurrentPeriodStart: start,
+		CurrentPeriodEnd:   periodEnd,
+		GracePeriodEnd:     periodEnd.Add(subscriptionGracePeriod),
+	})
+	if err != nil {
+		// Forget the renewal so that a replay of the event can apply it.
+		deleteErr := cfg.subscriptions.DeleteSubscriptionRenewal(ctx, webhookEventID)
+		return errors.Join(err, deleteErr)
+	}
+	return nil
+}
+
+// handlePaymentFailed keeps perks until the grace period after the paid period runs out.
+func (cfg *apiConfig) handlePaymentFailed(ctx context.Context, event subscriptionEvent) error {
+	sub, err := cfg.latestSubscription(ctx, event.UserID)
+	if err != nil {
+		return err
+	}
+	if sub.Status == subscriptionStatusCanceled {
+		return nil
+	}
+	_, err = cfg.subscriptions.MarkSubscriptionPastDue(ctx, database.MarkSubscriptionPastDueParams{
+		ID:             sub.ID,
+		GracePeriodEnd: sub.CurrentPeriodEnd.Add(subscriptionGracePeriod),
+	})
+	return err
+}
+
+// handleUserDowngraded ends the subscription immediately.
+func (cfg *apiConfig) handleUserDowngraded(ctx context.Context, event subscriptionEvent) error {
+	sub, err := cfg.latestSubscription(ctx, event.UserID)
+	if err != nil {
+		return err
+	}
+	if sub.Status == subscriptionStatusCanceled {
+		return nil
+	}
+	sub, err = cfg.subscriptions.CancelSubscription(ctx, sub.ID)
+	if err != nil {
+		return err
+	}
+	cfg.emitEvent(ctx, eventUserDowngraded, event.UserID, subscriptionEventData(sub))
+	return nil
+}
+
+// subscriptionEventData is the subscription as sent in user.upgraded and user.downgraded events.
+func subscriptionEventData(sub database.Subscription) interface{} {
+	return struct {
+		UserID           uuid.UUID `json:"user_id"`
+		Plan             string    `json:"plan"`
+		Status           string    `json:"status"`
+		CurrentPeriodEnd time.Time `json:"current_period_end"`
+	}{UserID: sub.UserID, Plan: sub.Plan, Status: sub.Status, CurrentPeriodEnd: sub.CurrentPeriodEnd}
+}
+
+func (cfg *apiConfig) handlerSubscriptionsList(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.sessionUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+	dbSubs, err := cfg.subscriptions.GetSubscriptionsByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve subscriptions", err)
+		return
+	}
+	subs := []Subscription{}
+	for _, sub := range dbSubs {
+		subs = append(subs, subscriptionFromDB(sub))
+	}
+	respondWithJSON(w, http.StatusOK, subs)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"github.com/acramatte/Chirpy/internal/database"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestSubscriptionRenewed(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name      string
+		periodEnd time.Time
+		start     time.Time
+	}{
+		{"renewed on time", now.Add(24 * time.Hour), now.Add(24 * time.Hour)},
+		{"renewed late", now.Add(-48 * time.Hour), now},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, store := newTestAPIConfig(t)
+			user, err := store.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sub, err := store.CreateSubscription(ctx, database.CreateSubscriptionParams{
+				UserID:             user.ID,
+				Plan:               planChirpyRedMonthly,
+				CurrentPeriodStart: tc.periodEnd.AddDate(0, -1, 0),
+				CurrentPeriodEnd:   tc.periodEnd,
+				GracePeriodEnd:     tc.periodEnd.Add(subscriptionGracePeriod),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			eventID := uuid.New()
+			for range 2 {
+				err = cfg.handleSubscriptionRenewed(ctx, eventID, subscriptionEvent{UserID: user.ID})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			subs, _ := store.GetSubscriptionsByUser(ctx, user.ID)
+			if len(subs) != 1 || subs[0].ID != sub.ID {
+				t.Fatalf("Expected the subscription to be renewed in place, got %+v", subs)
+			}
+			renewed := subs[0]
+			if renewed.CurrentPeriodStart.Sub(tc.start).Abs() > time.Minute {
+				t.Errorf("Expected the period to start at %s, got %s", tc.start, renewed.CurrentPeriodStart)
+			}
+			if !renewed.CurrentPeriodEnd.Equal(renewed.CurrentPeriodStart.AddDate(0, 1, 0)) {
+				t.Errorf("Expected the event to renew the subscription once, got a period ending %s", renewed.CurrentPeriodEnd)
+			}
+
+			err = cfg.handleSubscriptionRenewed(ctx, uuid.New(), subscriptionEvent{UserID: user.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			subs, _ = store.GetSubscriptionsByUser(ctx, user.ID)
+			if !subs[0].CurrentPeriodStart.Equal(renewed.CurrentPeriodEnd) {
+				t.Errorf("Expected another event to renew it again, got a period starting %s", subs[0].CurrentPeriodStart)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
 	"github.com/acramatte/Chirpy/internal/auth"
 	"github.com/acramatte/Chirpy/internal/database"
@@ -35,29 +36,31 @@ func (cfg *apiConfig) handlerUsersCreation(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	err = cfg.passwordPolicy.Validate(params.Password)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
 		return
 	}
-	user, err := cfg.db.CreateUser(r.Context(), database.CreateUserParams{params.Email, hashedPassword})
+	user, err := cfg.users.CreateUser(r.Context(), database.CreateUserParams{Email: params.Email, HashedPassword: sql.NullString{String: hashedPassword, Valid: true}})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create user", err)
 	}
 
-	respondWithJSON(w, http.StatusCreated, User{ID: user.ID, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, Email: user.Email, IsChirpyRed: user.IsChirpyRed})
+	respondWithJSON(w, http.StatusCreated, User{ID: user.ID, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, Email: user.Email, IsChirpyRed: false})
 }
 
+// handlerUsersUpdate changes the caller's email and password. Like the other credential
+// endpoints it requires a session JWT, so a leaked personal access token or OAuth token can't be
+// used to take over the account.
 func (cfg *apiConfig) handlerUsersUpdate(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
+	userID, err := cfg.sessionUserID(r)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
-		return
-	}
-
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		respondWithAuthError(w, err)
 		return
 	}
 
@@ -74,26 +77,59 @@ func (cfg *apiConfig) handlerUsersUpdate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	err = cfg.passwordPolicy.Validate(params.Password)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
 		return
 	}
+	currentUser, err := cfg.users.GetUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve user", err)
+		return
+	}
 
-	updatedUser, err := cfg.db.UpdateEmailAndPassword(r.Context(), database.UpdateEmailAndPasswordParams{
+	updatedUser, err := cfg.users.UpdateEmailAndPassword(r.Context(), database.UpdateEmailAndPasswordParams{
 		ID:             userID,
 		Email:          params.Email,
-		HashedPassword: hashedPassword,
+		HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
 	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
 		return
 	}
+	actorType, actorID := userActor(userID)
+	cfg.audit(r.Context(), auditEntry{
+		Action:     auditPasswordChanged,
+		ActorType:  actorType,
+		ActorID:    actorID,
+		TargetType: "user",
+		TargetID:   userID.String(),
+	})
+	if currentUser.Email != updatedUser.Email {
+		cfg.audit(r.Context(), auditEntry{
+			Action:     auditEmailChanged,
+			ActorType:  actorType,
+			ActorID:    actorID,
+			TargetType: "user",
+			TargetID:   userID.String(),
+			Details:    map[string]interface{}{"from": currentUser.Email, "to": updatedUser.Email},
+		})
+	}
+	isChirpyRed, err := cfg.isChirpyRed(r.Context(), updatedUser.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve subscription", err)
+		return
+	}
 	respondWithJSON(w, http.StatusOK, User{
 		ID:          updatedUser.ID,
 		CreatedAt:   updatedUser.CreatedAt,
 		UpdatedAt:   updatedUser.UpdatedAt,
 		Email:       updatedUser.Email,
-		IsChirpyRed: updatedUser.IsChirpyRed,
+		IsChirpyRed: isChirpyRed,
 	})
 }